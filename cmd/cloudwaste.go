@@ -28,7 +28,7 @@ func main() {
 
 	rootCmd.AddCommand(scan.Cmd(logger))
 	if err := rootCmd.Execute(); err != nil {
-		logger.Errorf("failed to execute root command", zap.Error(err))
+		logger.Errorf("failed to execute root command: %v", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,126 @@
+package scan
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+	"github.com/cloudwaste/cloudwaste/pkg/filter"
+)
+
+var (
+	UnknownConfigKeyError   = errors.New("Unknown config key")
+	InvalidConfigValueError = errors.New("Invalid config value")
+)
+
+// defaultConfigFile returns the config file under $XDG_CONFIG_HOME, or ~/.config if unset
+func defaultConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+
+	return filepath.Join(dir, "cloudwaste", "config.yaml")
+}
+
+// loadConfig reads the config file of the config flag, or the default config file if it
+// exists, into viper. Its keys are the names of the flags, which take precedence, along
+// with the waivers of wasted resources kept on purpose.
+func loadConfig(flags *pflag.FlagSet) error {
+	file := viper.GetString(util.FlagConfig)
+	if file == "" {
+		file = defaultConfigFile()
+		if _, err := os.Stat(file); file == "" || err != nil {
+			return nil
+		}
+	}
+
+	// The config is read on its own first, so only its keys are validated
+	config := viper.New()
+	config.SetConfigFile(file)
+	if err := config.ReadInConfig(); err != nil {
+		return errors.Wrap(err, file)
+	}
+
+	if err := validateConfig(file, config, flags); err != nil {
+		return err
+	}
+
+	viper.SetConfigFile(file)
+	return viper.MergeConfigMap(config.AllSettings())
+}
+
+// validateConfig checks that every key of a config is a flag or the waivers and that its
+// value has the type of the flag, naming the offending key otherwise
+func validateConfig(file string, config *viper.Viper, flags *pflag.FlagSet) error {
+	var keys []string
+	for key := range config.AllSettings() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == filter.WaiversKey {
+			if _, err := filter.UnmarshalWaivers(config, file); err != nil {
+				return err
+			}
+			continue
+		}
+
+		flag := flags.Lookup(key)
+		if flag == nil || key == util.FlagConfig {
+			return errors.Wrap(UnknownConfigKeyError, fmt.Sprintf("%s: %q", file, key))
+		}
+
+		if err := checkType(flag.Value.Type(), config.Get(key)); err != nil {
+			return errors.Wrap(InvalidConfigValueError, fmt.Sprintf("%s: %s: %v", file, key, err))
+		}
+	}
+
+	return nil
+}
+
+// checkType checks that a config value converts to the type of a flag
+func checkType(flagType string, value interface{}) error {
+	var err error
+
+	switch flagType {
+	case "bool":
+		_, err = cast.ToBoolE(value)
+	case "int":
+		_, err = cast.ToIntE(value)
+	case "float64":
+		_, err = cast.ToFloat64E(value)
+	case "duration":
+		// cast reads bare numbers as nanoseconds, so a duration must be a string with a unit like 10m
+		err = errors.New("not a duration string")
+		if s, ok := value.(string); ok {
+			_, err = time.ParseDuration(s)
+		}
+	case "string":
+		_, err = cast.ToStringE(value)
+	case "stringSlice":
+		_, err = cast.ToStringSliceE(value)
+	case "stringToString":
+		_, err = cast.ToStringMapStringE(value)
+	}
+
+	if err != nil {
+		// cast errors name the Go type, the flag type is clearer
+		return fmt.Errorf("expected %s, got %v", strings.TrimSuffix(flagType, "64"), value)
+	}
+	return nil
+}
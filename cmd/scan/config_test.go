@@ -0,0 +1,134 @@
+package scan
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+	"github.com/cloudwaste/cloudwaste/pkg/filter"
+)
+
+// newFlags returns a few flags of the scan command
+func newFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("scan", pflag.ContinueOnError)
+	flags.String(util.FlagConfig, "", "")
+	flags.StringSlice(util.FlagRegions, nil, "")
+	flags.StringToString(util.FlagEndpointURL, nil, "")
+	flags.Float64(util.FlagIdleCPUThreshold, 5, "")
+	flags.Duration(util.FlagDynamoDBLookback, 0, "")
+	flags.String(util.FlagOutput, "text", "")
+	return flags
+}
+
+func writeConfig(t *testing.T, name string, content string) string {
+	dir, err := ioutil.TempDir("", "cloudwaste")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	file := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// readConfig validates a config file against the flags
+func readConfig(t *testing.T, file string) error {
+	config := viper.New()
+	config.SetConfigFile(file)
+	if err := config.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	return validateConfig(file, config, newFlags())
+}
+
+func TestLoadConfig(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	flags := newFlags()
+	for _, flag := range []string{util.FlagConfig, util.FlagRegions, util.FlagIdleCPUThreshold, util.FlagDynamoDBLookback, util.FlagOutput} {
+		assert.Nil(viper.BindPFlag(flag, flags.Lookup(flag)))
+	}
+
+	file := writeConfig(t, "config.toml", `
+regions = ["us-east-1", "eu-west-1"]
+idle-cpu-threshold = 2.5
+dynamodb-lookback = "720h"
+output = "json"
+`)
+	assert.Nil(flags.Parse([]string{"--config", file, "--output", "table"}))
+
+	assert.Nil(loadConfig(flags))
+	assert.Equal([]string{"us-east-1", "eu-west-1"}, viper.GetStringSlice(util.FlagRegions))
+	assert.Equal(2.5, viper.GetFloat64(util.FlagIdleCPUThreshold))
+	assert.Equal(30*24*time.Hour, viper.GetDuration(util.FlagDynamoDBLookback))
+	// Flags take precedence over the config file
+	assert.Equal("table", viper.GetString(util.FlagOutput))
+}
+
+func TestDefaultConfigFile(t *testing.T) {
+	assert := assert.New(t)
+
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", "/etc/xdg")
+
+	assert.Equal("/etc/xdg/cloudwaste/config.yaml", defaultConfigFile())
+}
+
+func TestValidateConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(readConfig(t, writeConfig(t, "config.yaml", `
+regions: [us-east-1]
+endpoint-url:
+  default: http://localhost:4566
+idle-cpu-threshold: 2
+waivers:
+  - id: vol-123
+    owner: finops
+    reason: DR
+    expires: 2021-12-31
+`)))
+
+	assert.Nil(readConfig(t, writeConfig(t, "config.json", `{"output": "csv", "dynamodb-lookback": "48h"}`)))
+
+	// Test error cases
+	err := readConfig(t, writeConfig(t, "config.yaml", "regoins: [us-east-1]\n"))
+	assert.True(errors.Is(err, UnknownConfigKeyError))
+	assert.Contains(err.Error(), `"regoins"`)
+
+	err = readConfig(t, writeConfig(t, "config.yaml", "config: other.yaml\n"))
+	assert.True(errors.Is(err, UnknownConfigKeyError))
+
+	err = readConfig(t, writeConfig(t, "config.yaml", "idle-cpu-threshold: low\n"))
+	assert.True(errors.Is(err, InvalidConfigValueError))
+	assert.Contains(err.Error(), "idle-cpu-threshold: expected float, got low")
+
+	err = readConfig(t, writeConfig(t, "config.yaml", "dynamodb-lookback: two weeks\n"))
+	assert.True(errors.Is(err, InvalidConfigValueError))
+	assert.Contains(err.Error(), "dynamodb-lookback")
+
+	// A bare number has no unit, cast would read it as nanoseconds
+	err = readConfig(t, writeConfig(t, "config.yaml", "dynamodb-lookback: 10\n"))
+	assert.True(errors.Is(err, InvalidConfigValueError))
+	assert.Contains(err.Error(), "dynamodb-lookback: expected duration, got 10")
+
+	err = readConfig(t, writeConfig(t, "config.json", `{"dynamodb-lookback": "10"}`))
+	assert.True(errors.Is(err, InvalidConfigValueError))
+
+	err = readConfig(t, writeConfig(t, "config.yaml", "waivers:\n  - id: vol-123\n"))
+	assert.True(errors.Is(err, filter.InvalidWaiverError))
+	assert.Contains(err.Error(), "waivers[0].owner")
+}
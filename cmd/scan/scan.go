@@ -1,9 +1,26 @@
 package scan
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
 	"github.com/cloudwaste/cloudwaste/pkg/aws"
+	"github.com/cloudwaste/cloudwaste/pkg/aws/dynamodb"
+	"github.com/cloudwaste/cloudwaste/pkg/aws/ec2"
+	"github.com/cloudwaste/cloudwaste/pkg/aws/elb"
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	"github.com/cloudwaste/cloudwaste/pkg/aws/rds"
 	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+	"github.com/cloudwaste/cloudwaste/pkg/filter"
+	"github.com/cloudwaste/cloudwaste/pkg/report"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 )
@@ -11,21 +28,246 @@ import (
 // Cmd runs the scan command
 func Cmd(log *zap.SugaredLogger) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "scan",
-		Short: "Scan your cloud accounts for unused resources",
-		Run: func(_ *cobra.Command, _ []string) {
-			main(log)
+		Use:           "scan",
+		Short:         "Scan your cloud accounts for unused resources",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return main(log, cmd.PersistentFlags())
 		},
 	}
-	cmd.PersistentFlags().String(util.FlagRegion, "", "The AWS region you wish to scan. AWS_REGION env var and AWS shared config file are also supported.")
-	err := viper.BindPFlag(util.FlagRegion, cmd.PersistentFlags().Lookup(util.FlagRegion))
+	flags := cmd.PersistentFlags()
+	flags.String(util.FlagConfig, "", "A YAML, TOML or JSON config file keyed by flag name, which also takes waivers. Defaults to $XDG_CONFIG_HOME/cloudwaste/config.yaml if it exists.")
+	flags.String(util.FlagRegion, "", "The AWS region you wish to scan. AWS_REGION env var and AWS shared config file are also supported.")
+	flags.StringSlice(util.FlagRegions, nil, "A list of AWS regions you wish to scan.")
+	flags.Bool(util.FlagAllRegions, false, "Scan every region enabled for the account.")
+	flags.String(util.FlagProfile, "", "The AWS shared config profile to scan with. AWS_PROFILE env var is also supported.")
+	flags.StringSlice(util.FlagProfiles, nil, "A list of AWS shared config profiles to scan with in one run. Accounts reached by several profiles are scanned once.")
+	flags.String(util.FlagRoleARN, "", "A role to assume before scanning, e.g. arn:aws:iam::123456789012:role/cloudwaste. Other accounts are assumed into from this role.")
+	flags.String(util.FlagRoleName, "", "The name of the role to assume in each account of --accounts or --org.")
+	flags.StringSlice(util.FlagAccounts, nil, "A list of AWS account IDs to scan by assuming --role-name in each of them.")
+	flags.Bool(util.FlagOrg, false, "Scan every active account of the AWS Organization by assuming --role-name in each of them. Requires organizations:ListAccounts.")
+	flags.String(util.FlagExternalID, "", "The external ID to pass when assuming --role-arn and --role-name.")
+	flags.String(util.FlagMFASerial, "", "The serial number or ARN of the MFA device required to assume --role-arn. Without --role-arn, the profile must be an IAM user, whose MFA session then assumes --role-name in each account.")
+	flags.String(util.FlagMFAToken, "", "The token code of --mfa-serial. Prompted for on stdin if not set.")
+	flags.StringToString(util.FlagEndpointURL, nil, "Custom endpoint URLs by service, e.g. ec2=http://localhost:4566, for LocalStack or moto. The default service applies to every service without its own URL.")
+	flags.Int(util.FlagConcurrency, 8, "The maximum number of analyzers to run at once, and of AWS API calls in flight at once across all of them.")
+	flags.Duration(util.FlagTimeout, 0, "Stop the scan after this long, e.g. 10m, reporting the waste found so far and the analyzers cut off as errors. Zero means no timeout.")
+	flags.Duration(util.FlagPricingCacheTTL, pricing.DefaultCacheTTL, "How long AWS price lists are cached under the user cache dir. Zero disables the cache on disk.")
+	flags.Bool(util.FlagRefreshPricing, false, "Fetch AWS price lists again instead of using the cache.")
+	flags.String(util.FlagPricingProfile, "", "The AWS shared config profile to call the Pricing API in us-east-1 with, which prices the commercial and GovCloud regions but only takes credentials of a commercial account. Defaults to the first scanned profile.")
+	flags.String(util.FlagCNPricingProfile, "", "The AWS shared config profile to call the Pricing API of the China regions with, which only takes credentials of a China account. Defaults to the first scanned profile.")
+	flags.String(util.FlagPricingFile, "", "Read prices from an AWS Price List bulk offer file (JSON or CSV), or a directory of them named <ServiceCode>.json, instead of the Pricing API.")
+	flags.StringSlice(util.FlagAnalyzers, nil, "Only run the named analyzers. See --list-analyzers for the available names.")
+	flags.StringSlice(util.FlagDisableAnalyzers, nil, "Skip the named analyzers.")
+	flags.Bool(util.FlagListAnalyzers, false, "List the available analyzers and exit.")
+	flags.StringSlice(util.FlagIncludeTag, nil, "Only report wasted resources with one of these tags, as key=value or key for any value.")
+	flags.StringSlice(util.FlagExcludeTag, nil, "Don't report wasted resources with any of these tags, as key=value or key for any value, e.g. purpose=dr.")
+	flags.String(util.FlagIgnoreFile, filter.DefaultWaiverFile, "A YAML file of waivers for wasted resources kept on purpose, each with an id, owner, reason and expires date and optionally a type and account.")
+	flags.StringP(util.FlagOutput, "o", string(report.FormatText), fmt.Sprintf("The format of the scan report. One of %v.", report.Formats))
+	flags.String(util.FlagOutputFile, "", "Write the scan report to this file instead of stdout.")
+	flags.String(util.FlagSortBy, string(report.SortByNone), fmt.Sprintf("The order of the wasted resources in the scan report. One of %v.", report.SortBys))
+	flags.Bool(util.FlagStrict, false, "Exit with an error if any resource or analyzer couldn't be inspected. The report is written either way.")
+	flags.Float64(util.FlagIdleCPUThreshold, ec2.DefaultIdleCPUThreshold, "The CPU utilization in percent below which an EC2 instance is idle, as the --idle-instance-statistic of every period.")
+	flags.Float64(util.FlagIdleNetworkThreshold, ec2.DefaultIdleNetworkThreshold/(1024*1024), "The megabytes of network traffic in or out per period below which an EC2 instance is idle.")
+	flags.Duration(util.FlagIdleInstanceLookback, ec2.DefaultLookback, "How far back the utilization of EC2 instances is checked against the idle thresholds.")
+	flags.Duration(util.FlagIdleInstancePeriod, ec2.DefaultPeriod, "The period of the utilization data points of EC2 instances, in whole minutes. The idle thresholds apply to each period.")
+	flags.String(util.FlagIdleInstanceStatistic, ec2.DefaultCPUStatistic, "The CloudWatch statistic of the CPU utilization of EC2 instances, e.g. Maximum or p99. Network traffic is always summed.")
+	flags.Duration(util.FlagDynamoDBLookback, dynamodb.DefaultLookback, "How far back the consumed capacity of DynamoDB tables is compared to their provisioned capacity.")
+	flags.Duration(util.FlagDynamoDBPeriod, dynamodb.DefaultPeriod, "The period of the consumed capacity data points of DynamoDB tables, in whole minutes.")
+	flags.String(util.FlagDynamoDBStatistic, dynamodb.DefaultStatistic, "The CloudWatch statistic of the consumed capacity of DynamoDB tables compared to --dynamodb-min-usage.")
+	flags.Float64(util.FlagDynamoDBMinUsage, 0, "The consumed capacity units a DynamoDB table or index must exceed in some period to be used.")
+	flags.Duration(util.FlagRDSIdleLookback, rds.DefaultLookback, "How far back the connections of RDS databases are checked.")
+	flags.Duration(util.FlagRDSIdlePeriod, rds.DefaultPeriod, "The period of the connections data points of RDS databases, in whole minutes.")
+	flags.String(util.FlagRDSIdleStatistic, rds.DefaultStatistic, "The CloudWatch statistic of the connections of RDS databases.")
+	flags.Float64(util.FlagRDSIdleMinUsage, 0, "The connections an RDS database must exceed in some period to be used.")
+	flags.Duration(util.FlagELBIdleLookback, elb.DefaultLookback, "How far back the traffic of load balancers is checked.")
+	flags.Duration(util.FlagELBIdlePeriod, elb.DefaultPeriod, "The period of the traffic data points of load balancers, in whole minutes.")
+	flags.String(util.FlagELBIdleStatistic, elb.DefaultStatistic, "The CloudWatch statistic of the requests and processed bytes of load balancers.")
+	flags.Float64(util.FlagELBIdleMinUsage, 0, "The requests or processed bytes a load balancer must exceed in some period to be used.")
+	flags.Duration(util.FlagStoppedInstanceAge, ec2.DefaultStoppedInstanceAge, "How long an EC2 instance must have been stopped for its volumes and Elastic IPs to be waste.")
+	flags.Duration(util.FlagUnusedImageAge, ec2.DefaultUnusedImageAge, "How old an AMI not used by any instance, launch template or launch configuration must be for its snapshots to be waste.")
+	bindFlags(log, flags)
+
+	return cmd
+}
+
+func bindFlags(log *zap.SugaredLogger, flags *pflag.FlagSet) {
+	flags.VisitAll(func(flag *pflag.Flag) {
+		err := viper.BindPFlag(flag.Name, flag)
+		if err != nil {
+			log.Fatalf("couldn't bind PFlag %s: %v", flag.Name, err)
+		}
+	})
+}
+
+func main(log *zap.SugaredLogger, flags *pflag.FlagSet) error {
+	if err := loadConfig(flags); err != nil {
+		return err
+	}
+
+	if viper.GetBool(util.FlagListAnalyzers) {
+		return listAnalyzers(log)
+	}
+
+	format, err := report.ParseFormat(viper.GetString(util.FlagOutput))
 	if err != nil {
-		log.Fatalf("couldn't bind PFlag", err)
+		return errors.Wrap(err, util.FlagOutput)
 	}
 
-	return cmd
+	sortBy, err := report.ParseSortBy(viper.GetString(util.FlagSortBy))
+	if err != nil {
+		return errors.Wrap(err, util.FlagSortBy)
+	}
+
+	tagFilters, err := parseTagFilters()
+	if err != nil {
+		return err
+	}
+
+	waivers, err := loadWaivers()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := scanContext()
+	defer cancel()
+
+	wastedResources, resourceErrs, err := aws.AnalyzeWaste(ctx, log)
+	if err != nil {
+		return err
+	}
+
+	// Waivers see every wasted resource, so they aren't reported unused because of a tag
+	waived := filter.ApplyWaivers(wastedResources, waivers, time.Now())
+	for _, waiver := range waived.Expired {
+		log.Warnf("Waiver %s expired, its resources are reported again", waiver)
+	}
+	for _, waiver := range waived.Unused {
+		log.Warnf("Waiver %s matches no wasted resource, it can be removed", waiver)
+	}
+
+	wastedResources, suppressed := tagFilters.Apply(waived.Kept)
+	suppressed = append(waived.Suppressed, suppressed...)
+	if len(suppressed) > 0 {
+		log.Infof("Suppressed %d wasted resources by waiver or tag", len(suppressed))
+	}
+
+	if len(wastedResources) == 0 && len(suppressed) == 0 && len(resourceErrs) == 0 {
+		log.Info("Wow! You don't have any waste. Congratulations!")
+	}
+
+	report.Sort(wastedResources, sortBy)
+
+	if err := writeReport(format, report.NewReport(wastedResources, suppressed, resourceErrs)); err != nil {
+		return err
+	}
+
+	if viper.GetBool(util.FlagStrict) && len(resourceErrs) > 0 {
+		return errors.Wrap(aws.IncompleteScanError, fmt.Sprintf("%d errors", len(resourceErrs)))
+	}
+
+	return nil
+}
+
+// parseTagFilters returns the tag filters of the flags
+func parseTagFilters() (filter.TagFilters, error) {
+	include, err := filter.ParseTagFilters(viper.GetStringSlice(util.FlagIncludeTag))
+	if err != nil {
+		return filter.TagFilters{}, errors.Wrap(err, util.FlagIncludeTag)
+	}
+
+	exclude, err := filter.ParseTagFilters(viper.GetStringSlice(util.FlagExcludeTag))
+	if err != nil {
+		return filter.TagFilters{}, errors.Wrap(err, util.FlagExcludeTag)
+	}
+
+	return filter.TagFilters{Include: include, Exclude: exclude}, nil
+}
+
+// loadWaivers returns the waivers of the config file and of the ignore file flag. The
+// default ignore file is optional.
+func loadWaivers() ([]filter.Waiver, error) {
+	// The waivers of the config file were validated along with it
+	waivers, err := filter.UnmarshalWaivers(viper.GetViper(), viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+
+	file := viper.GetString(util.FlagIgnoreFile)
+	if file == "" {
+		return waivers, nil
+	}
+
+	if _, err := os.Stat(file); os.IsNotExist(err) && !viper.IsSet(util.FlagIgnoreFile) {
+		return waivers, nil
+	}
+
+	fileWaivers, err := filter.LoadWaivers(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(waivers, fileWaivers...), nil
 }
 
-func main(log *zap.SugaredLogger) {
-	aws.AnalyzeWaste(log)
+// scanContext returns a context that is cancelled on interrupt or after the scan timeout
+func scanContext() (context.Context, context.CancelFunc) {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	if timeout := viper.GetDuration(util.FlagTimeout); timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(interrupt)
+		cancel()
+	}
+}
+
+func writeReport(format report.Format, scanReport report.Report) error {
+	var w io.Writer = os.Stdout
+
+	if path := viper.GetString(util.FlagOutputFile); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	return report.Render(w, format, scanReport)
+}
+
+func listAnalyzers(log *zap.SugaredLogger) error {
+	registry, err := aws.ListAnalyzers(log)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSERVICE\tENABLED")
+	for _, analyzer := range registry.Analyzers() {
+		fmt.Fprintf(w, "%s\t%s\t%t\n", analyzer.Name(), analyzer.Service(), registry.IsEnabled(analyzer.Name()))
+	}
+
+	return w.Flush()
 }
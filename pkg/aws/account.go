@@ -0,0 +1,210 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/organizations/organizationsiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+// roleSessionName identifies the sessions of the roles assumed by a scan in CloudTrail
+const roleSessionName = "cloudwaste"
+
+var (
+	MissingRoleNameError = errors.New("--role-name is required to scan --accounts or --org")
+	MissingAccountsError = errors.New("--role-name requires --accounts or --org")
+)
+
+// Account is an AWS account to scan and the session to scan it with
+type Account struct {
+	ID string
+	// Alias is the IAM account alias, or the name of the account in the organization if it has none
+	Alias   string
+	Session *session.Session
+	// Regions are the regions to scan, nil to scan every region enabled for the account
+	Regions []string
+}
+
+// assumeRole returns a copy of the session with the credentials of a role, passing the
+// external ID of the flags if set
+func assumeRole(sess *session.Session, roleARN string, options ...func(*stscreds.AssumeRoleProvider)) *session.Session {
+	options = append([]func(*stscreds.AssumeRoleProvider){func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = roleSessionName
+		if externalID := viper.GetString(util.FlagExternalID); externalID != "" {
+			p.ExternalID = aws.String(externalID)
+		}
+	}}, options...)
+
+	return sess.Copy(&aws.Config{
+		Credentials: stscreds.NewCredentials(sess, roleARN, options...),
+	})
+}
+
+// roleARN returns the ARN of a role in an account
+func roleARN(partition string, accountID string, roleName string) string {
+	return arn.ARN{
+		Partition: partition,
+		Service:   "iam",
+		AccountID: accountID,
+		Resource:  "role/" + roleName,
+	}.String()
+}
+
+// resolveAccounts returns the accounts to scan from the account flags, along with the
+// accounts whose role couldn't be assumed. Without --accounts or --org only the account
+// of the session is scanned.
+func resolveAccounts(ctx context.Context, log *zap.SugaredLogger, sess *session.Session) ([]*Account, util.ResourceErrors, error) {
+	globalConfig := aws.NewConfig().WithRegion(homeRegion(sess))
+
+	identity, err := sts.New(sess, globalConfig).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roleName := viper.GetString(util.FlagRoleName)
+	accountIDs := viper.GetStringSlice(util.FlagAccounts)
+	org := viper.GetBool(util.FlagOrg)
+
+	if len(accountIDs) == 0 && !org {
+		if roleName != "" {
+			return nil, nil, MissingAccountsError
+		}
+
+		account := &Account{ID: aws.StringValue(identity.Account), Session: sess}
+		account.Alias = accountAlias(ctx, log, iam.New(sess, globalConfig), account.ID)
+		return []*Account{account}, nil, nil
+	}
+
+	if roleName == "" {
+		return nil, nil, MissingRoleNameError
+	}
+
+	callerARN, err := arn.Parse(aws.StringValue(identity.Arn))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Accounts without an alias fall back to their name in the organization
+	names := make(map[string]string)
+
+	if org {
+		orgAccounts, err := listOrganizationAccounts(ctx, organizations.New(sess, globalConfig))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, orgAccount := range orgAccounts {
+			accountIDs = append(accountIDs, aws.StringValue(orgAccount.Id))
+			names[aws.StringValue(orgAccount.Id)] = aws.StringValue(orgAccount.Name)
+		}
+	}
+
+	var unique []string
+	seen := make(map[string]bool)
+	for _, id := range accountIDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	accounts := make([]*Account, len(unique))
+	errs := make([]error, len(unique))
+
+	err = util.ForEach(ctx, viper.GetInt(util.FlagConcurrency), len(unique), func(i int) {
+		account := &Account{
+			ID:      unique[i],
+			Session: assumeRole(sess, roleARN(callerARN.Partition, unique[i], roleName)),
+		}
+
+		// Assume the role up front, so an account that can't be assumed fails once instead of in every analyzer
+		if _, err := account.Session.Config.Credentials.GetWithContext(ctx); err != nil {
+			errs[i] = err
+			return
+		}
+
+		account.Alias = accountAlias(ctx, log, iam.New(account.Session, globalConfig), account.ID)
+		if account.Alias == "" {
+			account.Alias = names[account.ID]
+		}
+		accounts[i] = account
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resolved []*Account
+	var resourceErrs util.ResourceErrors
+	for i, account := range accounts {
+		if errs[i] != nil {
+			log.Errorf("couldn't assume %s in account %s: %v", roleName, unique[i], errs[i])
+
+			resourceErr := util.NewResourceError(unique[i], "AssumeRole", errs[i])
+			resourceErr.AccountID = unique[i]
+			resourceErrs = append(resourceErrs, resourceErr)
+			continue
+		}
+		resolved = append(resolved, account)
+	}
+
+	return resolved, resourceErrs, nil
+}
+
+// listOrganizationAccounts returns the active accounts of the organization
+func listOrganizationAccounts(ctx context.Context, api organizationsiface.OrganizationsAPI) ([]*organizations.Account, error) {
+	var accounts []*organizations.Account
+
+	err := api.ListAccountsPagesWithContext(ctx, &organizations.ListAccountsInput{},
+		func(page *organizations.ListAccountsOutput, lastPage bool) bool {
+			for _, account := range page.Accounts {
+				// Suspended accounts can't be assumed into and aren't billed
+				if aws.StringValue(account.Status) == organizations.AccountStatusActive {
+					accounts = append(accounts, account)
+				}
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// accountAlias returns the IAM alias of an account, or an empty string if it has none or
+// it can't be listed, which doesn't keep the account from being scanned
+func accountAlias(ctx context.Context, log *zap.SugaredLogger, api iamiface.IAMAPI, accountID string) string {
+	resp, err := api.ListAccountAliasesWithContext(ctx, &iam.ListAccountAliasesInput{})
+	if err != nil {
+		log.Warnf("couldn't list the aliases of account %s: %v", accountID, err)
+		return ""
+	}
+
+	if len(resp.AccountAliases) == 0 {
+		return ""
+	}
+
+	return aws.StringValue(resp.AccountAliases[0])
+}
+
+// describeAccount formats an account for the logs
+func describeAccount(account *Account) string {
+	if account.Alias == "" {
+		return account.ID
+	}
+	return fmt.Sprintf("%s (%s)", account.ID, account.Alias)
+}
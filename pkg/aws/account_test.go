@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/organizations/organizationsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+type mockedOrganizations struct {
+	mock.Mock
+	organizationsiface.OrganizationsAPI
+}
+
+func (m *mockedOrganizations) ListAccountsPagesWithContext(ctx context.Context, input *organizations.ListAccountsInput, fn func(*organizations.ListAccountsOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*organizations.ListAccountsOutput), true)
+	}
+	return args.Error(1)
+}
+
+type mockedIAM struct {
+	mock.Mock
+	iamiface.IAMAPI
+}
+
+func (m *mockedIAM) ListAccountAliasesWithContext(ctx context.Context, input *iam.ListAccountAliasesInput, opts ...request.Option) (*iam.ListAccountAliasesOutput, error) {
+	args := m.Called(ctx, input)
+
+	if args.Error(1) == nil {
+		return args.Get(0).(*iam.ListAccountAliasesOutput), nil
+	}
+	return nil, args.Error(1)
+}
+
+func TestRoleARN(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("arn:aws:iam::123456789012:role/cloudwaste", roleARN("aws", "123456789012", "cloudwaste"))
+	assert.Equal("arn:aws-cn:iam::123456789012:role/audit/cloudwaste", roleARN("aws-cn", "123456789012", "audit/cloudwaste"))
+}
+
+func TestListOrganizationAccounts(t *testing.T) {
+	assert := assert.New(t)
+
+	m := new(mockedOrganizations)
+	m.On("ListAccountsPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&organizations.ListAccountsOutput{
+			Accounts: []*organizations.Account{
+				{Id: aws.String("111111111111"), Status: aws.String(organizations.AccountStatusActive)},
+				{Id: aws.String("222222222222"), Status: aws.String(organizations.AccountStatusSuspended)},
+				{Id: aws.String("333333333333"), Status: aws.String(organizations.AccountStatusActive)},
+			},
+		}, nil)
+
+	accounts, err := listOrganizationAccounts(context.TODO(), m)
+	assert.Nil(err)
+	if assert.Equal(2, len(accounts)) {
+		assert.Equal("111111111111", *accounts[0].Id)
+		assert.Equal("333333333333", *accounts[1].Id)
+	}
+
+	// Test error cases
+	m = new(mockedOrganizations)
+	m.On("ListAccountsPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("AWSOrganizationsNotInUseException"))
+
+	accounts, err = listOrganizationAccounts(context.TODO(), m)
+	assert.Nil(accounts)
+	assert.NotNil(err)
+}
+
+func TestAccountAlias(t *testing.T) {
+	assert := assert.New(t)
+	log := zap.NewNop().Sugar()
+
+	m := new(mockedIAM)
+	m.On("ListAccountAliasesWithContext", mock.Anything, mock.Anything).
+		Return(&iam.ListAccountAliasesOutput{AccountAliases: aws.StringSlice([]string{"prod"})}, nil).Once()
+	assert.Equal("prod", accountAlias(context.TODO(), log, m, "123456789012"))
+
+	m.On("ListAccountAliasesWithContext", mock.Anything, mock.Anything).
+		Return(&iam.ListAccountAliasesOutput{}, nil).Once()
+	assert.Equal("", accountAlias(context.TODO(), log, m, "123456789012"))
+
+	// The account is still scanned without its alias
+	m.On("ListAccountAliasesWithContext", mock.Anything, mock.Anything).
+		Return(nil, errors.New("AccessDenied")).Once()
+	assert.Equal("", accountAlias(context.TODO(), log, m, "123456789012"))
+}
@@ -2,91 +2,495 @@ package aws
 
 import (
 	"context"
-	"os"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
 	dynamoWaste "github.com/cloudwaste/cloudwaste/pkg/aws/dynamodb"
 	ec2Waste "github.com/cloudwaste/cloudwaste/pkg/aws/ec2"
+	elbWaste "github.com/cloudwaste/cloudwaste/pkg/aws/elb"
 	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	rdsWaste "github.com/cloudwaste/cloudwaste/pkg/aws/rds"
 	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
 )
 
-func AnalyzeWaste(log *zap.SugaredLogger) {
-	sess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
+var (
+	NoRegionError       = errors.New("no region provided or found in AWS config")
+	IncompleteScanError = errors.New("some resources couldn't be inspected")
+)
 
-	var region string
+// NewPricing returns the pricing client shared by every region of a scan. Price lists
+// come from the offer files of the pricing file flag if set and the Pricing API otherwise,
+// and are cached according to the flags. Each Pricing API only takes credentials of its
+// own partition, so it's called with the session of its pricing profile flag if set and
+// with sess otherwise.
+func NewPricing(sess *session.Session, limiter util.Limiter) (pricingWaste.PricingInterface, error) {
+	if path := viper.GetString(util.FlagPricingFile); path != "" {
+		// Offer files are local already, so only keep parsed price lists in memory
+		return &pricingWaste.CachedClient{
+			Pricing: &pricingWaste.FileClient{Path: path},
+		}, nil
+	}
 
-	if viper.IsSet(util.FlagRegion) {
-		region = viper.GetString(util.FlagRegion)
-	} else if *sess.Config.Region != "" {
-		region = *sess.Config.Region
-	} else {
-		log.Error("no region provided or found in AWS config.")
-		os.Exit(-1)
+	pricingSess, err := pricingSession(sess, util.FlagPricingProfile, limiter)
+	if err != nil {
+		return nil, err
+	}
+	cnPricingSess, err := pricingSession(sess, util.FlagCNPricingProfile, limiter)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &pricingWaste.CachedClient{
+		Pricing: &pricingWaste.Client{
+			Pricing: pricing.New(pricingSess, aws.NewConfig().WithRegion(endpoints.UsEast1RegionID)),
+			PartitionPricing: map[string]pricingiface.PricingAPI{
+				endpoints.CnNorthwest1RegionID: pricing.New(cnPricingSess, aws.NewConfig().WithRegion(endpoints.CnNorthwest1RegionID)),
+			},
+		},
+		TTL:     viper.GetDuration(util.FlagPricingCacheTTL),
+		Refresh: viper.GetBool(util.FlagRefreshPricing),
+	}
+
+	if client.TTL > 0 {
+		dir, err := pricingWaste.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		client.Dir = dir
+	}
+
+	return client, nil
+}
+
+// pricingSession returns the session of the profile of a pricing profile flag, or sess if it isn't set
+func pricingSession(sess *session.Session, profileFlag string, limiter util.Limiter) (*session.Session, error) {
+	profile := viper.GetString(profileFlag)
+	if profile == "" {
+		return sess, nil
+	}
+
+	pricingSess, err := newSession(profile, limiter)
+	if err != nil {
+		return nil, errors.Wrap(err, profileFlag)
 	}
+	return pricingSess, nil
+}
 
+// NewRegistry builds the service clients for a region and registers their analyzers
+// into a registry, enabling and disabling analyzers according to the flags
+func NewRegistry(log *zap.SugaredLogger, sess *session.Session, region string, priceList pricingWaste.PricingInterface) (*util.Registry, error) {
 	awsConfig := aws.NewConfig().WithRegion(region)
-	pricingAwsConfig := aws.NewConfig().WithRegion("us-east-1")
+
+	concurrency := viper.GetInt(util.FlagConcurrency)
+
+	idleInstanceMetrics, err := metricSettings(util.FlagIdleInstanceLookback, util.FlagIdleInstancePeriod, util.FlagIdleInstanceStatistic, "")
+	if err != nil {
+		return nil, err
+	}
+	dynamoMetrics, err := metricSettings(util.FlagDynamoDBLookback, util.FlagDynamoDBPeriod, util.FlagDynamoDBStatistic, util.FlagDynamoDBMinUsage)
+	if err != nil {
+		return nil, err
+	}
+	rdsIdleMetrics, err := metricSettings(util.FlagRDSIdleLookback, util.FlagRDSIdlePeriod, util.FlagRDSIdleStatistic, util.FlagRDSIdleMinUsage)
+	if err != nil {
+		return nil, err
+	}
+	elbIdleMetrics, err := metricSettings(util.FlagELBIdleLookback, util.FlagELBIdlePeriod, util.FlagELBIdleStatistic, util.FlagELBIdleMinUsage)
+	if err != nil {
+		return nil, err
+	}
 
 	ec2Client := &ec2Waste.Client{
-		Logger:  log,
-		EC2:     ec2.New(sess, awsConfig),
-		Pricing: pricing.New(sess, pricingAwsConfig),
+		Logger:               log,
+		EC2:                  ec2.New(sess, awsConfig),
+		Cloudwatch:           cloudwatch.New(sess, awsConfig),
+		Autoscaling:          autoscaling.New(sess, awsConfig),
+		Pricing:              priceList,
+		Concurrency:          concurrency,
+		IdleCPUThreshold:     viper.GetFloat64(util.FlagIdleCPUThreshold),
+		IdleNetworkThreshold: viper.GetFloat64(util.FlagIdleNetworkThreshold) * 1024 * 1024,
+		IdleMetrics:          idleInstanceMetrics,
+		StoppedInstanceAge:   viper.GetDuration(util.FlagStoppedInstanceAge),
+		UnusedImageAge:       viper.GetDuration(util.FlagUnusedImageAge),
 	}
 
 	dynamoClient := &dynamoWaste.Client{
-		DynamoDB:   dynamodb.New(sess, aws.NewConfig().WithRegion(region)),
-		Cloudwatch: cloudwatch.New(sess, aws.NewConfig().WithRegion(region)),
-		Pricing:    &pricingWaste.Client{Pricing: pricing.New(sess, aws.NewConfig().WithRegion("us-east-1"))},
+		DynamoDB:    dynamodb.New(sess, awsConfig),
+		Cloudwatch:  cloudwatch.New(sess, awsConfig),
+		Pricing:     priceList,
+		Concurrency: concurrency,
+		Metrics:     dynamoMetrics,
+	}
+
+	rdsClient := &rdsWaste.Client{
+		RDS:         rds.New(sess, awsConfig),
+		Cloudwatch:  cloudwatch.New(sess, awsConfig),
+		Pricing:     priceList,
+		Concurrency: concurrency,
+		IdleMetrics: rdsIdleMetrics,
+	}
+
+	elbClient := &elbWaste.Client{
+		ELB:         elb.New(sess, awsConfig),
+		ELBV2:       elbv2.New(sess, awsConfig),
+		Cloudwatch:  cloudwatch.New(sess, awsConfig),
+		Pricing:     priceList,
+		Concurrency: concurrency,
+		IdleMetrics: elbIdleMetrics,
+	}
+
+	registry := util.NewRegistry()
+
+	if err := ec2Client.RegisterAnalyzers(registry); err != nil {
+		return nil, err
+	}
+	if err := dynamoClient.RegisterAnalyzers(registry); err != nil {
+		return nil, err
+	}
+	if err := rdsClient.RegisterAnalyzers(registry); err != nil {
+		return nil, err
+	}
+	if err := elbClient.RegisterAnalyzers(registry); err != nil {
+		return nil, err
+	}
+
+	if names := viper.GetStringSlice(util.FlagAnalyzers); len(names) > 0 {
+		if err := registry.Only(names...); err != nil {
+			return nil, errors.Wrap(err, util.FlagAnalyzers)
+		}
+	}
+	if names := viper.GetStringSlice(util.FlagDisableAnalyzers); len(names) > 0 {
+		if err := registry.Disable(names...); err != nil {
+			return nil, errors.Wrap(err, util.FlagDisableAnalyzers)
+		}
+	}
+
+	return registry, nil
+}
+
+// metricSettings reads the metric settings of an analyzer from its flags, without a min
+// usage flag for analyzers with thresholds of their own. Unset flags fall back to the
+// defaults of the analyzer.
+func metricSettings(lookbackFlag string, periodFlag string, statFlag string, minUsageFlag string) (util.MetricSettings, error) {
+	settings := util.MetricSettings{
+		Lookback: viper.GetDuration(lookbackFlag),
+		Period:   viper.GetDuration(periodFlag),
+		Stat:     viper.GetString(statFlag),
+	}
+	if minUsageFlag != "" {
+		settings.MinUsage = viper.GetFloat64(minUsageFlag)
+	}
+
+	if settings.Period != 0 {
+		if err := util.ValidatePeriod(settings.Period); err != nil {
+			return settings, errors.Wrap(err, periodFlag)
+		}
+	}
+	if settings.Stat != "" {
+		if err := util.ValidateStatistic(settings.Stat); err != nil {
+			return settings, errors.Wrap(err, statFlag)
+		}
+	}
+
+	return settings, nil
+}
+
+// ListAnalyzers returns a registry of every known analyzer without scanning anything
+func ListAnalyzers(log *zap.SugaredLogger) (*util.Registry, error) {
+	sess, err := newSession(viper.GetString(util.FlagProfile), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRegistry(log, sess, "", &pricingWaste.Client{})
+}
+
+// AnalyzeWaste runs every enabled analyzer in every requested account and region and
+// returns the wasted resources it found, tagged with their account and region, along with
+// the resources, analyzers, accounts and profiles that failed. Analyzers run concurrently,
+// but the results are always ordered by account, then by region and then by analyzer.
+// Every analyzer shares the AWS API calls the concurrency flag allows at once. Once ctx
+// is done, the results found so far are returned and the analyzers that were cut off
+// are reported as failed.
+func AnalyzeWaste(ctx context.Context, log *zap.SugaredLogger) ([]util.AWSWastedResource, util.ResourceErrors, error) {
+	profiles := profileNames()
+	limiter := util.NewLimiter(viper.GetInt(util.FlagConcurrency))
+
+	var (
+		sess         *session.Session
+		accounts     []*Account
+		resourceErrs util.ResourceErrors
+	)
+	seen := make(map[string]bool)
+
+	for _, profile := range profiles {
+		profileSess, profileAccounts, profileErrs, err := resolveProfile(ctx, log, profile, limiter)
+		if err != nil {
+			// The only profile failing fails the whole scan
+			if len(profiles) == 1 {
+				return nil, nil, err
+			}
+
+			log.Errorf("couldn't scan profile %s: %v", profile, err)
+			resourceErrs = append(resourceErrs, util.NewResourceError(profile, "", err))
+			continue
+		}
+
+		if sess == nil {
+			sess = profileSess
+		}
+		resourceErrs = append(resourceErrs, profileErrs...)
+
+		for _, account := range profileAccounts {
+			// Profiles of the same account would report its waste twice
+			if seen[account.ID] {
+				log.Warnf("skipping account %s of profile %s, it's scanned already", describeAccount(account), profile)
+				continue
+			}
+			seen[account.ID] = true
+			accounts = append(accounts, account)
+		}
+	}
+
+	if sess == nil {
+		return nil, resourceErrs, nil
+	}
+
+	priceList, err := NewPricing(sess, limiter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	type job struct {
+		account  *Account
+		region   string
+		analyzer util.Analyzer
+	}
+
+	var jobs []job
+
+	for _, account := range accounts {
+		accountRegions := account.Regions
+		if accountRegions == nil {
+			accountRegions, err = resolveRegions(ctx, log, account.Session)
+			if err != nil {
+				// The only account failing fails the whole scan
+				if len(accounts) == 1 {
+					return nil, nil, err
+				}
+
+				log.Errorf("couldn't list the regions of account %s: %v", describeAccount(account), err)
+
+				resourceErr := util.NewResourceError(account.ID, "DescribeRegions", err)
+				resourceErr.AccountID = account.ID
+				resourceErrs = append(resourceErrs, resourceErr)
+				continue
+			}
+		}
+
+		for _, region := range accountRegions {
+			registry, err := NewRegistry(log, account.Session, region, priceList)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			for _, analyzer := range registry.Enabled() {
+				jobs = append(jobs, job{account: account, region: region, analyzer: analyzer})
+			}
+		}
+	}
+
+	results := make([][]util.AWSWastedResource, len(jobs))
+	failures := make([]util.ResourceErrors, len(jobs))
+	started := make([]bool, len(jobs))
+
+	// Run all the enabled checks
+	err = util.ForEach(ctx, viper.GetInt(util.FlagConcurrency), len(jobs), func(i int) {
+		j := jobs[i]
+		started[i] = true
+
+		wasted, analyzeErr := j.analyzer.Analyze(ctx, j.region)
+		jobErrs, analyzeErr := util.SplitResourceErrors(analyzeErr)
+		if analyzeErr != nil {
+			log.Errorf("failed to run analyzer %s in %s of account %s: %v", j.analyzer.Name(), j.region, describeAccount(j.account), analyzeErr)
+			// A failed analyzer is reported like a resource it couldn't inspect
+			jobErrs = util.ResourceErrors{util.NewResourceError("", "", analyzeErr)}
+		}
+
+		for _, resourceErr := range jobErrs {
+			resourceErr.Analyzer = j.analyzer.Name()
+			resourceErr.Region = j.region
+			resourceErr.AccountID = j.account.ID
+
+			if resourceErr.ResourceID != "" {
+				log.Warnf("analyzer %s couldn't inspect %s in %s of account %s: %v", j.analyzer.Name(), resourceErr.ResourceID, j.region, describeAccount(j.account), resourceErr.Err)
+			}
+		}
+		failures[i] = jobErrs
+
+		for k := range wasted {
+			wasted[k].Region = j.region
+			wasted[k].AccountID = j.account.ID
+			wasted[k].AccountAlias = j.account.Alias
+			wasted[k].Price.Currency = util.RegionCurrency(j.region)
+
+			if _, costErr := util.NewCost(wasted[k].Price); costErr != nil {
+				log.Warnf("analyzer %s reported %s with an unnormalized price: %v", j.analyzer.Name(), wasted[k].Resource.R.ID(), costErr)
+			}
+		}
+		results[i] = wasted
+	})
+
+	// The analyzers that never started are reported like the ones that failed
+	if err != nil {
+		var cutOff int
+		for i, j := range jobs {
+			if started[i] {
+				continue
+			}
+			cutOff++
+
+			resourceErr := util.NewResourceError("", "", err)
+			resourceErr.Analyzer = j.analyzer.Name()
+			resourceErr.Region = j.region
+			resourceErr.AccountID = j.account.ID
+			failures[i] = util.ResourceErrors{resourceErr}
+		}
+		log.Errorf("scan stopped before %d of %d analyzers ran: %v", cutOff, len(jobs), err)
 	}
 
 	var wastedResources []util.AWSWastedResource
+	for i, wasted := range results {
+		wastedResources = append(wastedResources, wasted...)
+		resourceErrs = append(resourceErrs, failures[i]...)
+	}
 
-	// Run all the checks
-	wastedNATGateways, err := ec2Client.AnalyzeNATGatewayWaste(context.TODO(), region)
-	if err == nil {
-		wastedResources = append(wastedResources, wastedNATGateways...)
-	} else {
-		log.Errorf("failed to analyze NAT Gateways: %v", err)
+	return wastedResources, resourceErrs, nil
+}
+
+// resolveProfile returns the session of a shared config profile, the default profile if
+// empty, limited by the limiter, and the accounts to scan with it along with the accounts
+// that failed
+func resolveProfile(ctx context.Context, log *zap.SugaredLogger, profile string, limiter util.Limiter) (*session.Session, []*Account, util.ResourceErrors, error) {
+	sess, err := newSession(profile, limiter)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	wastedEBSVolumes, err := ec2Client.AnalyzeEBSVolumeWaste(context.TODO(), region)
-	if err == nil {
-		wastedResources = append(wastedResources, wastedEBSVolumes...)
+
+	// MFA authenticates the role ARN if set, and otherwise the roles assumed in each account
+	if role := viper.GetString(util.FlagRoleARN); role != "" {
+		sess = assumeRole(sess, role, withMFA)
 	} else {
-		log.Errorf("failed to analyze EBS Volumes: %v", err)
+		sess = withSessionToken(sess)
 	}
-	wastedDynamoDBTables, err := dynamoClient.AnalyzeDynamodBTableWaste(context.TODO(), region)
-	if err == nil {
-		wastedResources = append(wastedResources, wastedDynamoDBTables...)
-	} else {
-		log.Errorf("failed to analyze EBS Volumes: %v", err)
+
+	// Every account shares the requested regions, unless each account's enabled regions are scanned
+	var regions []string
+	if !viper.GetBool(util.FlagAllRegions) {
+		regions, err = resolveRegions(ctx, log, sess)
+		if err != nil {
+			return nil, nil, nil, err
+		}
 	}
-	wastedElasticIPAddresses, err := ec2Client.AnalyzeElasticIPAddressWaste(context.TODO(), region)
-	if err == nil {
-		wastedResources = append(wastedResources, wastedElasticIPAddresses...)
-	} else {
-		log.Errorf("failed to analyze Elastic IP Addresses: %v", err)
-		_, err := ec2Client.AnalyzeNATGatewayWaste(context.TODO(), region)
+
+	accounts, resourceErrs, err := resolveAccounts(ctx, log, sess)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for _, account := range accounts {
+		account.Regions = regions
+	}
+
+	return sess, accounts, resourceErrs, nil
+}
+
+// defaultRegion returns the region of the region flag, falling back to the region of the AWS config
+func defaultRegion(sess *session.Session) string {
+	if viper.IsSet(util.FlagRegion) {
+		return viper.GetString(util.FlagRegion)
+	}
+	return aws.StringValue(sess.Config.Region)
+}
+
+// homeRegion returns the region to call global services like STS, IAM and Organizations in
+func homeRegion(sess *session.Session) string {
+	if region := defaultRegion(sess); region != "" {
+		return region
+	}
+	return endpoints.UsEast1RegionID
+}
+
+// resolveRegions returns the regions to scan from the region flags, falling back
+// to the region of the AWS config
+func resolveRegions(ctx context.Context, log *zap.SugaredLogger, sess *session.Session) ([]string, error) {
+	if viper.GetBool(util.FlagAllRegions) {
+		ec2Client := &ec2Waste.Client{
+			Logger: log,
+			EC2:    ec2.New(sess, aws.NewConfig().WithRegion(homeRegion(sess))),
+		}
+
+		regions, err := ec2Client.GetEnabledRegions(ctx)
 		if err != nil {
-			os.Exit(-1)
+			return nil, err
 		}
+
+		return pricedRegions(log, regions), nil
 	}
 
-	if len(wastedResources) == 0 {
-		log.Info("Wow! You don't have any waste. Congratulations!")
-	} else {
-		for _, r := range wastedResources {
-			log.Infof("%s - %s: $%f/%s", r.Resource.R.Type(), r.Resource.R.ID(), r.Price.Rate, r.Price.Unit)
+	var regions []string
+	seen := make(map[string]bool)
+
+	for _, region := range append([]string{viper.GetString(util.FlagRegion)}, viper.GetStringSlice(util.FlagRegions)...) {
+		if region != "" && !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+
+	if len(regions) == 0 {
+		region := defaultRegion(sess)
+		if region == "" {
+			return nil, NoRegionError
+		}
+		regions = append(regions, region)
+	}
+
+	// Fail before scanning anything if a requested region can't be priced
+	for _, region := range regions {
+		if _, err := util.ResolveLocation(region); err != nil {
+			return nil, err
+		}
+	}
+
+	return regions, nil
+}
+
+// pricedRegions returns the regions with a known pricing location, warning about the
+// rest. Regions enabled for the account may be newer than the locations known to
+// cloudwaste, and every resource of them would fail to be priced.
+func pricedRegions(log *zap.SugaredLogger, regions []string) []string {
+	var priced []string
+	for _, region := range regions {
+		if _, err := util.ResolveLocation(region); err != nil {
+			log.Warnf("skipping region %s: %v", region, err)
+			continue
 		}
+		priced = append(priced, region)
 	}
+	return priced
 }
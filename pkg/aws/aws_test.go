@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+func TestMetricSettings(t *testing.T) {
+	assert := assert.New(t)
+	defer viper.Reset()
+
+	viper.Set(util.FlagRDSIdleLookback, 30*24*time.Hour)
+	viper.Set(util.FlagRDSIdlePeriod, time.Hour)
+	viper.Set(util.FlagRDSIdleStatistic, "Average")
+	viper.Set(util.FlagRDSIdleMinUsage, 0.5)
+
+	settings, err := metricSettings(util.FlagRDSIdleLookback, util.FlagRDSIdlePeriod, util.FlagRDSIdleStatistic, util.FlagRDSIdleMinUsage)
+	assert.Nil(err)
+	assert.Equal(util.MetricSettings{Lookback: 30 * 24 * time.Hour, Period: time.Hour, Stat: "Average", MinUsage: 0.5}, settings)
+
+	// Analyzers without a min usage flag ignore it, unset flags are left to the analyzer
+	settings, err = metricSettings(util.FlagIdleInstanceLookback, util.FlagIdleInstancePeriod, util.FlagIdleInstanceStatistic, "")
+	assert.Nil(err)
+	assert.Equal(util.MetricSettings{}, settings)
+
+	// Test error cases
+	viper.Set(util.FlagRDSIdleStatistic, "avg")
+	_, err = metricSettings(util.FlagRDSIdleLookback, util.FlagRDSIdlePeriod, util.FlagRDSIdleStatistic, util.FlagRDSIdleMinUsage)
+	assert.True(errors.Is(err, util.InvalidStatisticError))
+	assert.Contains(err.Error(), util.FlagRDSIdleStatistic)
+
+	viper.Set(util.FlagRDSIdlePeriod, 90*time.Second)
+	_, err = metricSettings(util.FlagRDSIdleLookback, util.FlagRDSIdlePeriod, util.FlagRDSIdleStatistic, util.FlagRDSIdleMinUsage)
+	assert.True(errors.Is(err, util.InvalidPeriodError))
+	assert.Contains(err.Error(), util.FlagRDSIdlePeriod)
+}
+
+func TestPricedRegions(t *testing.T) {
+	assert := assert.New(t)
+
+	log := zap.NewNop().Sugar()
+
+	regions := pricedRegions(log, []string{"ap-southeast-5", "eu-west-1", "xx-unknown-1", "mx-central-1"})
+	assert.Equal([]string{"ap-southeast-5", "eu-west-1", "mx-central-1"}, regions)
+
+	assert.Nil(pricedRegions(log, []string{"xx-unknown-1"}))
+}
@@ -2,16 +2,18 @@ package dynamodb
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/pkg/errors"
 
 	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
 	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
@@ -20,23 +22,120 @@ import (
 type DynamoPricingFacet string
 
 const (
-	ReadCapacityUnitHours      DynamoPricingFacet = "ReadCapacityUnit-Hrs"
-	WriteCapacityUnitHours     DynamoPricingFacet = "WriteCapacityUnit-Hrs"
-	ReplWriteCapacityUnitHours DynamoPricingFacet = "ReplWriteCapacityUnit-Hrs"
+	ReadCapacityUnitHours       DynamoPricingFacet = "ReadCapacityUnit-Hrs"
+	WriteCapacityUnitHours      DynamoPricingFacet = "WriteCapacityUnit-Hrs"
+	ReplWriteCapacityUnitHours  DynamoPricingFacet = "ReplWriteCapacityUnit-Hrs"
+	ReadRequestUnits            DynamoPricingFacet = "ReadRequestUnits"
+	WriteRequestUnits           DynamoPricingFacet = "WriteRequestUnits"
+	ReplWriteRequestUnits       DynamoPricingFacet = "ReplWriteRequestUnits"
+	TimedStorageByteHours       DynamoPricingFacet = "TimedStorage-ByteHrs"
+	TimedPITRStorageByteHours   DynamoPricingFacet = "TimedPITRStorage-ByteHrs"
+	TimedBackupStorageByteHours DynamoPricingFacet = "TimedBackupStorage-ByteHrs"
+	StreamsReadRequestUnits     DynamoPricingFacet = "Streams-ReadRequestUnits"
 )
 
+// facetUnits maps each pricing facet to the unit of its price
+var facetUnits = map[DynamoPricingFacet]string{
+	ReadCapacityUnitHours:       "Hr",
+	WriteCapacityUnitHours:      "Hr",
+	ReplWriteCapacityUnitHours:  "Hr",
+	ReadRequestUnits:            "Unit",
+	WriteRequestUnits:           "Unit",
+	ReplWriteRequestUnits:       "Unit",
+	TimedStorageByteHours:       "Mo",
+	TimedPITRStorageByteHours:   "Mo",
+	TimedBackupStorageByteHours: "Mo",
+	StreamsReadRequestUnits:     "Unit",
+}
+
+// infrequentAccessPrefix prefixes the capacity, request and storage usage types of the
+// Standard-IA table class, e.g. IA-ReadCapacityUnit-Hrs
+const infrequentAccessPrefix = "IA-"
+
+type TableClass string
+
+const (
+	TableClassStandard                 TableClass = "STANDARD"
+	TableClassStandardInfrequentAccess TableClass = "STANDARD_INFREQUENT_ACCESS"
+)
+
+// bytesPerGB converts the size of tables and backups to the GB of the price list
+const bytesPerGB = 1024 * 1024 * 1024
+
 const (
 	tableType = "DynamoDB Table"
+
+	analyzerService = "dynamodb"
+
+	AnalyzerTable = "dynamodb-table"
+)
+
+// Rules identify why a table is wasted
+const (
+	RuleTableUnused          = "dynamodb-table-unused"
+	RuleTableEmpty           = "dynamodb-table-empty"
+	RuleTableOverProvisioned = "dynamodb-table-over-provisioned"
+	RuleTableBillingMode     = "dynamodb-table-billing-mode"
+)
+
+const (
+	// DefaultLookback is how far back consumed capacity is checked by default
+	DefaultLookback = 14 * 24 * time.Hour
+	// DefaultPeriod is the period of the consumed capacity data points by default
+	DefaultPeriod = time.Hour
+	// DefaultStatistic is the statistic of consumed capacity compared to the min usage by default
+	DefaultStatistic = "Sum"
 )
 
 type Client struct {
 	DynamoDB   dynamodbiface.DynamoDBAPI
 	Cloudwatch cloudwatchiface.CloudWatchAPI
 	Pricing    pricingWaste.PricingInterface
+	// Concurrency is the maximum number of per-table API calls in flight
+	Concurrency int
+	// Metrics tune the consumed capacity compared to provisioned capacity, the defaults for
+	// zero fields. Capacity is always priced from its sum over each period, the statistic
+	// is only compared to the min usage to tell whether a table is unused.
+	Metrics util.MetricSettings
+}
+
+// capacityUsage is the provisioned and consumed capacity of a table or one of its global secondary indexes
+type capacityUsage struct {
+	// index is the name of the global secondary index, empty for the table itself
+	index            string
+	provisionedRead  float64
+	provisionedWrite float64
+	// peakRead and peakWrite are the highest average of units consumed per second over a
+	// period, shorter bursts aren't accounted for
+	peakRead  float64
+	peakWrite float64
+	// totalRead and totalWrite are the units consumed over the lookback window
+	totalRead  float64
+	totalWrite float64
+	// maxRead and maxWrite are the largest data points of the statistic of the metric settings
+	maxRead  float64
+	maxWrite float64
 }
 
 type DynamoDBTable struct {
 	r *dynamodb.TableDescription
+	// usage holds the capacity of the table followed by each of its global secondary indexes
+	usage []capacityUsage
+	// hours is the length of the window the capacity was consumed over
+	hours float64
+	// minUsage is the consumed capacity the table or one of its indexes must exceed to be used
+	minUsage float64
+	// evidence is the window capacity was checked over and the largest data points
+	evidence map[string]string
+	// recommendation is the cheapest billing mode for the consumed capacity
+	recommendation string
+	// pointInTimeRecovery, backupSizeBytes and streamReads are only described for unused tables
+	pointInTimeRecovery bool
+	backupSizeBytes     int64
+	// streamReads are the GetRecords requests made to the stream of the table over the window
+	streamReads float64
+	// tags are only listed for wasted tables
+	tags map[string]string
 }
 
 func (a DynamoDBTable) Type() string {
@@ -47,123 +146,312 @@ func (a DynamoDBTable) ID() string {
 	return aws.StringValue(a.r.TableName)
 }
 
+func (a DynamoDBTable) Tags() map[string]string {
+	return a.tags
+}
+
+// BillingMode returns the current billing mode of the table
+func (a DynamoDBTable) BillingMode() string {
+	if a.r.BillingModeSummary == nil || a.r.BillingModeSummary.BillingMode == nil {
+		return dynamodb.BillingModeProvisioned
+	}
+	return *a.r.BillingModeSummary.BillingMode
+}
+
+// TableClass returns the table class of the table, tables described without one are Standard
+func (a DynamoDBTable) TableClass() TableClass {
+	if a.r.TableClassSummary == nil || a.r.TableClassSummary.TableClass == nil {
+		return TableClassStandard
+	}
+	return TableClass(*a.r.TableClassSummary.TableClass)
+}
+
+// streamEnabled tells whether the table has a stream
+func (a DynamoDBTable) streamEnabled() bool {
+	return a.r.StreamSpecification != nil && aws.BoolValue(a.r.StreamSpecification.StreamEnabled) &&
+		a.r.LatestStreamLabel != nil
+}
+
+// replicated tells whether the table is a replica of a global table
+func (a DynamoDBTable) replicated() bool {
+	return len(a.r.Replicas) > 0
+}
+
+// facet returns the pricing facet of the table class of the table
+func (a DynamoDBTable) facet(facet DynamoPricingFacet) DynamoPricingFacet {
+	switch facet {
+	case TimedPITRStorageByteHours, TimedBackupStorageByteHours, StreamsReadRequestUnits:
+		return facet
+	}
+	if a.TableClass() == TableClassStandardInfrequentAccess {
+		return DynamoPricingFacet(infrequentAccessPrefix + string(facet))
+	}
+	return facet
+}
+
+// Recommendation returns the cheapest billing mode of the table, only set once the table is priced
+func (a DynamoDBTable) Recommendation() string {
+	return a.recommendation
+}
+
+// empty tells whether the table holds no items. DynamoDB refreshes the item count and
+// size of a table about every six hours, tables it hasn't described them for aren't empty.
+func (a DynamoDBTable) empty() bool {
+	return (a.r.ItemCount != nil && *a.r.ItemCount == 0) ||
+		(a.r.TableSizeBytes != nil && *a.r.TableSizeBytes == 0)
+}
+
+// unused tells whether the capacity consumed by the table and its indexes never exceeded
+// the min usage. Empty tables can still be used, e.g. as a queue whose items are deleted
+// as soon as they're read, so emptiness alone doesn't make a table unused.
+func (a DynamoDBTable) unused() bool {
+	for _, usage := range a.usage {
+		if usage.maxRead > a.minUsage || usage.maxWrite > a.minUsage {
+			return false
+		}
+	}
+	return true
+}
+
+// reason explains the waste of a priced table
+func (a DynamoDBTable) reason() util.Reason {
+	var provisionedRead, provisionedWrite, peakRead, peakWrite float64
+	for _, usage := range a.usage {
+		provisionedRead += usage.provisionedRead
+		provisionedWrite += usage.provisionedWrite
+		peakRead += usage.peakRead
+		peakWrite += usage.peakWrite
+	}
+	lookback := util.FormatDuration(time.Duration(a.hours * float64(time.Hour)))
+
+	switch {
+	case a.unused() && a.empty():
+		return util.Reason{
+			Rule:        RuleTableEmpty,
+			Explanation: fmt.Sprintf("%s holds no items and its consumed RCU and WCU never exceeded %g per period over %s", a.ID(), a.minUsage, lookback),
+		}
+	case a.unused() && a.minUsage > 0:
+		return util.Reason{
+			Rule:        RuleTableUnused,
+			Explanation: fmt.Sprintf("consumed RCU and WCU never exceeded %g per period over %s", a.minUsage, lookback),
+		}
+	case a.unused():
+		return util.Reason{
+			Rule:        RuleTableUnused,
+			Explanation: fmt.Sprintf("0 consumed RCU and WCU over %s", lookback),
+		}
+	case a.recommendation != a.BillingMode():
+		return util.Reason{
+			Rule:        RuleTableBillingMode,
+			Explanation: fmt.Sprintf("%s billing is cheaper than %s for a peak of %.2f RCU and %.2f WCU over %s", a.recommendation, a.BillingMode(), peakRead, peakWrite, lookback),
+		}
+	}
+	return util.Reason{
+		Rule:        RuleTableOverProvisioned,
+		Explanation: fmt.Sprintf("%g RCU and %g WCU provisioned for a peak of %.2f RCU and %.2f WCU over %s", provisionedRead, provisionedWrite, peakRead, peakWrite, lookback),
+	}
+}
+
+// addEvidence records the description of a priced table along with the evidence of its consumed capacity
+func (a *DynamoDBTable) addEvidence() {
+	a.evidence[util.EvidenceItemCount] = strconv.FormatInt(aws.Int64Value(a.r.ItemCount), 10)
+	a.evidence[util.EvidenceTableSizeBytes] = strconv.FormatInt(aws.Int64Value(a.r.TableSizeBytes), 10)
+	a.evidence[util.EvidenceBillingMode] = a.BillingMode()
+	a.evidence[util.EvidenceRecommendation] = a.recommendation
+
+	if a.unused() {
+		status := dynamodb.PointInTimeRecoveryStatusDisabled
+		if a.pointInTimeRecovery {
+			status = dynamodb.PointInTimeRecoveryStatusEnabled
+		}
+		a.evidence[util.EvidencePointInTimeRecoveryStatus] = status
+		a.evidence[util.EvidenceBackupSizeBytes] = strconv.FormatInt(a.backupSizeBytes, 10)
+	}
+}
+
+// RegisterAnalyzers adds the DynamoDB analyzers to the registry
+func (client *Client) RegisterAnalyzers(registry *util.Registry) error {
+	return registry.Register(
+		util.NewAnalyzer(AnalyzerTable, analyzerService, client.AnalyzeDynamodBTableWaste),
+	)
+}
+
+// metrics returns the consumed capacity metric settings of the client, falling back to the defaults
+func (client *Client) metrics() util.MetricSettings {
+	return client.Metrics.WithDefaults(util.MetricSettings{
+		Lookback: DefaultLookback,
+		Period:   DefaultPeriod,
+		Stat:     DefaultStatistic,
+	})
+}
+
+// AnalyzeDynamodBTableWaste prices the capacity each table pays for beyond what it
+// consumed over the lookback window. Provisioned tables waste the capacity above their
+// peak consumption, or the difference with on-demand pricing when that's cheaper still,
+// and on-demand tables waste the difference with provisioning their peak consumption.
+// Tables whose consumed capacity never exceeded the min usage waste their whole
+// provisioned capacity, empty ones are reported as such.
 func (client *Client) AnalyzeDynamodBTableWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
 	pricing, err := client.GetDynamoDBTablePricing(ctx, region)
 	if err != nil {
 		return nil, err
 	}
 
-	unusedTables, err := client.GetUnusedDynamoDBTables(ctx)
+	tables, err := client.GetDynamoDBTables(ctx)
+	resourceErrs, err := util.SplitResourceErrors(err)
 	if err != nil {
 		return nil, err
 	}
 
 	var wastedResources []util.AWSWastedResource
 
-	for _, unusedResource := range unusedTables {
-		unusedTable, ok := unusedResource.R.(*DynamoDBTable)
+	for _, resource := range tables {
+		table, ok := resource.R.(*DynamoDBTable)
 		if !ok {
 			return nil, util.PricingError
 		}
 
-		// TODO: account for other things like storage, streams, etc...
-		if unusedTable.r.BillingModeSummary == nil ||
-			(unusedTable.r.BillingModeSummary.BillingMode != nil &&
-				*unusedTable.r.BillingModeSummary.BillingMode == "PROVISIONED") {
-			readUnits := *unusedTable.r.ProvisionedThroughput.ReadCapacityUnits
-			writeUnits := *unusedTable.r.ProvisionedThroughput.WriteCapacityUnits
+		rate, err := table.wastedRate(pricing)
+		if err != nil {
+			resourceErrs = resourceErrs.Append(table.ID(), util.NewResourceError(table.ID(), "GetProducts", err))
+			continue
+		}
+		if rate <= 0 {
+			continue
+		}
+		table.addEvidence()
+
+		// The waste is still reported without its tags
+		table.tags, err = client.getTags(ctx, table.r.TableArn)
+		if err != nil {
+			resourceErrs = resourceErrs.Append(table.ID(), util.NewResourceError(table.ID(), "ListTagsOfResource", err))
+		}
 
-			rate := pricing[ReadCapacityUnitHours].Rate*float64(readUnits) +
-				pricing[WriteCapacityUnitHours].Rate*float64(writeUnits)
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: resource,
+			Price: util.Price{
+				Unit: "Hr",
+				Rate: rate,
+			},
+			Reason:   table.reason(),
+			Evidence: table.evidence,
+		})
+	}
 
-			wastedResources = append(wastedResources, util.AWSWastedResource{
-				Resource: unusedResource,
-				Price: util.Price{
-					Unit: "Hr",
-					Rate: rate,
-				},
-			})
+	return wastedResources, resourceErrs.ErrorOrNil()
+}
+
+// wastedRate returns the hourly rate paid beyond the cheapest billing mode for the
+// consumed capacity, and records that billing mode as the recommendation. Unused tables
+// also waste their storage, point-in-time recovery, backups and the reads of their stream.
+func (a *DynamoDBTable) wastedRate(pricing map[DynamoPricingFacet]*util.Price) (float64, error) {
+	rate := func(facet DynamoPricingFacet) (float64, error) {
+		price, ok := pricing[a.facet(facet)]
+		if !ok {
+			return 0, errors.Wrap(util.PricingError, string(a.facet(facet)))
+		}
+		return price.Rate, nil
+	}
+
+	// Writes to global tables are replicated to every replica
+	writeCapacityFacet, writeRequestFacet := WriteCapacityUnitHours, WriteRequestUnits
+	if a.replicated() {
+		writeCapacityFacet, writeRequestFacet = ReplWriteCapacityUnitHours, ReplWriteRequestUnits
+	}
+
+	rates := make(map[DynamoPricingFacet]float64)
+	for _, facet := range []DynamoPricingFacet{ReadCapacityUnitHours, writeCapacityFacet, ReadRequestUnits, writeRequestFacet} {
+		r, err := rate(facet)
+		if err != nil {
+			return 0, err
+		}
+		rates[facet] = r
+	}
+
+	var provisioned, rightSized, onDemand float64
+
+	for _, usage := range a.usage {
+		provisioned += usage.provisionedRead*rates[ReadCapacityUnitHours] +
+			usage.provisionedWrite*rates[writeCapacityFacet]
+
+		rightSized += rightSize(usage.peakRead)*rates[ReadCapacityUnitHours] +
+			rightSize(usage.peakWrite)*rates[writeCapacityFacet]
+
+		if a.hours > 0 {
+			onDemand += (usage.totalRead*rates[ReadRequestUnits] +
+				usage.totalWrite*rates[writeRequestFacet]) / a.hours
+		}
+	}
+
+	var wasted float64
+	if a.BillingMode() == dynamodb.BillingModePayPerRequest {
+		if rightSized < onDemand {
+			a.recommendation = dynamodb.BillingModeProvisioned
+			wasted = onDemand - rightSized
 		} else {
-			wastedResources = append(wastedResources, util.AWSWastedResource{
-				Resource: unusedResource,
-				Price: util.Price{
-					Unit: "Hr",
-					Rate: 0.0,
-				},
-			})
+			a.recommendation = dynamodb.BillingModePayPerRequest
 		}
+	} else if a.unused() || onDemand < rightSized {
+		a.recommendation = dynamodb.BillingModePayPerRequest
+		wasted = provisioned - onDemand
+	} else {
+		a.recommendation = dynamodb.BillingModeProvisioned
+		wasted = provisioned - rightSized
 	}
 
-	return wastedResources, nil
+	if !a.unused() {
+		return wasted, nil
+	}
+
+	storageRate, err := rate(TimedStorageByteHours)
+	if err != nil {
+		return 0, err
+	}
+	tableSize := float64(aws.Int64Value(a.r.TableSizeBytes))
+	monthly := tableSize * storageRate
+
+	// Point-in-time recovery is billed on the size of the table
+	if a.pointInTimeRecovery {
+		pitrRate, err := rate(TimedPITRStorageByteHours)
+		if err != nil {
+			return 0, err
+		}
+		monthly += tableSize * pitrRate
+	}
+
+	if a.backupSizeBytes > 0 {
+		backupRate, err := rate(TimedBackupStorageByteHours)
+		if err != nil {
+			return 0, err
+		}
+		monthly += float64(a.backupSizeBytes) * backupRate
+	}
+
+	// Nothing is written to the stream of an unused table, its consumers still poll it
+	if a.streamReads > 0 && a.hours > 0 {
+		streamRate, err := rate(StreamsReadRequestUnits)
+		if err != nil {
+			return 0, err
+		}
+		wasted += a.streamReads * streamRate / a.hours
+	}
+
+	return wasted + monthly/bytesPerGB/util.HoursPerMonth, nil
+}
+
+// rightSize returns the capacity to provision for a peak consumption, in whole units
+// and at least one unit as DynamoDB requires
+func rightSize(peak float64) float64 {
+	return math.Max(1, math.Ceil(peak))
 }
 
-func (client *Client) GetUnusedDynamoDBTables(ctx context.Context) ([]util.AWSResourceObject, error) {
-	var unusedTables []util.AWSResourceObject
+// GetDynamoDBTables returns every table with its capacity usage
+func (client *Client) GetDynamoDBTables(ctx context.Context) ([]util.AWSResourceObject, error) {
+	var tableNames []*string
 
 	err := client.DynamoDB.ListTablesPagesWithContext(ctx, &dynamodb.ListTablesInput{},
 		func(page *dynamodb.ListTablesOutput, lastPage bool) bool {
-			for _, tableName := range page.TableNames {
-				tableOutput, err := client.DynamoDB.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
-					TableName: tableName,
-				})
-				// TODO: do somethign with errors in individual items
-				if err != nil {
-					continue
-				}
-
-				table := tableOutput.Table
-
-				if *table.ItemCount == 0 || *table.TableSizeBytes == 0 {
-					unusedTables = append(unusedTables, util.AWSResourceObject{R: &DynamoDBTable{table}})
-				}
-
-				startTime := time.Now().Add(-24 * 14 * time.Hour)
-				endTime := time.Now()
-
-				tableMetrics, err := client.Cloudwatch.GetMetricDataWithContext(ctx, &cloudwatch.GetMetricDataInput{
-					StartTime: &startTime,
-					EndTime:   &endTime,
-					MetricDataQueries: []*cloudwatch.MetricDataQuery{
-						{
-							Id: aws.String("readcapacity"),
-							MetricStat: &cloudwatch.MetricStat{
-								Period: aws.Int64(5 * 60),
-								Stat:   aws.String("Average"),
-								Metric: &cloudwatch.Metric{
-									MetricName: aws.String("ConsumedReadCapacityUnits"),
-									Namespace:  aws.String("AWS/DynamoDB"),
-									Dimensions: []*cloudwatch.Dimension{
-										{
-											Name:  aws.String("TableName"),
-											Value: table.TableName,
-										},
-									},
-								},
-							},
-						},
-					},
-				})
-				// TODO: do something with errors in individual items
-				if err != nil {
-					continue
-				}
-
-				used := false
-
-				for _, result := range tableMetrics.MetricDataResults {
-					for _, value := range result.Values {
-						// NOTE: Just looking at the table in the console will make it look used
-						if *value != 0.0 {
-							used = true
-						}
-					}
-				}
-
-				if !used {
-					unusedTables = append(unusedTables, util.AWSResourceObject{R: &DynamoDBTable{table}})
-				}
-			}
-
+			tableNames = append(tableNames, page.TableNames...)
 			return true
 		})
 
@@ -171,7 +459,245 @@ func (client *Client) GetUnusedDynamoDBTables(ctx context.Context) ([]util.AWSRe
 		return nil, err
 	}
 
-	return unusedTables, nil
+	tables := make([]*DynamoDBTable, len(tableNames))
+	errs := make([]error, len(tableNames))
+
+	err = util.ForEach(ctx, client.Concurrency, len(tableNames), func(i int) {
+		tables[i], errs[i] = client.getDynamoDBTable(ctx, tableNames[i])
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []util.AWSResourceObject
+	var resourceErrs util.ResourceErrors
+	for i, table := range tables {
+		if errs[i] != nil {
+			resourceErrs = resourceErrs.Append(aws.StringValue(tableNames[i]), errs[i])
+			continue
+		}
+		resources = append(resources, util.AWSResourceObject{R: table})
+	}
+
+	return resources, resourceErrs.ErrorOrNil()
+}
+
+// getDynamoDBTable returns the table with its capacity usage
+func (client *Client) getDynamoDBTable(ctx context.Context, tableName *string) (*DynamoDBTable, error) {
+	tableOutput, err := client.DynamoDB.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: tableName,
+	})
+	if err != nil {
+		return nil, util.NewResourceError(aws.StringValue(tableName), "DescribeTable", err)
+	}
+
+	settings := client.metrics()
+	table := &DynamoDBTable{
+		r:        tableOutput.Table,
+		hours:    settings.Lookback.Hours(),
+		minUsage: settings.MinUsage,
+		evidence: make(map[string]string),
+	}
+
+	usage, err := client.getCapacityUsage(ctx, table, "", table.r.ProvisionedThroughput)
+	if err != nil {
+		return nil, util.NewResourceError(aws.StringValue(tableName), "GetMetricData", err)
+	}
+	table.usage = append(table.usage, *usage)
+
+	for _, index := range table.r.GlobalSecondaryIndexes {
+		usage, err := client.getCapacityUsage(ctx, table, aws.StringValue(index.IndexName), index.ProvisionedThroughput)
+		if err != nil {
+			return nil, util.NewResourceError(aws.StringValue(tableName), "GetMetricData", err)
+		}
+		table.usage = append(table.usage, *usage)
+	}
+
+	// Backups are only wasted along with the table when nothing uses it
+	if !table.unused() {
+		return table, nil
+	}
+
+	table.pointInTimeRecovery, err = client.hasPointInTimeRecovery(ctx, tableName)
+	if err != nil {
+		return nil, util.NewResourceError(aws.StringValue(tableName), "DescribeContinuousBackups", err)
+	}
+
+	table.backupSizeBytes, err = client.getBackupSizeBytes(ctx, tableName)
+	if err != nil {
+		return nil, util.NewResourceError(aws.StringValue(tableName), "ListBackups", err)
+	}
+
+	if table.streamEnabled() {
+		table.streamReads, err = client.getStreamReads(ctx, table)
+		if err != nil {
+			return nil, util.NewResourceError(aws.StringValue(tableName), "GetMetricData", err)
+		}
+	}
+
+	return table, nil
+}
+
+// getTags returns the tags of a table by key
+func (client *Client) getTags(ctx context.Context, tableArn *string) (map[string]string, error) {
+	tags := make(map[string]string)
+
+	input := &dynamodb.ListTagsOfResourceInput{
+		ResourceArn: tableArn,
+	}
+	for {
+		resp, err := client.DynamoDB.ListTagsOfResourceWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range resp.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+
+		if resp.NextToken == nil {
+			return tags, nil
+		}
+		input.NextToken = resp.NextToken
+	}
+}
+
+// hasPointInTimeRecovery tells whether point-in-time recovery is enabled on a table
+func (client *Client) hasPointInTimeRecovery(ctx context.Context, tableName *string) (bool, error) {
+	resp, err := client.DynamoDB.DescribeContinuousBackupsWithContext(ctx, &dynamodb.DescribeContinuousBackupsInput{
+		TableName: tableName,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if resp.ContinuousBackupsDescription == nil || resp.ContinuousBackupsDescription.PointInTimeRecoveryDescription == nil {
+		return false, nil
+	}
+
+	return aws.StringValue(resp.ContinuousBackupsDescription.PointInTimeRecoveryDescription.PointInTimeRecoveryStatus) ==
+		dynamodb.PointInTimeRecoveryStatusEnabled, nil
+}
+
+// getBackupSizeBytes returns the size of the on-demand backups of a table. System backups
+// are free and backups made with AWS Backup are billed by AWS Backup.
+func (client *Client) getBackupSizeBytes(ctx context.Context, tableName *string) (int64, error) {
+	var size int64
+
+	input := &dynamodb.ListBackupsInput{
+		TableName:  tableName,
+		BackupType: aws.String(dynamodb.BackupTypeFilterUser),
+	}
+
+	for {
+		resp, err := client.DynamoDB.ListBackupsWithContext(ctx, input)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, backup := range resp.BackupSummaries {
+			if aws.StringValue(backup.BackupStatus) == dynamodb.BackupStatusDeleted {
+				continue
+			}
+			size += aws.Int64Value(backup.BackupSizeBytes)
+		}
+
+		if resp.LastEvaluatedBackupArn == nil {
+			return size, nil
+		}
+		input.ExclusiveStartBackupArn = resp.LastEvaluatedBackupArn
+	}
+}
+
+// getStreamReads returns the GetRecords requests made to the stream of a table over the
+// lookback window, from the sample count of their latency, and records its evidence
+func (client *Client) getStreamReads(ctx context.Context, table *DynamoDBTable) (float64, error) {
+	settings := client.metrics()
+
+	query := util.MetricQuery{
+		ID:         "streamreads",
+		Namespace:  "AWS/DynamoDB",
+		MetricName: "SuccessfulRequestLatency",
+		Dimensions: map[string]string{
+			"TableName":   table.ID(),
+			"StreamLabel": aws.StringValue(table.r.LatestStreamLabel),
+			"Operation":   "GetRecords",
+		},
+		Stat:     "SampleCount",
+		Period:   settings.Period,
+		Lookback: settings.Lookback,
+	}
+	result, err := util.GetMetric(ctx, client.Cloudwatch, query)
+	if err != nil {
+		return 0, err
+	}
+	result.AddEvidence(table.evidence, "GetRecords.SuccessfulRequestLatency", query)
+
+	return result.Sum, nil
+}
+
+// getCapacityUsage returns the provisioned and consumed capacity of a table, or of one
+// of its global secondary indexes if index isn't empty, and records its evidence
+func (client *Client) getCapacityUsage(ctx context.Context, table *DynamoDBTable, index string, throughput *dynamodb.ProvisionedThroughputDescription) (*capacityUsage, error) {
+	settings := client.metrics()
+	usage := &capacityUsage{index: index}
+
+	if throughput != nil {
+		usage.provisionedRead = float64(aws.Int64Value(throughput.ReadCapacityUnits))
+		usage.provisionedWrite = float64(aws.Int64Value(throughput.WriteCapacityUnits))
+	}
+
+	dimensions := map[string]string{"TableName": table.ID()}
+	if index != "" {
+		dimensions["GlobalSecondaryIndexName"] = index
+	}
+
+	for _, metric := range []struct {
+		name  string
+		peak  *float64
+		total *float64
+		max   *float64
+	}{
+		{"ConsumedReadCapacityUnits", &usage.peakRead, &usage.totalRead, &usage.maxRead},
+		{"ConsumedWriteCapacityUnits", &usage.peakWrite, &usage.totalWrite, &usage.maxWrite},
+	} {
+		// NOTE: Just looking at the table in the console will make it look used
+		query := util.MetricQuery{
+			ID:         strings.ToLower(strings.TrimPrefix(metric.name, "Consumed")),
+			Namespace:  "AWS/DynamoDB",
+			MetricName: metric.name,
+			Dimensions: dimensions,
+			Stat:       "Sum",
+			Period:     settings.Period,
+			Lookback:   settings.Lookback,
+		}
+		result, err := util.GetMetric(ctx, client.Cloudwatch, query)
+		if err != nil {
+			return nil, err
+		}
+
+		*metric.peak = result.Max / settings.Period.Seconds()
+		*metric.total = result.Sum
+
+		// Other statistics take a query of their own
+		if settings.Stat != query.Stat {
+			query.Stat = settings.Stat
+			result, err = util.GetMetric(ctx, client.Cloudwatch, query)
+			if err != nil {
+				return nil, err
+			}
+		}
+		*metric.max = result.Max
+
+		name := metric.name
+		if index != "" {
+			name = index + "." + name
+		}
+		result.AddEvidence(table.evidence, name, query)
+	}
+
+	return usage, nil
 }
 
 func (client *Client) GetDynamoDBTablePricing(ctx context.Context, region string) (map[DynamoPricingFacet]*util.Price, error) {
@@ -187,44 +713,47 @@ func (client *Client) GetDynamoDBTablePricing(ctx context.Context, region string
 	dynamoDBPricing := make(map[DynamoPricingFacet]*util.Price)
 
 	for _, pricingItem := range pricing {
-		usageType := pricingItem.Product.Attributes.UsageType
-
-		if strings.Contains(usageType, string(ReadCapacityUnitHours)) {
-			for _, term := range pricingItem.Terms.OnDemand {
-				for _, priceDimension := range term.PriceDimensions {
-					if priceDimension.EndRange == "Inf" {
-						rate, err := strconv.ParseFloat(priceDimension.PricePerUnit.USD, 64)
-						if err != nil {
-							return nil, err
-						}
-
-						dynamoDBPricing[ReadCapacityUnitHours] = &util.Price{
-							Rate: rate,
-							Unit: "Hr",
-						}
-					}
-				}
-			}
-		} else if strings.Contains(usageType, string(WriteCapacityUnitHours)) &&
-			!strings.Contains(usageType, string(ReplWriteCapacityUnitHours)) {
-			for _, term := range pricingItem.Terms.OnDemand {
-				for _, priceDimension := range term.PriceDimensions {
-					if priceDimension.EndRange == "Inf" {
-						rate, err := strconv.ParseFloat(priceDimension.PricePerUnit.USD, 64)
-						if err != nil {
-							return nil, err
-						}
-
-						dynamoDBPricing[WriteCapacityUnitHours] = &util.Price{
-							Rate: rate,
-							Unit: "Hr",
-						}
-					}
-				}
-			}
+		facet, ok := matchFacet(pricingItem.Product.Attributes.UsageType)
+		if !ok {
+			continue
+		}
+
+		// Price past the free tier
+		priceDimension, err := pricingItem.UnboundedDimension()
+		if errors.Is(err, pricingWaste.NoPriceDimensionError) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rate, err := priceDimension.Rate()
+		if err != nil {
+			return nil, err
 		}
 
+		dynamoDBPricing[facet] = &util.Price{
+			Rate: rate,
+			Unit: facetUnits[DynamoPricingFacet(strings.TrimPrefix(string(facet), infrequentAccessPrefix))],
+		}
 	}
 
 	return dynamoDBPricing, nil
 }
+
+// matchFacet returns the pricing facet of a usage type. Usage types are prefixed with
+// the region outside of us-east-1, e.g. USE2-IA-ReadCapacityUnit-Hrs, so the longest
+// facet a usage type ends with is taken, the Standard-IA facets over the Standard ones
+// and Streams-ReadRequestUnits over ReadRequestUnits.
+func matchFacet(usageType string) (DynamoPricingFacet, bool) {
+	var match string
+	for _, prefix := range []string{infrequentAccessPrefix, ""} {
+		for facet := range facetUnits {
+			f := prefix + string(facet)
+			if (usageType == f || strings.HasSuffix(usageType, "-"+f)) && len(f) > len(match) {
+				match = f
+			}
+		}
+	}
+	return DynamoPricingFacet(match), match != ""
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
@@ -17,6 +19,7 @@ import (
 
 	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
 	pricingTest "github.com/cloudwaste/cloudwaste/pkg/aws/pricing/test"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
 )
 
 type DynamoDBTestSuite struct {
@@ -32,74 +35,139 @@ func (suite *DynamoDBTestSuite) SetupTest() {
 	suite.client = Client{Pricing: suite.mockedPricing}
 }
 
-type mockData struct {
-	Table   *dynamodb.DescribeTableOutput
-	Metrics *cloudwatch.GetMetricDataOutput
+// hourly repeats the units consumed in each hour of the lookback window
+func hourly(units float64) []float64 {
+	return []float64{units, units}
 }
 
-var tables = map[string]mockData{
-	"table1": { // used
-		Table: &dynamodb.DescribeTableOutput{
-			Table: &dynamodb.TableDescription{
-				TableName:      aws.String("table1"),
-				ItemCount:      aws.Int64(2),
-				TableSizeBytes: aws.Int64(30),
-			},
-		},
-		Metrics: &cloudwatch.GetMetricDataOutput{
-			MetricDataResults: []*cloudwatch.MetricDataResult{{
-				Id:     aws.String("readcapacity"),
-				Values: aws.Float64Slice([]float64{0.0, 1.0}),
-			}},
-		},
+// throughput returns the provisioned throughput of a table or index
+func throughput(read int64, write int64) *dynamodb.ProvisionedThroughputDescription {
+	return &dynamodb.ProvisionedThroughputDescription{
+		ReadCapacityUnits:  aws.Int64(read),
+		WriteCapacityUnits: aws.Int64(write),
+	}
+}
+
+func payPerRequest() *dynamodb.BillingModeSummary {
+	return &dynamodb.BillingModeSummary{BillingMode: aws.String(dynamodb.BillingModePayPerRequest)}
+}
+
+var tables = map[string]*dynamodb.TableDescription{
+	// provisioned for its peak consumption
+	"table1": {TableName: aws.String("table1"), ProvisionedThroughput: throughput(5, 5)},
+	// only written to, provisioned for its peak consumption
+	"table2": {TableName: aws.String("table2"), ProvisionedThroughput: throughput(1, 5)},
+	// over-provisioned, with an unused global secondary index
+	"table3": {
+		TableName:             aws.String("table3"),
+		ProvisionedThroughput: throughput(100, 100),
+		GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{{
+			IndexName:             aws.String("index1"),
+			ProvisionedThroughput: throughput(10, 10),
+		}},
 	},
-	"table2": { // unused
-		Table: &dynamodb.DescribeTableOutput{
-			Table: &dynamodb.TableDescription{
-				TableName:      aws.String("table2"),
-				ItemCount:      aws.Int64(4),
-				TableSizeBytes: aws.Int64(0),
-			},
-		},
-		Metrics: &cloudwatch.GetMetricDataOutput{
-			MetricDataResults: []*cloudwatch.MetricDataResult{{
-				Id:     aws.String("readcapacity"),
-				Values: aws.Float64Slice([]float64{0.0, 1.0}),
-			}},
-		},
+	// unused, kept for disaster recovery
+	"table4": {
+		TableName:             aws.String("table4"),
+		TableArn:              aws.String("arn:aws:dynamodb:us-east-1:123456789012:table/table4"),
+		ProvisionedThroughput: throughput(5, 5),
 	},
-	"table3": { // unused
-		Table: &dynamodb.DescribeTableOutput{
-			Table: &dynamodb.TableDescription{
-				TableName:      aws.String("table3"),
-				ItemCount:      aws.Int64(0),
-				TableSizeBytes: aws.Int64(4),
-			},
+	// on-demand with steady reads
+	"table5": {TableName: aws.String("table5"), BillingModeSummary: payPerRequest(), ProvisionedThroughput: throughput(0, 0)},
+	// on-demand with few reads
+	"table6": {TableName: aws.String("table6"), BillingModeSummary: payPerRequest(), ProvisionedThroughput: throughput(0, 0)},
+	// unused on-demand, with point-in-time recovery, backups and a polled stream
+	"table7": {
+		TableName:             aws.String("table7"),
+		BillingModeSummary:    payPerRequest(),
+		ProvisionedThroughput: throughput(0, 0),
+		TableSizeBytes:        aws.Int64(10 * gb),
+		StreamSpecification:   &dynamodb.StreamSpecification{StreamEnabled: aws.Bool(true)},
+		LatestStreamLabel:     aws.String("2021-01-01T00:00:00.000"),
+	},
+	// replica of a global table with over-provisioned writes
+	"table8": {
+		TableName:             aws.String("table8"),
+		ProvisionedThroughput: throughput(1, 10),
+		Replicas:              []*dynamodb.ReplicaDescription{{RegionName: aws.String("us-west-2")}},
+	},
+	// unused on-demand of the Standard-IA table class
+	"table9": {
+		TableName:             aws.String("table9"),
+		BillingModeSummary:    payPerRequest(),
+		ProvisionedThroughput: throughput(0, 0),
+		TableSizeBytes:        aws.Int64(10 * gb),
+		TableClassSummary:     &dynamodb.TableClassSummary{TableClass: aws.String(dynamodb.TableClassStandardInfrequentAccess)},
+	},
+	// empty and unused
+	"table10": {
+		TableName:             aws.String("table10"),
+		ProvisionedThroughput: throughput(5, 5),
+		ItemCount:             aws.Int64(0),
+		TableSizeBytes:        aws.Int64(0),
+	},
+	// empty, with its capacity consumed, e.g. a queue
+	"table11": {
+		TableName:             aws.String("table11"),
+		ProvisionedThroughput: throughput(5, 5),
+		ItemCount:             aws.Int64(0),
+		TableSizeBytes:        aws.Int64(0),
+	},
+}
+
+const gb = 1024 * 1024 * 1024
+
+// pointInTimeRecovery lists the tables with point-in-time recovery enabled
+var pointInTimeRecovery = map[string]bool{
+	"table7": true,
+}
+
+// tags are the pages of tags of each table by ARN
+var tags = map[string][]*dynamodb.ListTagsOfResourceOutput{
+	"arn:aws:dynamodb:us-east-1:123456789012:table/table4": {
+		{
+			Tags:      []*dynamodb.Tag{{Key: aws.String("env"), Value: aws.String("dr")}},
+			NextToken: aws.String("page2"),
 		},
-		Metrics: &cloudwatch.GetMetricDataOutput{
-			MetricDataResults: []*cloudwatch.MetricDataResult{{
-				Id:     aws.String("readcapacity"),
-				Values: aws.Float64Slice([]float64{0.0, 1.0}),
-			}},
+		{
+			Tags: []*dynamodb.Tag{{Key: aws.String("team"), Value: aws.String("payments")}},
 		},
 	},
-	"table4": { // unused
-		Table: &dynamodb.DescribeTableOutput{
-			Table: &dynamodb.TableDescription{
-				TableName:      aws.String("table4"),
-				ItemCount:      aws.Int64(4),
-				TableSizeBytes: aws.Int64(4),
+}
+
+// backups are the pages of backups of each table
+var backups = map[string][]*dynamodb.ListBackupsOutput{
+	"table7": {
+		{
+			BackupSummaries: []*dynamodb.BackupSummary{
+				{BackupStatus: aws.String(dynamodb.BackupStatusAvailable), BackupSizeBytes: aws.Int64(3 * gb)},
 			},
+			LastEvaluatedBackupArn: aws.String("page2"),
 		},
-		Metrics: &cloudwatch.GetMetricDataOutput{
-			MetricDataResults: []*cloudwatch.MetricDataResult{{
-				Id:     aws.String("readcapacity"),
-				Values: aws.Float64Slice([]float64{0.0, 0.0}),
-			}},
+		{
+			BackupSummaries: []*dynamodb.BackupSummary{
+				{BackupStatus: aws.String(dynamodb.BackupStatusDeleted), BackupSizeBytes: aws.Int64(1 * gb)},
+				{BackupStatus: aws.String(dynamodb.BackupStatusAvailable), BackupSizeBytes: aws.Int64(2 * gb)},
+			},
 		},
 	},
 }
 
+// metrics are the units consumed each hour, by table, index and metric name
+var metrics = map[string][]float64{
+	"table1//ConsumedReadCapacityUnits":   hourly(5 * 3600),
+	"table1//ConsumedWriteCapacityUnits":  hourly(5 * 3600),
+	"table2//ConsumedWriteCapacityUnits":  hourly(5 * 3600),
+	"table3//ConsumedReadCapacityUnits":   hourly(10 * 3600),
+	"table3//ConsumedWriteCapacityUnits":  hourly(10 * 3600),
+	"table5//ConsumedReadCapacityUnits":   hourly(10 * 3600),
+	"table6//ConsumedReadCapacityUnits":   {10, 0},
+	"table8//ConsumedWriteCapacityUnits":  hourly(1 * 3600),
+	"table7//SuccessfulRequestLatency":    {100, 200},
+	"table11//ConsumedReadCapacityUnits":  hourly(5 * 3600),
+	"table11//ConsumedWriteCapacityUnits": hourly(5 * 3600),
+}
+
 type mockedDynamoDB struct {
 	mock.Mock
 	dynamodbiface.DynamoDBAPI
@@ -122,18 +190,74 @@ func (m *mockedDynamoDB) ListTablesPagesWithContext(ctx context.Context, input *
 func (m *mockedDynamoDB) DescribeTableWithContext(ctx context.Context, input *dynamodb.DescribeTableInput, options ...request.Option) (*dynamodb.DescribeTableOutput, error) {
 	args := m.Called(ctx, input, options)
 
-	return tables[*input.TableName].Table, args.Error(0)
+	return &dynamodb.DescribeTableOutput{Table: tables[*input.TableName]}, args.Error(0)
 }
 
-func (m *mockedCloudwatch) GetMetricDataWithContext(ctx context.Context, input *cloudwatch.GetMetricDataInput, options ...request.Option) (*cloudwatch.GetMetricDataOutput, error) {
+func (m *mockedDynamoDB) DescribeContinuousBackupsWithContext(ctx context.Context, input *dynamodb.DescribeContinuousBackupsInput, options ...request.Option) (*dynamodb.DescribeContinuousBackupsOutput, error) {
 	args := m.Called(ctx, input, options)
 
-	return tables[*input.MetricDataQueries[0].MetricStat.Metric.Dimensions[0].Value].Metrics, args.Error(0)
+	status := dynamodb.PointInTimeRecoveryStatusDisabled
+	if pointInTimeRecovery[*input.TableName] {
+		status = dynamodb.PointInTimeRecoveryStatusEnabled
+	}
+
+	return &dynamodb.DescribeContinuousBackupsOutput{
+		ContinuousBackupsDescription: &dynamodb.ContinuousBackupsDescription{
+			PointInTimeRecoveryDescription: &dynamodb.PointInTimeRecoveryDescription{
+				PointInTimeRecoveryStatus: aws.String(status),
+			},
+		},
+	}, args.Error(0)
 }
 
-func TestGetUnusedDynamoDBTables(t *testing.T) {
-	assert := assert.New(t)
+func (m *mockedDynamoDB) ListBackupsWithContext(ctx context.Context, input *dynamodb.ListBackupsInput, options ...request.Option) (*dynamodb.ListBackupsOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	pages := backups[*input.TableName]
+	if len(pages) == 0 {
+		return &dynamodb.ListBackupsOutput{}, args.Error(0)
+	}
+	if input.ExclusiveStartBackupArn != nil {
+		return pages[1], args.Error(0)
+	}
+	return pages[0], args.Error(0)
+}
+
+func (m *mockedDynamoDB) ListTagsOfResourceWithContext(ctx context.Context, input *dynamodb.ListTagsOfResourceInput, options ...request.Option) (*dynamodb.ListTagsOfResourceOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	pages := tags[aws.StringValue(input.ResourceArn)]
+	if len(pages) == 0 {
+		return &dynamodb.ListTagsOfResourceOutput{}, args.Error(0)
+	}
+	if input.NextToken != nil {
+		return pages[1], args.Error(0)
+	}
+	return pages[0], args.Error(0)
+}
+
+func (m *mockedCloudwatch) GetMetricDataWithContext(ctx context.Context, input *cloudwatch.GetMetricDataInput, options ...request.Option) (*cloudwatch.GetMetricDataOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	metric := input.MetricDataQueries[0].MetricStat.Metric
+	var table, index string
+	for _, dimension := range metric.Dimensions {
+		switch aws.StringValue(dimension.Name) {
+		case "TableName":
+			table = aws.StringValue(dimension.Value)
+		case "GlobalSecondaryIndexName":
+			index = aws.StringValue(dimension.Value)
+		}
+	}
+
+	values := metrics[table+"/"+index+"/"+aws.StringValue(metric.MetricName)]
+	return &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []*cloudwatch.MetricDataResult{{Values: aws.Float64Slice(values)}},
+	}, args.Error(0)
+}
 
+// mockTables lists and describes every table and its consumed capacity
+func mockTables(client *Client) {
 	tableNames := make([]*string, 0, len(tables))
 	for k := range tables {
 		tableNames = append(tableNames, aws.String(k))
@@ -147,39 +271,287 @@ func TestGetUnusedDynamoDBTables(t *testing.T) {
 		}, nil)
 	md.On("DescribeTableWithContext", mock.Anything, mock.Anything, mock.Anything).
 		Return(nil)
+	md.On("DescribeContinuousBackupsWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	md.On("ListBackupsWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	md.On("ListTagsOfResourceWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
 	mc.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
 		Return(nil)
 
-	client := Client{DynamoDB: md, Cloudwatch: mc}
-	unusedTables, err := client.GetUnusedDynamoDBTables(context.Background())
+	client.DynamoDB = md
+	client.Cloudwatch = mc
+	client.Metrics.Lookback = 2 * time.Hour
+}
+
+func TestGetDynamoDBTables(t *testing.T) {
+	assert := assert.New(t)
 
-	assert.Equal(3, len(unusedTables))
+	client := Client{}
+	mockTables(&client)
+	resources, err := client.GetDynamoDBTables(context.Background())
+	assert.Nil(err)
+	assert.Equal(len(tables), len(resources))
 
-	unusedTableNames := make([]string, 0, len(unusedTables))
-	for _, unusedTable := range unusedTables {
-		unusedTableNames = append(unusedTableNames, unusedTable.R.ID())
+	for _, resource := range resources {
+		assert.Equal(tableType, resource.R.Type())
+
+		table := resource.R.(*DynamoDBTable)
+		switch table.ID() {
+		case "table2":
+			assert.False(table.unused())
+			assert.Equal(0.0, table.usage[0].totalRead)
+			assert.Equal(5.0*3600*2, table.usage[0].totalWrite)
+		case "table3":
+			if assert.Equal(2, len(table.usage)) {
+				assert.Equal(10.0, table.usage[0].peakRead)
+				assert.Equal("index1", table.usage[1].index)
+				assert.Equal(10.0, table.usage[1].provisionedRead)
+				assert.Equal(0.0, table.usage[1].peakRead)
+			}
+			assert.Equal("36000", table.evidence["ConsumedReadCapacityUnits.Sum.max"])
+			assert.Equal("0", table.evidence["index1.ConsumedReadCapacityUnits.Sum.max"])
+			assert.Equal("1h", table.evidence[util.EvidencePeriod])
+		case "table4":
+			assert.True(table.unused())
+		case "table7":
+			assert.True(table.pointInTimeRecovery)
+			assert.Equal(int64(5*gb), table.backupSizeBytes)
+			assert.Equal(300.0, table.streamReads)
+		case "table9":
+			assert.Equal(TableClassStandardInfrequentAccess, table.TableClass())
+		case "table10":
+			assert.True(table.unused())
+		case "table11":
+			// Empty tables are only unused when they don't consume capacity either
+			assert.False(table.unused())
+		}
 	}
 
-	assert.NotContains(unusedTableNames, "table1")
-	assert.Contains(unusedTableNames, "table2")
-	assert.Contains(unusedTableNames, "table3")
-	assert.Contains(unusedTableNames, "table4")
-	assert.Nil(err)
+	// Tables that can't be described are reported without failing the others
+	md := new(mockedDynamoDB)
+	md.On("ListTablesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&dynamodb.ListTablesOutput{TableNames: aws.StringSlice([]string{"table1"})}, nil)
+	md.On("DescribeTableWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(awserr.New(dynamodb.ErrCodeResourceNotFoundException, "not found", nil))
 
-	assert.Equal(tableType, unusedTables[0].R.Type())
+	client.DynamoDB = md
+	resources, err = client.GetDynamoDBTables(context.Background())
+	assert.Equal(0, len(resources))
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("table1", resourceErrs[0].ResourceID)
+		assert.Equal("DescribeTable", resourceErrs[0].Operation)
+		assert.Equal(dynamodb.ErrCodeResourceNotFoundException, resourceErrs[0].Code)
+	}
 
 	// Test error cases
 	md = new(mockedDynamoDB)
-	mc = new(mockedCloudwatch)
+	mc := new(mockedCloudwatch)
 	md.On("ListTablesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
 		Return(nil, errors.New("failed"))
 
 	client = Client{DynamoDB: md, Cloudwatch: mc}
-	unusedTables, err = client.GetUnusedDynamoDBTables(context.Background())
-	assert.Nil(unusedTables)
+	resources, err = client.GetDynamoDBTables(context.Background())
+	assert.Nil(resources)
 	assert.NotNil(err)
 }
 
+func TestGetDynamoDBTablesMinUsage(t *testing.T) {
+	assert := assert.New(t)
+
+	// table2 writes 18000 units an hour
+	client := Client{}
+	mockTables(&client)
+	client.Metrics.MinUsage = 18000
+	client.Metrics.Stat = "Maximum"
+
+	resources, err := client.GetDynamoDBTables(context.Background())
+	assert.Nil(err)
+
+	for _, resource := range resources {
+		table := resource.R.(*DynamoDBTable)
+		if table.ID() != "table2" {
+			continue
+		}
+
+		assert.True(table.unused())
+		// Capacity is still priced from its sum
+		assert.Equal(5.0*3600*2, table.usage[0].totalWrite)
+		assert.Equal("18000", table.evidence["ConsumedWriteCapacityUnits.Maximum.max"])
+	}
+
+	// Other statistics than the sum take a query of their own
+	var stats []string
+	for _, call := range client.Cloudwatch.(*mockedCloudwatch).Calls {
+		input := call.Arguments.Get(1).(*cloudwatch.GetMetricDataInput)
+		stats = append(stats, aws.StringValue(input.MetricDataQueries[0].MetricStat.Stat))
+	}
+	assert.Contains(stats, "Sum")
+	assert.Contains(stats, "Maximum")
+}
+
+func newPriceItem(usageType string, usd string) *pricing.AWSPriceItem {
+	return &pricing.AWSPriceItem{
+		Product: pricing.AWSPriceItemProduct{
+			Attributes: pricing.AWSPriceItemProductAttributes{UsageType: usageType},
+		},
+		Terms: pricing.AWSPriceItemTerms{
+			OnDemand: map[string]pricing.AWSPriceItemOnDemand{
+				"1": {PriceDimensions: map[string]pricing.AWSPriceItemPriceDimension{
+					"1": {BeginRange: "0", EndRange: "Inf", PricePerUnit: pricing.AWSPriceItemPricePerUnit{USD: usd}},
+				}},
+			},
+		},
+	}
+}
+
+func (suite *DynamoDBTestSuite) TestAnalyzeDynamoDBTableWaste() {
+	assert := assert.New(suite.T())
+
+	suite.mockedPricing.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem("ReadCapacityUnit-Hrs", "0.00013"),
+			newPriceItem("WriteCapacityUnit-Hrs", "0.00065"),
+			newPriceItem("ReplWriteCapacityUnit-Hrs", "0.000975"),
+			newPriceItem("ReadRequestUnits", "0.00000025"),
+			newPriceItem("WriteRequestUnits", "0.00000125"),
+			newPriceItem("ReplWriteRequestUnits", "0.000001875"),
+			newPriceItem("TimedStorage-ByteHrs", "0.25"),
+			newPriceItem("IA-TimedStorage-ByteHrs", "0.10"),
+			newPriceItem("IA-ReadCapacityUnit-Hrs", "0.00016"),
+			newPriceItem("IA-WriteCapacityUnit-Hrs", "0.0008"),
+			newPriceItem("IA-ReadRequestUnits", "0.0000003"),
+			newPriceItem("IA-WriteRequestUnits", "0.0000015"),
+			newPriceItem("TimedPITRStorage-ByteHrs", "0.20"),
+			newPriceItem("TimedBackupStorage-ByteHrs", "0.10"),
+			newPriceItem("Streams-ReadRequestUnits", "0.0000002"),
+		}, nil)
+	mockTables(&suite.client)
+
+	wastedResources, err := suite.client.AnalyzeDynamodBTableWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+
+	rates := make(map[string]float64)
+	recommendations := make(map[string]string)
+	tableTags := make(map[string]map[string]string)
+	reasons := make(map[string]util.Reason)
+	evidence := make(map[string]map[string]string)
+	for _, wastedResource := range wastedResources {
+		reasons[wastedResource.Resource.R.ID()] = wastedResource.Reason
+		evidence[wastedResource.Resource.R.ID()] = wastedResource.Evidence
+		assert.Equal("Hr", wastedResource.Price.Unit)
+		rates[wastedResource.Resource.R.ID()] = wastedResource.Price.Rate
+		recommendations[wastedResource.Resource.R.ID()] = wastedResource.Resource.R.(*DynamoDBTable).Recommendation()
+		tableTags[wastedResource.Resource.R.ID()] = wastedResource.Resource.R.Tags()
+	}
+
+	assert.Equal(7, len(rates))
+	assert.NotContains(rates, "table1")
+	assert.NotContains(rates, "table2")
+	assert.NotContains(rates, "table6")
+
+	// Capacity above the peak consumption of the table and its index
+	assert.InDelta(99*(0.00013+0.00065), rates["table3"], 1e-9)
+	assert.Equal(dynamodb.BillingModeProvisioned, recommendations["table3"])
+
+	// Unused tables waste their whole provisioned capacity
+	assert.InDelta(5*(0.00013+0.00065), rates["table4"], 1e-9)
+	assert.Equal(dynamodb.BillingModePayPerRequest, recommendations["table4"])
+	assert.Equal(map[string]string{"env": "dr", "team": "payments"}, tableTags["table4"])
+	assert.Equal(RuleTableUnused, reasons["table4"].Rule)
+	assert.Contains(reasons["table4"].Explanation, "0 consumed RCU and WCU over")
+	assert.Contains(evidence["table4"], util.EvidenceItemCount)
+	assert.Equal(RuleTableOverProvisioned, reasons["table3"].Rule)
+
+	// Steady on-demand reads cost more than provisioning them
+	assert.InDelta(10*3600*0.00000025-(10*0.00013+0.00065), rates["table5"], 1e-9)
+	assert.Equal(dynamodb.BillingModeProvisioned, recommendations["table5"])
+
+	// Unused on-demand tables waste their storage, point-in-time recovery, backups and stream reads
+	assert.InDelta((10*0.25+10*0.20+5*0.10)/util.HoursPerMonth+300*0.0000002/2, rates["table7"], 1e-9)
+	assert.Equal(dynamodb.PointInTimeRecoveryStatusEnabled, evidence["table7"][util.EvidencePointInTimeRecoveryStatus])
+	assert.Equal("5368709120", evidence["table7"][util.EvidenceBackupSizeBytes])
+	assert.Equal("200", evidence["table7"]["GetRecords.SuccessfulRequestLatency.SampleCount.max"])
+
+	// Empty and unused tables waste their whole provisioned capacity
+	assert.InDelta(5*(0.00013+0.00065), rates["table10"], 1e-9)
+	assert.Equal(RuleTableEmpty, reasons["table10"].Rule)
+	assert.Equal("0", evidence["table10"][util.EvidenceItemCount])
+
+	// Empty tables whose capacity is consumed are judged on their capacity alone
+	assert.NotContains(rates, "table11")
+
+	// Standard-IA tables are priced at the rates of their table class
+	assert.InDelta(10*0.10/util.HoursPerMonth, rates["table9"], 1e-9)
+
+	// Writes to global tables are priced as replicated writes
+	assert.InDelta(9*0.000975, rates["table8"], 1e-9)
+	assert.Equal(dynamodb.BillingModeProvisioned, recommendations["table8"])
+}
+
+func TestMatchFacet(t *testing.T) {
+	assert := assert.New(t)
+
+	for usageType, expected := range map[string]DynamoPricingFacet{
+		"ReadCapacityUnit-Hrs":           ReadCapacityUnitHours,
+		"USE2-WriteCapacityUnit-Hrs":     WriteCapacityUnitHours,
+		"USE2-ReplWriteCapacityUnit-Hrs": ReplWriteCapacityUnitHours,
+		"USE2-IA-ReadRequestUnits":       "IA-ReadRequestUnits",
+		"IA-TimedStorage-ByteHrs":        "IA-TimedStorage-ByteHrs",
+		"EU-TimedPITRStorage-ByteHrs":    TimedPITRStorageByteHours,
+		"USE2-Streams-ReadRequestUnits":  StreamsReadRequestUnits,
+		"Streams-ReadRequestUnits":       StreamsReadRequestUnits,
+	} {
+		facet, ok := matchFacet(usageType)
+		assert.True(ok, usageType)
+		assert.Equal(expected, facet, usageType)
+	}
+
+	_, ok := matchFacet("USE2-StreamsReadRequestUnits")
+	assert.False(ok)
+}
+
+func (suite *DynamoDBTestSuite) TestAnalyzeDynamoDBTableWasteMissingPricing() {
+	assert := assert.New(suite.T())
+
+	// Replicated writes and the Standard-IA table class have no price, so only the global
+	// and Standard-IA tables can't be priced
+	suite.mockedPricing.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem("ReadCapacityUnit-Hrs", "0.00013"),
+			newPriceItem("WriteCapacityUnit-Hrs", "0.00065"),
+			newPriceItem("ReadRequestUnits", "0.00000025"),
+			newPriceItem("WriteRequestUnits", "0.00000125"),
+			newPriceItem("TimedStorage-ByteHrs", "0.25"),
+			newPriceItem("TimedPITRStorage-ByteHrs", "0.20"),
+			newPriceItem("TimedBackupStorage-ByteHrs", "0.10"),
+			newPriceItem("Streams-ReadRequestUnits", "0.0000002"),
+		}, nil)
+	mockTables(&suite.client)
+
+	wastedResources, err := suite.client.AnalyzeDynamodBTableWaste(context.TODO(), suite.region)
+	var ids []string
+	for _, wastedResource := range wastedResources {
+		ids = append(ids, wastedResource.Resource.R.ID())
+	}
+	assert.Contains(ids, "table3")
+	assert.NotContains(ids, "table8")
+	assert.NotContains(ids, "table9")
+
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(2, len(resourceErrs)) {
+		for _, resourceErr := range resourceErrs {
+			assert.Contains([]string{"table8", "table9"}, resourceErr.ResourceID)
+			assert.Equal("GetProducts", resourceErr.Operation)
+			assert.True(errors.Is(resourceErr, util.PricingError))
+		}
+	}
+}
+
 func (suite *DynamoDBTestSuite) TestGetDynamoDBPricing() {
 	assert := assert.New(suite.T())
 
@@ -2,10 +2,14 @@ package ec2
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/pricing"
+
+	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
 	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
 )
 
@@ -18,7 +22,7 @@ type EBSVolume struct {
 }
 
 type EBSVolumeType string
-type EBSVolumePricing map[EBSVolumeType]*util.AWSPriceItem
+type EBSVolumePricing map[EBSVolumeType]*pricingWaste.AWSPriceItem
 
 func (r EBSVolume) Type() string {
 	return ebsVolumeType
@@ -28,6 +32,10 @@ func (r EBSVolume) ID() string {
 	return aws.StringValue(r.r.VolumeId)
 }
 
+func (r EBSVolume) Tags() map[string]string {
+	return tagMap(r.r.Tags)
+}
+
 func (r EBSVolume) VolumeType() EBSVolumeType {
 	volumeType := aws.StringValue(r.r.VolumeType)
 	return EBSVolumeType(volumeType)
@@ -37,6 +45,22 @@ func (r EBSVolume) VolumeSizeinGb() int64 {
 	return *r.r.Size
 }
 
+func (r EBSVolume) reason() util.Reason {
+	return util.Reason{
+		Rule:        RuleEBSVolumeUnattached,
+		Explanation: fmt.Sprintf("%s is %s, attached to no instance", r.ID(), aws.StringValue(r.r.State)),
+	}
+}
+
+func (r EBSVolume) evidence() map[string]string {
+	return map[string]string{
+		util.EvidenceState:      aws.StringValue(r.r.State),
+		util.EvidenceCreateTime: util.FormatTime(aws.TimeValue(r.r.CreateTime)),
+		util.EvidenceVolumeType: aws.StringValue(r.r.VolumeType),
+		util.EvidenceSize:       strconv.FormatInt(aws.Int64Value(r.r.Size), 10),
+	}
+}
+
 func (client *Client) AnalyzeEBSVolumeWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
 	pricing, err := client.GetEBSVolumePricing(ctx, region)
 	if err != nil {
@@ -49,6 +73,7 @@ func (client *Client) AnalyzeEBSVolumeWaste(ctx context.Context, region string)
 	}
 
 	var wastedResources []util.AWSWastedResource
+	var resourceErrs util.ResourceErrors
 
 	for _, unusedResource := range unusedVolumes {
 		unusedVolume, ok := unusedResource.R.(*EBSVolume)
@@ -56,31 +81,48 @@ func (client *Client) AnalyzeEBSVolumeWaste(ctx context.Context, region string)
 			return nil, util.PricingError
 		}
 
-		volumeTypePricing, ok := pricing[unusedVolume.VolumeType()]
-		if !ok {
-			return nil, util.PricingError
-		}
-
-		if len(volumeTypePricing.OnDemand.Dimensions) > 1 {
-			return nil, util.PricingError
-		}
-
-		dimension := volumeTypePricing.OnDemand.Dimensions[0]
-
-		if dimension.Unit != "GB-Mo" {
-			return nil, util.PricingError
+		rate, err := pricing.MonthlyRate(unusedVolume)
+		if err != nil {
+			resourceErrs = resourceErrs.Append(unusedVolume.ID(), util.NewResourceError(unusedVolume.ID(), "GetProducts", err))
+			continue
 		}
 
 		wastedResources = append(wastedResources, util.AWSWastedResource{
 			Resource: unusedResource,
 			Price: util.Price{
 				Unit: "Mo",
-				Rate: dimension.Rate * float64(unusedVolume.VolumeSizeinGb()),
+				Rate: rate,
 			},
+			Reason:   unusedVolume.reason(),
+			Evidence: unusedVolume.evidence(),
 		})
 	}
 
-	return wastedResources, nil
+	return wastedResources, resourceErrs.ErrorOrNil()
+}
+
+// MonthlyRate returns the monthly price of the storage of a volume
+func (pricing EBSVolumePricing) MonthlyRate(volume *EBSVolume) (float64, error) {
+	volumeTypePricing, ok := pricing[volume.VolumeType()]
+	if !ok {
+		return 0, util.PricingError
+	}
+
+	dimension, err := volumeTypePricing.Dimension(float64(volume.VolumeSizeinGb()))
+	if err != nil {
+		return 0, err
+	}
+
+	if dimension.Unit != "GB-Mo" {
+		return 0, util.PricingError
+	}
+
+	rate, err := dimension.Rate()
+	if err != nil {
+		return 0, err
+	}
+
+	return rate * float64(volume.VolumeSizeinGb()), nil
 }
 
 func (client *Client) GetUnusedEBSVolumes(ctx context.Context) ([]util.AWSResourceObject, error) {
@@ -104,21 +146,11 @@ func (client *Client) GetUnusedEBSVolumes(ctx context.Context) ([]util.AWSResour
 }
 
 func (client *Client) GetEBSVolumePricing(ctx context.Context, region string) (EBSVolumePricing, error) {
-	regionName := util.RegionLongNames[region]
-
-	resp, err := client.Pricing.GetProductsWithContext(ctx, &pricing.GetProductsInput{
-		ServiceCode: aws.String(serviceCode),
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.EC2,
 		Filters: []*pricing.Filter{
-			{
-				Type:  aws.String("TERM_MATCH"),
-				Field: aws.String("location"),
-				Value: aws.String(regionName),
-			},
-			{
-				Type:  aws.String("TERM_MATCH"),
-				Field: aws.String("productFamily"),
-				Value: aws.String("Storage"),
-			},
+			termMatch("productFamily", "Storage"),
 		},
 	})
 	if err != nil {
@@ -127,13 +159,8 @@ func (client *Client) GetEBSVolumePricing(ctx context.Context, region string) (E
 
 	pricing := EBSVolumePricing{}
 
-	for _, priceItemJson := range resp.PriceList {
-		priceItem, err := util.ParsePriceItem(priceItemJson)
-		if err != nil {
-			return nil, err
-		}
-
-		if volumeType, ok := priceItem.Attributes["volumeApiName"].(string); ok {
+	for _, priceItem := range priceItems {
+		if volumeType := priceItem.Product.Attributes.VolumeAPIName; volumeType != "" {
 			pricing[EBSVolumeType(volumeType)] = priceItem
 		}
 	}
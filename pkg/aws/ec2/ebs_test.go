@@ -7,62 +7,41 @@ import (
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/pricing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	pricingTest "github.com/cloudwaste/cloudwaste/pkg/aws/pricing/test"
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
 )
 
 type EBSTestSuite struct {
 	suite.Suite
 	m      *mockedEC2
-	p      *mockedPricing
+	p      *pricingTest.MockedPricingInterface
 	region string
 	client Client
 }
 
 func (suite *EBSTestSuite) SetupTest() {
 	suite.m = new(mockedEC2)
-	suite.p = new(mockedPricing)
+	suite.p = new(pricingTest.MockedPricingInterface)
 	suite.region = "us-east-1"
 	suite.client = Client{EC2: suite.m, Pricing: suite.p}
 }
 
 func (suite *EBSTestSuite) MockPricingGood(unit string, rate string) *mock.Call {
-	return suite.p.On("GetProductsWithContext", mock.Anything, mock.Anything, mock.Anything).
-		Return(&pricing.GetProductsOutput{
-			PriceList: []aws.JSONValue{
-				{
-					"product": map[string]interface{}{
-						"attributes": map[string]interface{}{
-							"volumeApiName": "gp2",
-							"usagetype":     "",
-						},
-					},
-					"terms": map[string]interface{}{
-						"OnDemand": map[string]interface{}{
-							"1": map[string]interface{}{
-								"priceDimensions": map[string]interface{}{
-									"1": map[string]interface{}{
-										"unit":        unit,
-										"beginRange":  "0",
-										"endRange":    "Inf",
-										"description": "",
-										"pricePerUnit": map[string]interface{}{
-											"USD": rate,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+	return suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{VolumeAPIName: "gp2"}, unit,
+				priceRange{begin: "0", end: "Inf", usd: rate},
+			),
 		}, nil)
 }
 
 func (suite *EBSTestSuite) MockPricingError() *mock.Call {
-	return suite.p.On("GetProductsWithContext", mock.Anything, mock.Anything, mock.Anything).
+	return suite.p.On("GetProducts", mock.Anything, mock.Anything).
 		Return(nil, errors.New("error"))
 }
 
@@ -87,6 +66,7 @@ func (suite *EBSTestSuite) TestAnalyzeEBSVolumeWaste() {
 					State:      aws.String("available"),
 					Size:       aws.Int64(unusedVolumeSize),
 					VolumeType: aws.String("gp2"),
+					Tags:       []*ec2.Tag{{Key: aws.String("env"), Value: aws.String("dr")}},
 				},
 				{ // used
 					VolumeId: aws.String("vol2"),
@@ -103,6 +83,31 @@ func (suite *EBSTestSuite) TestAnalyzeEBSVolumeWaste() {
 	assert.Equal(vol1Name, wastedVolumes[0].Resource.R.ID())
 	assert.Equal(expectedUnit, wastedVolumes[0].Price.Unit)
 	assert.Equal(expectedRate, wastedVolumes[0].Price.Rate)
+	assert.Equal(map[string]string{"env": "dr"}, wastedVolumes[0].Resource.R.Tags())
+	assert.Equal(util.Reason{Rule: RuleEBSVolumeUnattached, Explanation: "vol1 is available, attached to no instance"}, wastedVolumes[0].Reason)
+	assert.Equal("available", wastedVolumes[0].Evidence["State"])
+	assert.Equal("500", wastedVolumes[0].Evidence["Size"])
+
+	// Volumes of a type without a price are left out of the others
+	suite.MockPricingGood(pricingUnit, rate).Once()
+	suite.m.On("DescribeVolumesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeVolumesOutput{
+			Volumes: []*ec2.Volume{
+				{VolumeId: aws.String(vol1Name), State: aws.String("available"), Size: aws.Int64(unusedVolumeSize), VolumeType: aws.String("gp2")},
+				{VolumeId: aws.String("vol3"), State: aws.String("available"), Size: aws.Int64(10), VolumeType: aws.String("io2")},
+			},
+		}, nil).Once()
+
+	wastedVolumes, err = suite.client.AnalyzeEBSVolumeWaste(context.TODO(), suite.region)
+	if assert.Equal(1, len(wastedVolumes)) {
+		assert.Equal(vol1Name, wastedVolumes[0].Resource.R.ID())
+	}
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("vol3", resourceErrs[0].ResourceID)
+		assert.Equal("GetProducts", resourceErrs[0].Operation)
+	}
 
 	// Test error cases
 	suite.MockPricingError().Once()
@@ -159,9 +164,14 @@ func (suite *EBSTestSuite) TestGetEBSVolumePricing() {
 	pricingRet, err := suite.client.GetEBSVolumePricing(context.Background(), suite.region)
 
 	gp2Pricing := pricingRet[EBSVolumeType("gp2")]
-	assert.NotNil(gp2Pricing)
-	assert.Equal(expectedRate, gp2Pricing.OnDemand.Dimensions[0].Rate)
-	assert.Equal(expectedUnit, gp2Pricing.OnDemand.Dimensions[0].Unit)
+	if assert.NotNil(gp2Pricing) {
+		dimension, err := gp2Pricing.Dimension(0)
+		assert.Nil(err)
+		rate, err := dimension.Rate()
+		assert.Nil(err)
+		assert.Equal(expectedRate, rate)
+		assert.Equal(expectedUnit, dimension.Unit)
+	}
 	assert.Nil(err)
 
 	// Test error cases
@@ -171,15 +181,14 @@ func (suite *EBSTestSuite) TestGetEBSVolumePricing() {
 	assert.Nil(pricingRet)
 	assert.NotNil(err)
 
-	suite.p.On("GetProductsWithContext", mock.Anything, mock.Anything, mock.Anything).
-		Return(&pricing.GetProductsOutput{
-			PriceList: []aws.JSONValue{
-				{},
-			},
+	// Price items that aren't for a volume type are ignored
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			{},
 		}, nil).Once()
 	pricingRet, err = suite.client.GetEBSVolumePricing(context.Background(), suite.region)
-	assert.Nil(pricingRet)
-	assert.NotNil(err)
+	assert.Equal(0, len(pricingRet))
+	assert.Nil(err)
 }
 
 // In order for 'go test' to run this suite, we need to create
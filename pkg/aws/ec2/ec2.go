@@ -3,31 +3,74 @@ package ec2
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/pricing"
-	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
 	"go.uber.org/zap"
 
+	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
 	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
 )
 
 var (
-	serviceCode             = "AmazonEC2"
 	multiplePriceCodesError = errors.New("Couldn't find single price code")
-	couldntParseError       = errors.New("Couldn't parse price code")
 )
 
 const (
 	UsageTypeNatGatewayHours = "NatGateway-Hours"
 )
 
+const (
+	analyzerService = "ec2"
+
+	AnalyzerNATGateway       = "nat-gateway"
+	AnalyzerEBSVolume        = "ebs-volume"
+	AnalyzerEBSSnapshot      = "ebs-snapshot"
+	AnalyzerElasticIPAddress = "elastic-ip-address"
+	AnalyzerIdleInstance     = "idle-instance"
+	AnalyzerStoppedInstance  = "stopped-instance"
+	AnalyzerImage            = "ami"
+)
+
+// Rules identify why a resource is wasted
+const (
+	RuleNATGatewayUnrouted      = "nat-gateway-unrouted"
+	RuleEBSVolumeUnattached     = "ebs-volume-unattached"
+	RuleEBSSnapshotOrphaned     = "ebs-snapshot-orphaned"
+	RuleElasticIPUnassociated   = "elastic-ip-address-unassociated"
+	RuleIdleInstance            = "idle-instance-underutilized"
+	RuleStoppedInstanceResource = "stopped-instance-resources"
+	RuleImageUnused             = "ami-unused"
+)
+
 type Client struct {
-	Logger  *zap.SugaredLogger
-	EC2     ec2iface.EC2API
-	Pricing pricingiface.PricingAPI
+	Logger      *zap.SugaredLogger
+	EC2         ec2iface.EC2API
+	Cloudwatch  cloudwatchiface.CloudWatchAPI
+	Autoscaling autoscalingiface.AutoScalingAPI
+	Pricing     pricingWaste.PricingInterface
+	// Concurrency is the maximum number of per-resource API calls in flight
+	Concurrency int
+	// IdleCPUThreshold is the CPU utilization in percent an idle instance stays below every period
+	IdleCPUThreshold float64
+	// IdleNetworkThreshold is the bytes in and out an idle instance stays below every period
+	IdleNetworkThreshold float64
+	// IdleMetrics tune the utilization checked by the idle instance analyzer, the defaults
+	// for zero fields. Its statistic is the one of CPU utilization, the thresholds above
+	// take the place of its min usage.
+	IdleMetrics util.MetricSettings
+	// StoppedInstanceAge is how long an instance must have been stopped to be waste, DefaultStoppedInstanceAge if zero
+	StoppedInstanceAge time.Duration
+	// UnusedImageAge is how old an unused AMI must be to be waste, DefaultUnusedImageAge if zero
+	UnusedImageAge time.Duration
 }
 
 type ElasticIPAddress struct {
@@ -52,6 +95,24 @@ func (a ElasticIPAddress) ID() string {
 	return aws.StringValue(a.r.AllocationId)
 }
 
+func (a ElasticIPAddress) Tags() map[string]string {
+	return tagMap(a.r.Tags)
+}
+
+func (a ElasticIPAddress) reason() util.Reason {
+	return util.Reason{
+		Rule:        RuleElasticIPUnassociated,
+		Explanation: fmt.Sprintf("%s is associated with no instance or network interface", aws.StringValue(a.r.PublicIp)),
+	}
+}
+
+func (a ElasticIPAddress) evidence() map[string]string {
+	return map[string]string{
+		util.EvidencePublicIP: aws.StringValue(a.r.PublicIp),
+		util.EvidenceDomain:   aws.StringValue(a.r.Domain),
+	}
+}
+
 func (r NatGateway) Type() string {
 	return "NAT Gateway"
 }
@@ -60,6 +121,69 @@ func (r NatGateway) ID() string {
 	return aws.StringValue(r.r.NatGatewayId)
 }
 
+func (r NatGateway) Tags() map[string]string {
+	return tagMap(r.r.Tags)
+}
+
+func (r NatGateway) reason() util.Reason {
+	return util.Reason{
+		Rule:        RuleNATGatewayUnrouted,
+		Explanation: fmt.Sprintf("no route tables reference %s", r.ID()),
+	}
+}
+
+func (r NatGateway) evidence() map[string]string {
+	return map[string]string{
+		util.EvidenceState:           aws.StringValue(r.r.State),
+		util.EvidenceCreateTime:      util.FormatTime(aws.TimeValue(r.r.CreateTime)),
+		util.EvidenceVpcID:           aws.StringValue(r.r.VpcId),
+		util.EvidenceRouteTableCount: "0",
+	}
+}
+
+// tagMap returns the tags of a resource by key
+func tagMap(tags []*ec2.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		m[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return m
+}
+
+// RegisterAnalyzers adds the EC2 analyzers to the registry
+func (client *Client) RegisterAnalyzers(registry *util.Registry) error {
+	return registry.Register(
+		util.NewAnalyzer(AnalyzerNATGateway, analyzerService, client.AnalyzeNATGatewayWaste),
+		util.NewAnalyzer(AnalyzerEBSVolume, analyzerService, client.AnalyzeEBSVolumeWaste),
+		util.NewAnalyzer(AnalyzerEBSSnapshot, analyzerService, client.AnalyzeEBSSnapshotWaste),
+		util.NewAnalyzer(AnalyzerElasticIPAddress, analyzerService, client.AnalyzeElasticIPAddressWaste),
+		util.NewAnalyzer(AnalyzerIdleInstance, analyzerService, client.AnalyzeIdleInstanceWaste),
+		util.NewAnalyzer(AnalyzerStoppedInstance, analyzerService, client.AnalyzeStoppedInstanceWaste),
+		util.NewAnalyzer(AnalyzerImage, analyzerService, client.AnalyzeImageWaste),
+	)
+}
+
+// GetEnabledRegions returns the sorted names of the regions enabled for the account
+func (client *Client) GetEnabledRegions(ctx context.Context) ([]string, error) {
+	resp, err := client.EC2.DescribeRegionsWithContext(ctx, &ec2.DescribeRegionsInput{
+		AllRegions: aws.Bool(false),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []string
+	for _, region := range resp.Regions {
+		if aws.StringValue(region.OptInStatus) == "not-opted-in" {
+			continue
+		}
+		regions = append(regions, aws.StringValue(region.RegionName))
+	}
+	sort.Strings(regions)
+
+	return regions, nil
+}
+
 func (client *Client) AnalyzeElasticIPAddressWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
 	pricing, err := client.GetElasticIPAddressPricing(ctx, region)
 	if err != nil {
@@ -78,12 +202,19 @@ func (client *Client) AnalyzeElasticIPAddressWaste(ctx context.Context, region s
 			return nil, errors.New("Unhandled pricing unit")
 		}
 
+		address, ok := unusedAddress.R.(*ElasticIPAddress)
+		if !ok {
+			return nil, util.PricingError
+		}
+
 		wastedResources = append(wastedResources, util.AWSWastedResource{
 			Resource: unusedAddress,
 			Price: util.Price{
 				Unit: "Hr",
 				Rate: pricing.Rate,
 			},
+			Reason:   address.reason(),
+			Evidence: address.evidence(),
 		})
 	}
 
@@ -102,6 +233,7 @@ func (client *Client) AnalyzeNATGatewayWaste(ctx context.Context, region string)
 	}
 
 	unusedNatGateways, err := client.GetUnusedNATGateways(ctx)
+	resourceErrs, err := util.SplitResourceErrors(err)
 	if err != nil {
 		client.Logger.Errorf("couldn't get Unused NAT Gateways: %v\n", err)
 		return nil, err
@@ -110,16 +242,23 @@ func (client *Client) AnalyzeNATGatewayWaste(ctx context.Context, region string)
 	var wastedResources []util.AWSWastedResource
 
 	for _, unusedResource := range unusedNatGateways {
+		gateway, ok := unusedResource.R.(*NatGateway)
+		if !ok {
+			return nil, util.PricingError
+		}
+
 		wastedResources = append(wastedResources, util.AWSWastedResource{
 			Resource: unusedResource,
 			Price: util.Price{
 				Unit: "Hr",
 				Rate: pricing.PerHour.Rate,
 			},
+			Reason:   gateway.reason(),
+			Evidence: gateway.evidence(),
 		})
 	}
 
-	return wastedResources, nil
+	return wastedResources, resourceErrs.ErrorOrNil()
 }
 
 func (client *Client) GetUnusedElasticIPAddresses(ctx context.Context) ([]util.AWSResourceObject, error) {
@@ -140,26 +279,13 @@ func (client *Client) GetUnusedElasticIPAddresses(ctx context.Context) ([]util.A
 }
 
 func (client *Client) GetUnusedNATGateways(ctx context.Context) ([]util.AWSResourceObject, error) {
-	var unusedGateways []util.AWSResourceObject
+	var gateways []*ec2.NatGateway
 
 	err := client.EC2.DescribeNatGatewaysPagesWithContext(ctx, &ec2.DescribeNatGatewaysInput{},
 		func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
 			for _, gateway := range page.NatGateways {
-				if *gateway.State == "deleted" {
-					continue
-				}
-
-				resp, _ := client.EC2.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{
-					Filters: []*ec2.Filter{
-						{
-							Name:   aws.String("route.nat-gateway-id"),
-							Values: []*string{gateway.NatGatewayId},
-						},
-					},
-				})
-
-				if len(resp.RouteTables) == 0 {
-					unusedGateways = append(unusedGateways, util.AWSResourceObject{R: &NatGateway{gateway}})
+				if *gateway.State != "deleted" {
+					gateways = append(gateways, gateway)
 				}
 			}
 
@@ -170,104 +296,130 @@ func (client *Client) GetUnusedNATGateways(ctx context.Context) ([]util.AWSResou
 		return nil, err
 	}
 
-	return unusedGateways, nil
+	unused := make([]bool, len(gateways))
+	errs := make([]error, len(gateways))
+
+	err = util.ForEach(ctx, client.Concurrency, len(gateways), func(i int) {
+		resp, err := client.EC2.DescribeRouteTablesWithContext(ctx, &ec2.DescribeRouteTablesInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("route.nat-gateway-id"),
+					Values: []*string{gateways[i].NatGatewayId},
+				},
+			},
+		})
+		if err != nil {
+			errs[i] = util.NewResourceError(aws.StringValue(gateways[i].NatGatewayId), "DescribeRouteTables", err)
+			return
+		}
+
+		unused[i] = len(resp.RouteTables) == 0
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var unusedGateways []util.AWSResourceObject
+	var resourceErrs util.ResourceErrors
+	for i, gateway := range gateways {
+		if errs[i] != nil {
+			resourceErrs = resourceErrs.Append(aws.StringValue(gateway.NatGatewayId), errs[i])
+			continue
+		}
+		if unused[i] {
+			unusedGateways = append(unusedGateways, util.AWSResourceObject{R: &NatGateway{gateway}})
+		}
+	}
+
+	return unusedGateways, resourceErrs.ErrorOrNil()
 }
 
-func (client *Client) GetElasticIPAddressPricing(ctx context.Context, region string) (*util.Price, error) {
-	regionName := util.RegionLongNames[region]
+// termMatch returns a TERM_MATCH pricing filter
+func termMatch(field string, value string) *pricing.Filter {
+	return &pricing.Filter{
+		Type:  aws.String(pricing.FilterTypeTermMatch),
+		Field: aws.String(field),
+		Value: aws.String(value),
+	}
+}
 
-	resp, err := client.Pricing.GetProductsWithContext(ctx, &pricing.GetProductsInput{
-		ServiceCode: aws.String(serviceCode),
+func (client *Client) GetElasticIPAddressPricing(ctx context.Context, region string) (*util.Price, error) {
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.EC2,
 		Filters: []*pricing.Filter{
-			{
-				Type:  aws.String("TERM_MATCH"),
-				Field: aws.String("productFamily"),
-				Value: aws.String("IP Address"),
-			},
-			{
-				Type:  aws.String("TERM_MATCH"),
-				Field: aws.String("group"),
-				Value: aws.String("ElasticIP:Address"),
-			},
-			{
-				Type:  aws.String("TERM_MATCH"),
-				Field: aws.String("location"),
-				Value: aws.String(regionName),
-			},
+			termMatch("productFamily", "IP Address"),
+			termMatch("group", "ElasticIP:Address"),
 		},
 	})
 
 	if err != nil {
 		return nil, err
 	}
-	if len(resp.PriceList) != 1 {
+	if len(priceItems) != 1 {
 		return nil, multiplePriceCodesError
 	}
 
-	priceItem, err := util.ParsePriceItem(resp.PriceList[0])
+	// The first tier covers the address attached to a running instance for free
+	dimension, err := priceItems[0].Dimension(1)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, dimension := range priceItem.OnDemand.Dimensions {
-		if dimension.BeginRange == "1" {
-			return &util.Price{
-				Rate: dimension.Rate,
-				Unit: dimension.Unit,
-			}, nil
-		}
+	rate, err := dimension.Rate()
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, couldntParseError
+	return &util.Price{
+		Rate: rate,
+		Unit: dimension.Unit,
+	}, nil
 }
 
 func (client *Client) GetNATGatewayPricing(ctx context.Context, region string) (*NATGatewayPricing, error) {
-	regionName := util.RegionLongNames[region]
-
-	resp, err := client.Pricing.GetProductsWithContext(ctx, &pricing.GetProductsInput{
-		ServiceCode: aws.String(serviceCode),
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.EC2,
 		Filters: []*pricing.Filter{
-			{
-				Type:  aws.String("TERM_MATCH"),
-				Field: aws.String("productFamily"),
-				Value: aws.String("NAT Gateway"),
-			},
-			{
-				Type:  aws.String("TERM_MATCH"),
-				Field: aws.String("location"),
-				Value: aws.String(regionName),
-			},
+			termMatch("productFamily", "NAT Gateway"),
 		},
 	})
 
 	if err != nil {
-		client.Logger.Errorf("couldn't GetProductsWithContext: %v", err)
+		client.Logger.Errorf("couldn't GetProducts: %v", err)
 		return nil, err
 	}
 
-	for _, p := range resp.PriceList {
-		priceItem, err := util.ParsePriceItem(p)
-		if err != nil {
-			client.Logger.Errorf("couldn't ParsePriceItem: %v", err)
-			return nil, err
-		}
+	for _, priceItem := range priceItems {
+		// Usage types outside of us-east-1 are prefixed with the region, e.g. USW2-NatGateway-Hours
+		if strings.HasSuffix(priceItem.Product.Attributes.UsageType, UsageTypeNatGatewayHours) {
+			dimensions, err := priceItem.Dimensions()
+			if err != nil {
+				return nil, err
+			}
 
-		if priceItem.UsageType == UsageTypeNatGatewayHours {
-			if len(priceItem.OnDemand.Dimensions) != 1 {
+			if len(dimensions) != 1 {
 				client.Logger.Error("priceItem.OnDemand.Dimensions was not 1")
 				return nil, util.PricingError
 			}
-			dimension := priceItem.OnDemand.Dimensions[0]
+			dimension := dimensions[0]
 
 			if dimension.Unit != "Hrs" {
 				client.Logger.Error("dimension.Unit was not Hrs")
 				return nil, util.PricingError
 			}
 
+			rate, err := dimension.Rate()
+			if err != nil {
+				return nil, err
+			}
+
 			return &NATGatewayPricing{
 				PerHour: &util.Price{
 					Unit: "Hrs",
-					Rate: dimension.Rate,
+					Rate: rate,
 				},
 			}, nil
 		}
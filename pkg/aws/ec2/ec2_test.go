@@ -6,15 +6,18 @@ import (
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
-	"github.com/aws/aws-sdk-go/service/pricing"
-	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"go.uber.org/zap"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	pricingTest "github.com/cloudwaste/cloudwaste/pkg/aws/pricing/test"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
 )
 
 type mockedEC2 struct {
@@ -22,23 +25,44 @@ type mockedEC2 struct {
 	ec2iface.EC2API
 }
 
-type mockedPricing struct {
-	mock.Mock
-	pricingiface.PricingAPI
-	aws.Config
-}
-
 type EC2TestSuite struct {
 	suite.Suite
 	m      *mockedEC2
-	p      *mockedPricing
+	p      *pricingTest.MockedPricingInterface
 	region string
 	client Client
 }
 
+type priceRange struct {
+	begin string
+	end   string
+	usd   string
+}
+
+func newPriceItem(attributes pricing.AWSPriceItemProductAttributes, unit string, ranges ...priceRange) *pricing.AWSPriceItem {
+	dimensions := make(map[string]pricing.AWSPriceItemPriceDimension)
+	for i, r := range ranges {
+		dimensions[string(rune('1'+i))] = pricing.AWSPriceItemPriceDimension{
+			Unit:         unit,
+			BeginRange:   r.begin,
+			EndRange:     r.end,
+			PricePerUnit: pricing.AWSPriceItemPricePerUnit{USD: r.usd},
+		}
+	}
+
+	return &pricing.AWSPriceItem{
+		Product: pricing.AWSPriceItemProduct{Attributes: attributes},
+		Terms: pricing.AWSPriceItemTerms{
+			OnDemand: map[string]pricing.AWSPriceItemOnDemand{
+				"1": {PriceDimensions: dimensions},
+			},
+		},
+	}
+}
+
 func (suite *EC2TestSuite) SetupTest() {
 	suite.m = new(mockedEC2)
-	suite.p = new(mockedPricing)
+	suite.p = new(pricingTest.MockedPricingInterface)
 	suite.region = "us-east-1"
 	suite.client = Client{
 		Logger:  zap.NewNop().Sugar(),
@@ -48,75 +72,26 @@ func (suite *EC2TestSuite) SetupTest() {
 }
 
 func (suite *EC2TestSuite) MockElasticIPAddressPricingGood(unit string, rate string) *mock.Call {
-	return suite.p.On("GetProductsWithContext", mock.Anything, mock.Anything, mock.Anything).
-		Return(&pricing.GetProductsOutput{
-			PriceList: []aws.JSONValue{
-				{
-					"terms": map[string]interface{}{
-						"OnDemand": map[string]interface{}{
-							"1": map[string]interface{}{
-								"priceDimensions": map[string]interface{}{
-									"1": map[string]interface{}{
-										"unit":        unit,
-										"beginRange":  "1",
-										"endRange":    "Inf",
-										"description": "",
-										"pricePerUnit": map[string]interface{}{
-											"USD": rate,
-										},
-									},
-									"2": map[string]interface{}{
-										"unit":        unit,
-										"beginRange":  "0",
-										"endRange":    "1",
-										"description": "",
-										"pricePerUnit": map[string]interface{}{
-											"USD": "0.0",
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+	return suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{}, unit,
+				priceRange{begin: "1", end: "Inf", usd: rate},
+				priceRange{begin: "0", end: "1", usd: "0.0"},
+			),
 		}, nil)
 }
 
 func (suite *EC2TestSuite) MockNATGatewayPricingGood(unit string, rate string) *mock.Call {
-	return suite.p.On("GetProductsWithContext", mock.Anything, mock.Anything, mock.Anything).
-		Return(&pricing.GetProductsOutput{
-			PriceList: []aws.JSONValue{
-				{
-					"product": map[string]interface{}{
-						"attributes": map[string]interface{}{
-							"usagetype": UsageTypeNatGatewayHours,
-						},
-					},
-					"terms": map[string]interface{}{
-						"OnDemand": map[string]interface{}{
-							"1": map[string]interface{}{
-								"priceDimensions": map[string]interface{}{
-									"1": map[string]interface{}{
-										"unit":        unit,
-										"beginRange":  "0",
-										"endRange":    "Inf",
-										"description": "",
-										"pricePerUnit": map[string]interface{}{
-											"USD": rate,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
+	return suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "USW2-" + UsageTypeNatGatewayHours}, unit,
+				priceRange{begin: "0", end: "Inf", usd: rate},
+			),
 		}, nil)
 }
 
 func (suite *EC2TestSuite) MockPricingError() *mock.Call {
-	return suite.p.On("GetProductsWithContext", mock.Anything, mock.Anything, mock.Anything).
+	return suite.p.On("GetProducts", mock.Anything, mock.Anything).
 		Return(nil, errors.New("error"))
 }
 
@@ -126,6 +101,15 @@ func (m *mockedEC2) DescribeAddressesWithContext(ctx context.Context, input *ec2
 	return args.Get(0).(*ec2.DescribeAddressesOutput), args.Error(1)
 }
 
+func (m *mockedEC2) DescribeRegionsWithContext(ctx context.Context, input *ec2.DescribeRegionsInput, options ...request.Option) (*ec2.DescribeRegionsOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	if args.Error(1) == nil {
+		return args.Get(0).(*ec2.DescribeRegionsOutput), nil
+	}
+	return nil, args.Error(1)
+}
+
 func (m *mockedEC2) DescribeNatGatewaysPagesWithContext(ctx context.Context, input *ec2.DescribeNatGatewaysInput, fn func(*ec2.DescribeNatGatewaysOutput, bool) bool, opts ...request.Option) error {
 	args := m.Called(ctx, input, fn)
 
@@ -136,7 +120,10 @@ func (m *mockedEC2) DescribeNatGatewaysPagesWithContext(ctx context.Context, inp
 func (m *mockedEC2) DescribeRouteTablesWithContext(ctx context.Context, input *ec2.DescribeRouteTablesInput, options ...request.Option) (*ec2.DescribeRouteTablesOutput, error) {
 	args := m.Called(ctx, input, options)
 
-	return args.Get(0).(*ec2.DescribeRouteTablesOutput), args.Error(1)
+	if args.Error(1) == nil {
+		return args.Get(0).(*ec2.DescribeRouteTablesOutput), nil
+	}
+	return nil, args.Error(1)
 }
 
 func (m *mockedEC2) DescribeVolumesPagesWithContext(ctx context.Context, input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool, opts ...request.Option) error {
@@ -148,15 +135,6 @@ func (m *mockedEC2) DescribeVolumesPagesWithContext(ctx context.Context, input *
 	return args.Error(1)
 }
 
-func (m *mockedPricing) GetProductsWithContext(ctx context.Context, input *pricing.GetProductsInput, options ...request.Option) (*pricing.GetProductsOutput, error) {
-	args := m.Called(ctx, input, options)
-
-	if args.Error(1) == nil {
-		return args.Get(0).(*pricing.GetProductsOutput), nil
-	}
-	return nil, args.Error(1)
-}
-
 func TestGetUnusedElasticIPAddresses(t *testing.T) {
 	assert := assert.New(t)
 
@@ -191,6 +169,46 @@ func TestGetUnusedElasticIPAddresses(t *testing.T) {
 	assert.NotNil(err)
 }
 
+func TestGetEnabledRegions(t *testing.T) {
+	assert := assert.New(t)
+
+	m := new(mockedEC2)
+	m.On("DescribeRegionsWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeRegionsOutput{
+			Regions: []*ec2.Region{
+				{
+					RegionName:  aws.String("us-west-2"),
+					OptInStatus: aws.String("opt-in-not-required"),
+				},
+				{
+					RegionName:  aws.String("af-south-1"),
+					OptInStatus: aws.String("opted-in"),
+				},
+				{
+					RegionName:  aws.String("ap-east-1"),
+					OptInStatus: aws.String("not-opted-in"),
+				},
+				{
+					RegionName:  aws.String("us-east-1"),
+					OptInStatus: aws.String("opt-in-not-required"),
+				},
+			},
+		}, nil).Once()
+
+	client := Client{EC2: m}
+	regions, err := client.GetEnabledRegions(context.Background())
+	assert.Nil(err)
+	assert.Equal([]string{"af-south-1", "us-east-1", "us-west-2"}, regions)
+
+	// Test error cases
+	m.On("DescribeRegionsWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("AWS Error")).Once()
+
+	regions, err = client.GetEnabledRegions(context.Background())
+	assert.Nil(regions)
+	assert.NotNil(err)
+}
+
 func TestGetUnusedNATGateways(t *testing.T) {
 	assert := assert.New(t)
 	m := new(mockedEC2)
@@ -212,7 +230,11 @@ func TestGetUnusedNATGateways(t *testing.T) {
 
 	client := Client{EC2: m}
 	unusedNatGateways, err := client.GetUnusedNATGateways(context.Background())
-	assert.Equal(1, len(unusedNatGateways))
+	if assert.Equal(1, len(unusedNatGateways)) {
+		gateway := unusedNatGateways[0].R.(*NatGateway)
+		assert.Equal(util.Reason{Rule: RuleNATGatewayUnrouted, Explanation: "no route tables reference gateway1"}, gateway.reason())
+		assert.Equal("0", gateway.evidence()[util.EvidenceRouteTableCount])
+	}
 	assert.Nil(err)
 
 	// Used
@@ -237,6 +259,29 @@ func TestGetUnusedNATGateways(t *testing.T) {
 	unusedNatGateways, err = client.GetUnusedNATGateways(context.Background())
 	assert.Equal(0, len(unusedNatGateways))
 	assert.Nil(err)
+
+	// Route tables of one gateway can't be described
+	m.On("DescribeNatGatewaysPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeNatGatewaysOutput{
+			NatGateways: []*ec2.NatGateway{
+				{
+					NatGatewayId: aws.String("gateway1"),
+					State:        aws.String("available"),
+				},
+			},
+		}, nil).Once()
+	m.On("DescribeRouteTablesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, awserr.New("UnauthorizedOperation", "not authorized", nil)).Once()
+
+	unusedNatGateways, err = client.GetUnusedNATGateways(context.Background())
+	assert.Equal(0, len(unusedNatGateways))
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("gateway1", resourceErrs[0].ResourceID)
+		assert.Equal("DescribeRouteTables", resourceErrs[0].Operation)
+		assert.Equal("UnauthorizedOperation", resourceErrs[0].Code)
+	}
 }
 
 func (suite *EC2TestSuite) TestGetElasticIPAddressPricing() {
@@ -263,11 +308,9 @@ func (suite *EC2TestSuite) TestGetElasticIPAddressPricing() {
 	assert.Nil(pricingRet)
 	assert.NotNil(err)
 
-	suite.p.On("GetProductsWithContext", mock.Anything, mock.Anything, mock.Anything).
-		Return(&pricing.GetProductsOutput{
-			PriceList: []aws.JSONValue{
-				{}, {}, // Multiple priceItems
-			},
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			{}, {}, // Multiple priceItems
 		}, nil).Once()
 
 	pricingRet, err = suite.client.GetElasticIPAddressPricing(context.Background(), suite.region)
@@ -294,12 +337,16 @@ func (suite *EC2TestSuite) TestGetNATGatwayPricing() {
 
 	// Test error cases
 	suite.MockPricingError().Once()
-	suite.p.On("GetProductsWithContext", mock.Anything, mock.Anything, mock.Anything).
-		Return(nil, errors.New("error")).Once()
 
 	pricingRet, err = suite.client.GetNATGatewayPricing(context.Background(), suite.region)
 	assert.Nil(pricingRet)
 	assert.NotNil(err)
+
+	suite.MockNATGatewayPricingGood("Mo", rate).Once()
+
+	pricingRet, err = suite.client.GetNATGatewayPricing(context.Background(), suite.region)
+	assert.Nil(pricingRet)
+	assert.Equal(util.PricingError, err)
 }
 
 // In order for 'go test' to run this suite, we need to create
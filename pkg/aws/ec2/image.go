@@ -0,0 +1,250 @@
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+const (
+	imageType = "AMI"
+
+	// DefaultUnusedImageAge is how old an unused AMI must be by default to be waste
+	DefaultUnusedImageAge = 90 * 24 * time.Hour
+)
+
+type Image struct {
+	r *ec2.Image
+}
+
+func (r Image) Type() string {
+	return imageType
+}
+
+func (r Image) ID() string {
+	return aws.StringValue(r.r.ImageId)
+}
+
+func (r Image) Tags() map[string]string {
+	return tagMap(r.r.Tags)
+}
+
+// snapshotSizes returns the size in GB of each snapshot backing the image
+func (r Image) snapshotSizes() []int64 {
+	var sizes []int64
+	for _, mapping := range r.r.BlockDeviceMappings {
+		if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+			sizes = append(sizes, aws.Int64Value(mapping.Ebs.VolumeSize))
+		}
+	}
+	return sizes
+}
+
+func (r Image) reason() util.Reason {
+	return util.Reason{
+		Rule:        RuleImageUnused,
+		Explanation: fmt.Sprintf("no instance, launch template or launch configuration uses %s", r.ID()),
+	}
+}
+
+func (r Image) evidence() map[string]string {
+	var snapshotIDs []string
+	for _, mapping := range r.r.BlockDeviceMappings {
+		if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+			snapshotIDs = append(snapshotIDs, *mapping.Ebs.SnapshotId)
+		}
+	}
+
+	return map[string]string{
+		util.EvidenceCreationDate: aws.StringValue(r.r.CreationDate),
+		util.EvidenceSnapshotIDs:  strings.Join(snapshotIDs, ","),
+	}
+}
+
+func (client *Client) unusedImageAge() time.Duration {
+	if client.UnusedImageAge > 0 {
+		return client.UnusedImageAge
+	}
+	return DefaultUnusedImageAge
+}
+
+// AnalyzeImageWaste prices the snapshots backing each unused AMI by the size of their
+// volumes, which is an upper bound as snapshots only store the blocks written to
+func (client *Client) AnalyzeImageWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
+	unusedImages, err := client.GetUnusedImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(unusedImages) == 0 {
+		return nil, nil
+	}
+
+	pricing, err := client.GetEBSSnapshotPricing(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotPricing, ok := pricing[EBSSnapshotTierStandard]
+	if !ok {
+		return nil, util.PricingError
+	}
+
+	var wastedResources []util.AWSWastedResource
+
+	for _, unusedResource := range unusedImages {
+		unusedImage, ok := unusedResource.R.(*Image)
+		if !ok {
+			return nil, util.PricingError
+		}
+
+		var rate float64
+		for _, size := range unusedImage.snapshotSizes() {
+			rate += snapshotPricing.Rate * float64(size)
+		}
+
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: unusedResource,
+			Price: util.Price{
+				Unit: "Mo",
+				Rate: rate,
+			},
+			Reason:   unusedImage.reason(),
+			Evidence: unusedImage.evidence(),
+		})
+	}
+
+	return wastedResources, nil
+}
+
+// GetUnusedImages returns the EBS backed AMIs owned by the account that are older than
+// the unused image age and aren't used by any instance, launch template version or
+// launch configuration of the region. It fails if any launch template can't be described,
+// as the images it may use would otherwise be reported as unused.
+func (client *Client) GetUnusedImages(ctx context.Context) ([]util.AWSResourceObject, error) {
+	createdBefore := time.Now().Add(-client.unusedImageAge())
+
+	var images []*ec2.Image
+
+	err := client.EC2.DescribeImagesPagesWithContext(ctx, &ec2.DescribeImagesInput{
+		Owners: aws.StringSlice([]string{"self"}),
+	}, func(page *ec2.DescribeImagesOutput, lastPage bool) bool {
+		for _, image := range page.Images {
+			created, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+			if err != nil || created.After(createdBefore) ||
+				aws.StringValue(image.RootDeviceType) != ec2.DeviceTypeEbs {
+				continue
+			}
+			images = append(images, image)
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(images) == 0 {
+		return nil, nil
+	}
+
+	usedImageIDs, err := client.usedImageIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var unusedImages []util.AWSResourceObject
+	for _, image := range images {
+		if !usedImageIDs[aws.StringValue(image.ImageId)] {
+			unusedImages = append(unusedImages, util.AWSResourceObject{R: &Image{image}})
+		}
+	}
+
+	return unusedImages, nil
+}
+
+// usedImageIDs returns the AMIs referenced by instances, launch template versions and
+// launch configurations
+func (client *Client) usedImageIDs(ctx context.Context) (map[string]bool, error) {
+	used := make(map[string]bool)
+
+	err := client.EC2.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{},
+		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, reservation := range page.Reservations {
+				for _, instance := range reservation.Instances {
+					used[aws.StringValue(instance.ImageId)] = true
+				}
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var templateIDs []*string
+
+	err = client.EC2.DescribeLaunchTemplatesPagesWithContext(ctx, &ec2.DescribeLaunchTemplatesInput{},
+		func(page *ec2.DescribeLaunchTemplatesOutput, lastPage bool) bool {
+			for _, template := range page.LaunchTemplates {
+				templateIDs = append(templateIDs, template.LaunchTemplateId)
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Any version of a template can be launched, not only the default one
+	templateImageIDs := make([][]string, len(templateIDs))
+	errs := make([]error, len(templateIDs))
+
+	err = util.ForEach(ctx, client.Concurrency, len(templateIDs), func(i int) {
+		errs[i] = client.EC2.DescribeLaunchTemplateVersionsPagesWithContext(ctx, &ec2.DescribeLaunchTemplateVersionsInput{
+			LaunchTemplateId: templateIDs[i],
+		}, func(page *ec2.DescribeLaunchTemplateVersionsOutput, lastPage bool) bool {
+			for _, version := range page.LaunchTemplateVersions {
+				if version.LaunchTemplateData != nil && version.LaunchTemplateData.ImageId != nil {
+					templateImageIDs[i] = append(templateImageIDs[i], *version.LaunchTemplateData.ImageId)
+				}
+			}
+			return true
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, templateID := range templateIDs {
+		if errs[i] != nil {
+			return nil, errors.Wrap(errs[i], fmt.Sprintf("couldn't describe the versions of launch template %s", aws.StringValue(templateID)))
+		}
+		for _, imageID := range templateImageIDs[i] {
+			used[imageID] = true
+		}
+	}
+
+	err = client.Autoscaling.DescribeLaunchConfigurationsPagesWithContext(ctx, &autoscaling.DescribeLaunchConfigurationsInput{},
+		func(page *autoscaling.DescribeLaunchConfigurationsOutput, lastPage bool) bool {
+			for _, configuration := range page.LaunchConfigurations {
+				used[aws.StringValue(configuration.ImageId)] = true
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return used, nil
+}
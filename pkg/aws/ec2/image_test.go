@@ -0,0 +1,190 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+type mockedAutoscaling struct {
+	mock.Mock
+	autoscalingiface.AutoScalingAPI
+}
+
+func (m *mockedAutoscaling) DescribeLaunchConfigurationsPagesWithContext(ctx context.Context, input *autoscaling.DescribeLaunchConfigurationsInput, fn func(*autoscaling.DescribeLaunchConfigurationsOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*autoscaling.DescribeLaunchConfigurationsOutput), true)
+	}
+	return args.Error(1)
+}
+
+func (m *mockedEC2) DescribeLaunchTemplatesPagesWithContext(ctx context.Context, input *ec2.DescribeLaunchTemplatesInput, fn func(*ec2.DescribeLaunchTemplatesOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*ec2.DescribeLaunchTemplatesOutput), true)
+	}
+	return args.Error(1)
+}
+
+func (m *mockedEC2) DescribeLaunchTemplateVersionsPagesWithContext(ctx context.Context, input *ec2.DescribeLaunchTemplateVersionsInput, fn func(*ec2.DescribeLaunchTemplateVersionsOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*ec2.DescribeLaunchTemplateVersionsOutput), true)
+	}
+	return args.Error(1)
+}
+
+func (suite *EC2TestSuite) MockImages() *mockedAutoscaling {
+	old := time.Now().Add(-365 * 24 * time.Hour).UTC().Format("2006-01-02T15:04:05.000Z")
+	recent := time.Now().Add(-24 * time.Hour).UTC().Format("2006-01-02T15:04:05.000Z")
+
+	newImage := func(id string, created string, sizes ...int64) *ec2.Image {
+		image := &ec2.Image{
+			ImageId:        aws.String(id),
+			CreationDate:   aws.String(created),
+			RootDeviceType: aws.String(ec2.DeviceTypeEbs),
+		}
+		for i, size := range sizes {
+			image.BlockDeviceMappings = append(image.BlockDeviceMappings, &ec2.BlockDeviceMapping{
+				Ebs: &ec2.EbsBlockDevice{
+					SnapshotId: aws.String(id + "-snap" + string(rune('1'+i))),
+					VolumeSize: aws.Int64(size),
+				},
+			})
+		}
+		return image
+	}
+
+	instanceStore := newImage("ami-instance-store", old)
+	instanceStore.RootDeviceType = aws.String(ec2.DeviceTypeInstanceStore)
+
+	suite.m.On("DescribeImagesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeImagesOutput{
+			Images: []*ec2.Image{
+				newImage("ami-unused", old, 8, 100),
+				newImage("ami-recent", recent, 8),
+				newImage("ami-instance", old, 8),
+				newImage("ami-template", old, 8),
+				newImage("ami-configuration", old, 8),
+				instanceStore,
+			},
+		}, nil)
+
+	suite.m.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{
+				Instances: []*ec2.Instance{{ImageId: aws.String("ami-instance")}},
+			}},
+		}, nil)
+
+	suite.m.On("DescribeLaunchTemplatesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeLaunchTemplatesOutput{
+			LaunchTemplates: []*ec2.LaunchTemplate{{LaunchTemplateId: aws.String("lt-1")}},
+		}, nil)
+
+	suite.m.On("DescribeLaunchTemplateVersionsPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeLaunchTemplateVersionsOutput{
+			LaunchTemplateVersions: []*ec2.LaunchTemplateVersion{
+				{LaunchTemplateData: &ec2.ResponseLaunchTemplateData{ImageId: aws.String("ami-template")}},
+				{LaunchTemplateData: &ec2.ResponseLaunchTemplateData{}},
+			},
+		}, nil)
+
+	a := new(mockedAutoscaling)
+	a.On("DescribeLaunchConfigurationsPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&autoscaling.DescribeLaunchConfigurationsOutput{
+			LaunchConfigurations: []*autoscaling.LaunchConfiguration{{ImageId: aws.String("ami-configuration")}},
+		}, nil)
+	suite.client.Autoscaling = a
+
+	return a
+}
+
+func (suite *EC2TestSuite) TestAnalyzeImageWaste() {
+	assert := assert.New(suite.T())
+
+	suite.MockImages()
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "EBS:SnapshotUsage"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.0500000000"},
+			),
+		}, nil)
+
+	wastedResources, err := suite.client.AnalyzeImageWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+	if assert.Equal(1, len(wastedResources)) {
+		assert.Equal("ami-unused", wastedResources[0].Resource.R.ID())
+		assert.Equal(imageType, wastedResources[0].Resource.R.Type())
+		assert.Equal("Mo", wastedResources[0].Price.Unit)
+		// Both snapshots of the image in a single resource
+		assert.InDelta(0.05*108, wastedResources[0].Price.Rate, 1e-9)
+		assert.Equal(RuleImageUnused, wastedResources[0].Reason.Rule)
+		assert.Contains(wastedResources[0].Reason.Explanation, "ami-unused")
+	}
+}
+
+func (suite *EC2TestSuite) TestGetUnusedImagesTemplateError() {
+	assert := assert.New(suite.T())
+
+	// Registered ahead of the mocks of every other call, so they take precedence
+	suite.m.On("DescribeLaunchTemplatesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeLaunchTemplatesOutput{
+			LaunchTemplates: []*ec2.LaunchTemplate{{LaunchTemplateId: aws.String("lt-1")}, {LaunchTemplateId: aws.String("lt-2")}},
+		}, nil)
+	suite.m.On("DescribeLaunchTemplateVersionsPagesWithContext", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeLaunchTemplateVersionsInput) bool {
+		return aws.StringValue(input.LaunchTemplateId) == "lt-2"
+	}), mock.Anything).Return(nil, awserr.New("UnauthorizedOperation", "not authorized", nil))
+	suite.MockImages()
+
+	// The images lt-2 may use can't be told apart from unused ones
+	unusedImages, err := suite.client.GetUnusedImages(context.TODO())
+	assert.Nil(unusedImages)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "lt-2")
+	}
+
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(resourceErrs)
+	assert.NotNil(err)
+}
+
+func (suite *EC2TestSuite) TestGetUnusedImagesError() {
+	assert := assert.New(suite.T())
+
+	a := suite.MockImages()
+	a.ExpectedCalls = nil
+	a.On("DescribeLaunchConfigurationsPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("error"))
+
+	unusedImages, err := suite.client.GetUnusedImages(context.TODO())
+	assert.Nil(unusedImages)
+	assert.NotNil(err)
+}
+
+func (suite *EC2TestSuite) TestGetUnusedImagesDescribeError() {
+	assert := assert.New(suite.T())
+
+	suite.m.On("DescribeImagesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("error"))
+
+	unusedImages, err := suite.client.GetUnusedImages(context.TODO())
+	assert.Nil(unusedImages)
+	assert.NotNil(err)
+}
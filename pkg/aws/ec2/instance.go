@@ -0,0 +1,346 @@
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/pkg/errors"
+
+	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+const (
+	instanceType = "EC2 Instance"
+
+	// DefaultIdleCPUThreshold is the CPU utilization in percent of every period below which an instance is idle
+	DefaultIdleCPUThreshold = 5.0
+	// DefaultIdleNetworkThreshold is the bytes in or out per period below which an instance is idle
+	DefaultIdleNetworkThreshold = 5 * 1024 * 1024
+	// DefaultLookback is how far back instance utilization is checked by default
+	DefaultLookback = 14 * 24 * time.Hour
+	// DefaultPeriod is the period of the utilization data points by default
+	DefaultPeriod = time.Hour
+	// DefaultCPUStatistic is the statistic of the CPU utilization data points by default
+	DefaultCPUStatistic = "Average"
+)
+
+type Instance struct {
+	r *ec2.Instance
+	// evidence is the window utilization was checked over and the largest data points
+	evidence map[string]string
+}
+
+func (r Instance) Type() string {
+	return instanceType
+}
+
+func (r Instance) ID() string {
+	return aws.StringValue(r.r.InstanceId)
+}
+
+func (r Instance) Tags() map[string]string {
+	return tagMap(r.r.Tags)
+}
+
+// instancePricingKey identifies the price of an instance in the AmazonEC2 price list
+type instancePricingKey struct {
+	instanceType    string
+	tenancy         string
+	operatingSystem string
+	preInstalledSw  string
+	licenseModel    string
+}
+
+// instancePlatform is the software an instance is billed for in the price list
+type instancePlatform struct {
+	operatingSystem string
+	preInstalledSw  string
+	licenseModel    string
+}
+
+const (
+	licenseModelIncluded = "No License required"
+	licenseModelBYOL     = "Bring your own license"
+)
+
+var (
+	linuxPlatform   = instancePlatform{"Linux", "NA", licenseModelIncluded}
+	windowsPlatform = instancePlatform{"Windows", "NA", licenseModelIncluded}
+
+	// pricePlatforms maps the platform details of an instance to the software it's billed for.
+	// BYOL Red Hat is billed as Linux, the subscription being paid for elsewhere.
+	pricePlatforms = map[string]instancePlatform{
+		"Linux/UNIX":                                                 linuxPlatform,
+		"Red Hat BYOL Linux":                                         linuxPlatform,
+		"Red Hat Enterprise Linux":                                   {"RHEL", "NA", licenseModelIncluded},
+		"Red Hat Enterprise Linux with HA":                           {"Red Hat Enterprise Linux with HA", "NA", licenseModelIncluded},
+		"Red Hat Enterprise Linux with SQL Server Standard":          {"RHEL", "SQL Std", licenseModelIncluded},
+		"Red Hat Enterprise Linux with SQL Server Enterprise":        {"RHEL", "SQL Ent", licenseModelIncluded},
+		"Red Hat Enterprise Linux with SQL Server Web":               {"RHEL", "SQL Web", licenseModelIncluded},
+		"Red Hat Enterprise Linux with SQL Server Standard and HA":   {"Red Hat Enterprise Linux with HA", "SQL Std", licenseModelIncluded},
+		"Red Hat Enterprise Linux with SQL Server Enterprise and HA": {"Red Hat Enterprise Linux with HA", "SQL Ent", licenseModelIncluded},
+		"SQL Server Standard":                                        {"Linux", "SQL Std", licenseModelIncluded},
+		"SQL Server Enterprise":                                      {"Linux", "SQL Ent", licenseModelIncluded},
+		"SQL Server Web":                                             {"Linux", "SQL Web", licenseModelIncluded},
+		"SUSE Linux":                                                 {"SUSE", "NA", licenseModelIncluded},
+		"Ubuntu Pro":                                                 {"Ubuntu Pro", "NA", licenseModelIncluded},
+		"Windows":                                                    windowsPlatform,
+		"Windows BYOL":                                               {"Windows", "NA", licenseModelBYOL},
+		"Windows with SQL Server Standard":                           {"Windows", "SQL Std", licenseModelIncluded},
+		"Windows with SQL Server Enterprise":                         {"Windows", "SQL Ent", licenseModelIncluded},
+		"Windows with SQL Server Web":                                {"Windows", "SQL Web", licenseModelIncluded},
+	}
+)
+
+// priceTenancies maps the tenancy of an instance placement to its name in the price list
+var priceTenancies = map[string]string{
+	ec2.TenancyDefault:   "Shared",
+	ec2.TenancyDedicated: "Dedicated",
+	ec2.TenancyHost:      "Host",
+}
+
+// platform returns the software the instance is billed for from its platform details,
+// falling back to its platform, which only tells Windows apart, if they're unknown
+func (r Instance) platform() instancePlatform {
+	if platform, ok := pricePlatforms[aws.StringValue(r.r.PlatformDetails)]; ok {
+		return platform
+	}
+	// The API describes the platform as "windows", unlike the SDK constant
+	if strings.EqualFold(aws.StringValue(r.r.Platform), ec2.PlatformValuesWindows) {
+		return windowsPlatform
+	}
+	return linuxPlatform
+}
+
+// pricingKey returns the price list attributes of an instance
+func (r Instance) pricingKey() instancePricingKey {
+	platform := r.platform()
+	key := instancePricingKey{
+		instanceType:    aws.StringValue(r.r.InstanceType),
+		tenancy:         "Shared",
+		operatingSystem: platform.operatingSystem,
+		preInstalledSw:  platform.preInstalledSw,
+		licenseModel:    platform.licenseModel,
+	}
+
+	if r.r.Placement != nil {
+		if tenancy, ok := priceTenancies[aws.StringValue(r.r.Placement.Tenancy)]; ok {
+			key.tenancy = tenancy
+		}
+	}
+
+	return key
+}
+
+// idleMetrics returns the utilization metric settings of the client, falling back to the defaults
+func (client *Client) idleMetrics() util.MetricSettings {
+	return client.IdleMetrics.WithDefaults(util.MetricSettings{
+		Lookback: DefaultLookback,
+		Period:   DefaultPeriod,
+		Stat:     DefaultCPUStatistic,
+	})
+}
+
+func (client *Client) AnalyzeIdleInstanceWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
+	idleInstances, err := client.GetIdleInstances(ctx)
+	resourceErrs, err := util.SplitResourceErrors(err)
+	if err != nil {
+		return nil, err
+	}
+
+	// Instances often share a type, so only look each price up once
+	prices := make(map[instancePricingKey]*util.Price)
+	priceErrs := make(map[instancePricingKey]error)
+
+	settings := client.idleMetrics()
+	reason := util.Reason{
+		Rule: RuleIdleInstance,
+		Explanation: fmt.Sprintf("CPU utilization stayed below %g%% and network traffic below %g MB in and out every %s over %s",
+			client.IdleCPUThreshold, client.IdleNetworkThreshold/(1024*1024), util.FormatDuration(settings.Period), util.FormatDuration(settings.Lookback)),
+	}
+
+	var wastedResources []util.AWSWastedResource
+
+	for _, idleResource := range idleInstances {
+		idleInstance, ok := idleResource.R.(*Instance)
+		if !ok {
+			return nil, util.PricingError
+		}
+
+		key := idleInstance.pricingKey()
+		price, ok := prices[key]
+		if !ok && priceErrs[key] == nil {
+			price, err = client.GetInstancePricing(ctx, region, key)
+			prices[key], priceErrs[key] = price, err
+		}
+		if err := priceErrs[key]; err != nil {
+			resourceErrs = resourceErrs.Append(idleInstance.ID(), util.NewResourceError(idleInstance.ID(), "GetProducts", err))
+			continue
+		}
+
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: idleResource,
+			Price:    *price,
+			Reason:   reason,
+			Evidence: idleInstance.evidence,
+		})
+	}
+
+	return wastedResources, resourceErrs.ErrorOrNil()
+}
+
+// GetIdleInstances returns the running on-demand instances launched before the lookback
+// window whose CPU utilization and network traffic stayed below the idle thresholds
+// during every period of it
+func (client *Client) GetIdleInstances(ctx context.Context) ([]util.AWSResourceObject, error) {
+	var instances []*ec2.Instance
+
+	launchedBefore := time.Now().Add(-client.idleMetrics().Lookback)
+
+	err := client.EC2.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{ec2.InstanceStateNameRunning}),
+			},
+		},
+	}, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				// Spot and scheduled instances aren't billed at the on-demand price
+				if instance.InstanceLifecycle != nil ||
+					instance.LaunchTime == nil || instance.LaunchTime.After(launchedBefore) {
+					continue
+				}
+				instances = append(instances, instance)
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	idle := make([]bool, len(instances))
+	evidence := make([]map[string]string, len(instances))
+	errs := make([]error, len(instances))
+
+	err = util.ForEach(ctx, client.Concurrency, len(instances), func(i int) {
+		idle[i], evidence[i], errs[i] = client.isIdleInstance(ctx, instances[i])
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var idleInstances []util.AWSResourceObject
+	var resourceErrs util.ResourceErrors
+	for i, instance := range instances {
+		if errs[i] != nil {
+			resourceErrs = resourceErrs.Append(aws.StringValue(instance.InstanceId), errs[i])
+			continue
+		}
+		if idle[i] {
+			idleInstances = append(idleInstances, util.AWSResourceObject{R: &Instance{r: instance, evidence: evidence[i]}})
+		}
+	}
+
+	return idleInstances, resourceErrs.ErrorOrNil()
+}
+
+// isIdleInstance checks the utilization metrics of an instance, stopping at the first one
+// over its threshold, and returns the evidence of an idle instance
+func (client *Client) isIdleInstance(ctx context.Context, instance *ec2.Instance) (bool, map[string]string, error) {
+	settings := client.idleMetrics()
+
+	// Network traffic is a number of bytes, only its sum over a period compares to the threshold
+	thresholds := []struct {
+		metricName string
+		stat       string
+		threshold  float64
+	}{
+		{"CPUUtilization", settings.Stat, client.IdleCPUThreshold},
+		{"NetworkIn", "Sum", client.IdleNetworkThreshold},
+		{"NetworkOut", "Sum", client.IdleNetworkThreshold},
+	}
+
+	evidence := make(map[string]string)
+
+	for _, t := range thresholds {
+		query := util.MetricQuery{
+			ID:         "utilization",
+			Namespace:  "AWS/EC2",
+			MetricName: t.metricName,
+			Dimensions: map[string]string{"InstanceId": aws.StringValue(instance.InstanceId)},
+			Stat:       t.stat,
+			Period:     settings.Period,
+			Lookback:   settings.Lookback,
+		}
+		result, err := util.GetMetric(ctx, client.Cloudwatch, query)
+		if err != nil {
+			return false, nil, util.NewResourceError(aws.StringValue(instance.InstanceId), "GetMetricData", err)
+		}
+
+		// An instance without data points isn't reporting metrics, so it can't be judged idle
+		if result.DataPoints == 0 || result.Max >= t.threshold {
+			return false, nil, nil
+		}
+		result.AddEvidence(evidence, t.metricName, query)
+	}
+
+	evidence[util.EvidenceInstanceType] = aws.StringValue(instance.InstanceType)
+	evidence[util.EvidenceLaunchTime] = util.FormatTime(aws.TimeValue(instance.LaunchTime))
+
+	return true, evidence, nil
+}
+
+// GetInstancePricing returns the on-demand hourly price of an instance type with
+// the given tenancy, operating system, pre-installed software and license model
+func (client *Client) GetInstancePricing(ctx context.Context, region string, key instancePricingKey) (*util.Price, error) {
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.EC2,
+		Filters: []*pricing.Filter{
+			termMatch("productFamily", "Compute Instance"),
+			termMatch("instanceType", key.instanceType),
+			termMatch("tenancy", key.tenancy),
+			termMatch("operatingSystem", key.operatingSystem),
+			termMatch("preInstalledSw", key.preInstalledSw),
+			termMatch("licenseModel", key.licenseModel),
+			termMatch("capacitystatus", "Used"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(priceItems) != 1 {
+		return nil, errors.Wrap(multiplePriceCodesError, fmt.Sprintf("%d prices for %s %s %s %s", len(priceItems), key.instanceType, key.tenancy, key.operatingSystem, key.preInstalledSw))
+	}
+
+	dimension, err := priceItems[0].UnboundedDimension()
+	if err != nil {
+		return nil, err
+	}
+
+	if dimension.Unit != "Hrs" {
+		return nil, util.PricingError
+	}
+
+	rate, err := dimension.Rate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &util.Price{
+		Unit: "Hr",
+		Rate: rate,
+	}, nil
+}
@@ -0,0 +1,290 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	pricingTest "github.com/cloudwaste/cloudwaste/pkg/aws/pricing/test"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+type mockedCloudwatch struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+// utilization holds the hourly data points of each metric by instance
+var utilization = map[string]map[string][]float64{
+	"idle": {
+		"CPUUtilization": {0.5, 1.2},
+		"NetworkIn":      {1024, 2048},
+		"NetworkOut":     {512},
+	},
+	"busy-cpu": {
+		"CPUUtilization": {0.5, 40},
+	},
+	"busy-network": {
+		"CPUUtilization": {0.5},
+		"NetworkIn":      {1024},
+		"NetworkOut":     {100 * 1024 * 1024},
+	},
+	"idle-linux": {
+		"CPUUtilization": {0.5},
+		"NetworkIn":      {1024},
+		"NetworkOut":     {1024},
+	},
+	"no-metrics": {},
+}
+
+func (m *mockedCloudwatch) GetMetricDataWithContext(ctx context.Context, input *cloudwatch.GetMetricDataInput, options ...request.Option) (*cloudwatch.GetMetricDataOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	metric := input.MetricDataQueries[0].MetricStat.Metric
+	values := utilization[*metric.Dimensions[0].Value][*metric.MetricName]
+
+	return &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []*cloudwatch.MetricDataResult{{
+			Id:     aws.String("utilization"),
+			Values: aws.Float64Slice(values),
+		}},
+	}, args.Error(0)
+}
+
+func (m *mockedEC2) DescribeInstancesPagesWithContext(ctx context.Context, input *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*ec2.DescribeInstancesOutput), true)
+	}
+	return args.Error(1)
+}
+
+type InstanceTestSuite struct {
+	suite.Suite
+	m      *mockedEC2
+	c      *mockedCloudwatch
+	p      *pricingTest.MockedPricingInterface
+	region string
+	client Client
+}
+
+func (suite *InstanceTestSuite) SetupTest() {
+	suite.m = new(mockedEC2)
+	suite.c = new(mockedCloudwatch)
+	suite.p = new(pricingTest.MockedPricingInterface)
+	suite.region = "us-east-1"
+	suite.client = Client{
+		EC2:                  suite.m,
+		Cloudwatch:           suite.c,
+		Pricing:              suite.p,
+		IdleCPUThreshold:     DefaultIdleCPUThreshold,
+		IdleNetworkThreshold: DefaultIdleNetworkThreshold,
+	}
+}
+
+func (suite *InstanceTestSuite) MockInstances() {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	young := time.Now().Add(-time.Hour)
+
+	newInstance := func(id string, launchTime time.Time) *ec2.Instance {
+		return &ec2.Instance{
+			InstanceId:   aws.String(id),
+			InstanceType: aws.String("m5.large"),
+			LaunchTime:   aws.Time(launchTime),
+			Placement:    &ec2.Placement{Tenancy: aws.String(ec2.TenancyDefault)},
+		}
+	}
+
+	spot := newInstance("spot", old)
+	spot.InstanceLifecycle = aws.String(ec2.InstanceLifecycleTypeSpot)
+
+	windows := newInstance("idle", old)
+	windows.Platform = aws.String("windows")
+
+	suite.m.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{
+				{Instances: []*ec2.Instance{windows, newInstance("busy-cpu", old)}},
+				{Instances: []*ec2.Instance{newInstance("busy-network", old), newInstance("no-metrics", old)}},
+				{Instances: []*ec2.Instance{newInstance("young", young), spot}},
+			},
+		}, nil)
+}
+
+func (suite *InstanceTestSuite) TestGetIdleInstances() {
+	assert := assert.New(suite.T())
+
+	suite.MockInstances()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	idleInstances, err := suite.client.GetIdleInstances(context.TODO())
+	assert.Nil(err)
+	if assert.Equal(1, len(idleInstances)) {
+		assert.Equal("idle", idleInstances[0].R.ID())
+		assert.Equal(instanceType, idleInstances[0].R.Type())
+	}
+
+	input := suite.m.Calls[0].Arguments.Get(1).(*ec2.DescribeInstancesInput)
+	assert.Equal([]string{"running"}, aws.StringValueSlice(input.Filters[0].Values))
+
+	// The statistic and period only change how CPU utilization is summarized
+	suite.c = new(mockedCloudwatch)
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.client.Cloudwatch = suite.c
+	suite.client.IdleMetrics = util.MetricSettings{Period: 5 * time.Minute, Stat: "Maximum"}
+
+	idleInstances, err = suite.client.GetIdleInstances(context.TODO())
+	assert.Nil(err)
+	assert.Equal(1, len(idleInstances))
+	for _, call := range suite.c.Calls {
+		stat := call.Arguments.Get(1).(*cloudwatch.GetMetricDataInput).MetricDataQueries[0].MetricStat
+		assert.Equal(int64(300), aws.Int64Value(stat.Period))
+		if aws.StringValue(stat.Metric.MetricName) == "CPUUtilization" {
+			assert.Equal("Maximum", aws.StringValue(stat.Stat))
+		} else {
+			assert.Equal("Sum", aws.StringValue(stat.Stat))
+		}
+	}
+	suite.client.IdleMetrics = util.MetricSettings{}
+
+	// Test error cases
+	suite.c = new(mockedCloudwatch)
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("error"))
+	suite.client.Cloudwatch = suite.c
+
+	idleInstances, err = suite.client.GetIdleInstances(context.TODO())
+	assert.Nil(idleInstances)
+	assert.NotNil(err)
+}
+
+func (suite *InstanceTestSuite) TestAnalyzeIdleInstanceWaste() {
+	assert := assert.New(suite.T())
+
+	suite.MockInstances()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{InstanceType: "m5.large"}, "Hrs",
+				priceRange{begin: "0", end: "Inf", usd: "0.1880000000"},
+			),
+		}, nil).Once()
+
+	wastedResources, err := suite.client.AnalyzeIdleInstanceWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+	if assert.Equal(1, len(wastedResources)) {
+		assert.Equal("Hr", wastedResources[0].Price.Unit)
+		assert.Equal(0.188, wastedResources[0].Price.Rate)
+		assert.Equal("1.2", wastedResources[0].Evidence["CPUUtilization.Average.max"])
+		assert.Equal("2048", wastedResources[0].Evidence["NetworkIn.Sum.max"])
+		assert.Equal("512", wastedResources[0].Evidence["NetworkOut.Sum.max"])
+		assert.Equal("1h", wastedResources[0].Evidence[util.EvidencePeriod])
+		assert.Equal("m5.large", wastedResources[0].Evidence["InstanceType"])
+		assert.Equal(RuleIdleInstance, wastedResources[0].Reason.Rule)
+	}
+
+	input := suite.p.Calls[0].Arguments.Get(1).(*pricing.GetProductsInput)
+	filters := make(map[string]string)
+	for _, filter := range input.Filters {
+		filters[aws.StringValue(filter.Field)] = aws.StringValue(filter.Value)
+	}
+	assert.Equal("m5.large", filters["instanceType"])
+	assert.Equal("Shared", filters["tenancy"])
+	assert.Equal("Windows", filters["operatingSystem"])
+	assert.Equal("NA", filters["preInstalledSw"])
+
+	// Test error cases
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{}, nil).Once()
+
+	wastedResources, err = suite.client.AnalyzeIdleInstanceWaste(context.TODO(), suite.region)
+	assert.Nil(wastedResources)
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("idle", resourceErrs[0].ResourceID)
+		assert.True(errors.Is(resourceErrs[0], multiplePriceCodesError))
+	}
+}
+
+func TestInstancePricingKey(t *testing.T) {
+	assert := assert.New(t)
+
+	newInstance := func(platform string, platformDetails string) Instance {
+		instance := &ec2.Instance{InstanceType: aws.String("m5.large")}
+		if platform != "" {
+			instance.Platform = aws.String(platform)
+		}
+		if platformDetails != "" {
+			instance.PlatformDetails = aws.String(platformDetails)
+		}
+		return Instance{r: instance}
+	}
+
+	assert.Equal(instancePricingKey{"m5.large", "Shared", "Linux", "NA", "No License required"}, newInstance("", "Linux/UNIX").pricingKey())
+	assert.Equal(instancePricingKey{"m5.large", "Shared", "RHEL", "NA", "No License required"}, newInstance("", "Red Hat Enterprise Linux").pricingKey())
+	assert.Equal(instancePricingKey{"m5.large", "Shared", "Windows", "SQL Std", "No License required"},
+		newInstance("windows", "Windows with SQL Server Standard").pricingKey())
+	assert.Equal(instancePricingKey{"m5.large", "Shared", "Linux", "SQL Ent", "No License required"}, newInstance("", "SQL Server Enterprise").pricingKey())
+
+	// Without known platform details only Windows is told apart
+	assert.Equal("Windows", newInstance("windows", "").pricingKey().operatingSystem)
+	assert.Equal("Linux", newInstance("", "").pricingKey().operatingSystem)
+}
+
+func (suite *InstanceTestSuite) TestAnalyzeIdleInstanceWastePricingError() {
+	assert := assert.New(suite.T())
+
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	suite.m.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{Instances: []*ec2.Instance{
+				{InstanceId: aws.String("idle"), InstanceType: aws.String("u-6tb1.metal"), LaunchTime: aws.Time(old)},
+				{InstanceId: aws.String("idle-linux"), InstanceType: aws.String("t3.micro"), LaunchTime: aws.Time(old)},
+			}}},
+		}, nil)
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.p.On("GetProducts", mock.Anything, mock.MatchedBy(func(input *pricing.GetProductsInput) bool {
+		return aws.StringValue(input.Filters[1].Value) == "t3.micro"
+	})).Return([]*pricing.AWSPriceItem{
+		newPriceItem(pricing.AWSPriceItemProductAttributes{InstanceType: "t3.micro"}, "Hrs",
+			priceRange{begin: "0", end: "Inf", usd: "0.0104000000"},
+		),
+	}, nil)
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{}, nil)
+
+	// The instance type without a price doesn't hide the other idle instance
+	wastedResources, err := suite.client.AnalyzeIdleInstanceWaste(context.TODO(), suite.region)
+	if assert.Equal(1, len(wastedResources)) {
+		assert.Equal("idle-linux", wastedResources[0].Resource.R.ID())
+		assert.Equal(0.0104, wastedResources[0].Price.Rate)
+	}
+
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("idle", resourceErrs[0].ResourceID)
+		assert.Equal("GetProducts", resourceErrs[0].Operation)
+	}
+}
+
+func TestInstanceTestSuite(t *testing.T) {
+	suite.Run(t, new(InstanceTestSuite))
+}
@@ -0,0 +1,248 @@
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/pkg/errors"
+
+	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+const (
+	ebsSnapshotType = "EBS Snapshot"
+
+	// unknownSourceVolumeID is the volume of snapshots that weren't taken from a volume
+	// of the account, e.g. copied snapshots or those created by CreateImage
+	unknownSourceVolumeID = "vol-ffffffff"
+)
+
+type EBSSnapshotTier string
+
+const (
+	EBSSnapshotTierStandard EBSSnapshotTier = "standard"
+	EBSSnapshotTierArchive  EBSSnapshotTier = "archive"
+)
+
+// ebsSnapshotUsageTypes maps the usage type suffix of each tier in the price list
+var ebsSnapshotUsageTypes = map[string]EBSSnapshotTier{
+	"EBS:SnapshotUsage":          EBSSnapshotTierStandard,
+	"EBS:SnapshotArchiveStorage": EBSSnapshotTierArchive,
+}
+
+type EBSSnapshot struct {
+	r *ec2.Snapshot
+}
+
+type EBSSnapshotPricing map[EBSSnapshotTier]*util.Price
+
+func (r EBSSnapshot) Type() string {
+	return ebsSnapshotType
+}
+
+func (r EBSSnapshot) ID() string {
+	return aws.StringValue(r.r.SnapshotId)
+}
+
+func (r EBSSnapshot) Tags() map[string]string {
+	return tagMap(r.r.Tags)
+}
+
+func (r EBSSnapshot) reason() util.Reason {
+	return util.Reason{
+		Rule:        RuleEBSSnapshotOrphaned,
+		Explanation: fmt.Sprintf("source volume %s no longer exists and no AMI uses %s", aws.StringValue(r.r.VolumeId), r.ID()),
+	}
+}
+
+func (r EBSSnapshot) evidence() map[string]string {
+	return map[string]string{
+		util.EvidenceVolumeID:    aws.StringValue(r.r.VolumeId),
+		util.EvidenceStartTime:   util.FormatTime(aws.TimeValue(r.r.StartTime)),
+		util.EvidenceVolumeSize:  strconv.FormatInt(aws.Int64Value(r.r.VolumeSize), 10),
+		util.EvidenceStorageTier: string(r.Tier()),
+	}
+}
+
+// Tier returns the storage tier of the snapshot, snapshots described without one are standard
+func (r EBSSnapshot) Tier() EBSSnapshotTier {
+	if r.r.StorageTier == nil {
+		return EBSSnapshotTierStandard
+	}
+	return EBSSnapshotTier(*r.r.StorageTier)
+}
+
+// AnalyzeEBSSnapshotWaste prices orphaned snapshots by the size of their source volume
+// at the rate of their storage tier. Standard snapshots are incremental, so this is an
+// upper bound of what deleting one saves.
+func (client *Client) AnalyzeEBSSnapshotWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
+	orphanedSnapshots, err := client.GetOrphanedEBSSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(orphanedSnapshots) == 0 {
+		return nil, nil
+	}
+
+	pricing, err := client.GetEBSSnapshotPricing(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	var wastedResources []util.AWSWastedResource
+	var resourceErrs util.ResourceErrors
+
+	for _, orphanedResource := range orphanedSnapshots {
+		orphanedSnapshot, ok := orphanedResource.R.(*EBSSnapshot)
+		if !ok {
+			return nil, util.PricingError
+		}
+
+		tierPricing, ok := pricing[orphanedSnapshot.Tier()]
+		if !ok {
+			err := errors.Wrap(util.PricingError, string(orphanedSnapshot.Tier()))
+			resourceErrs = resourceErrs.Append(orphanedSnapshot.ID(), util.NewResourceError(orphanedSnapshot.ID(), "GetProducts", err))
+			continue
+		}
+
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: orphanedResource,
+			Price: util.Price{
+				Unit: "Mo",
+				Rate: tierPricing.Rate * float64(aws.Int64Value(orphanedSnapshot.r.VolumeSize)),
+			},
+			Reason:   orphanedSnapshot.reason(),
+			Evidence: orphanedSnapshot.evidence(),
+		})
+	}
+
+	return wastedResources, resourceErrs.ErrorOrNil()
+}
+
+// imageSnapshotIDs returns the snapshots backing the AMIs owned by the account
+func (client *Client) imageSnapshotIDs(ctx context.Context) (map[string]bool, error) {
+	snapshotIDs := make(map[string]bool)
+
+	err := client.EC2.DescribeImagesPagesWithContext(ctx, &ec2.DescribeImagesInput{
+		Owners: aws.StringSlice([]string{"self"}),
+	}, func(page *ec2.DescribeImagesOutput, lastPage bool) bool {
+		for _, image := range page.Images {
+			for _, mapping := range image.BlockDeviceMappings {
+				if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+					snapshotIDs[*mapping.Ebs.SnapshotId] = true
+				}
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshotIDs, nil
+}
+
+// GetOrphanedEBSSnapshots returns the completed snapshots owned by the account whose
+// source volume no longer exists and which don't back any AMI of the account. Snapshots
+// with an unknown source volume are skipped, as their volume can't be checked.
+func (client *Client) GetOrphanedEBSSnapshots(ctx context.Context) ([]util.AWSResourceObject, error) {
+	volumeIDs := make(map[string]bool)
+
+	err := client.EC2.DescribeVolumesPagesWithContext(ctx, &ec2.DescribeVolumesInput{},
+		func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			for _, volume := range page.Volumes {
+				volumeIDs[aws.StringValue(volume.VolumeId)] = true
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	imageSnapshotIDs, err := client.imageSnapshotIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphanedSnapshots []util.AWSResourceObject
+
+	err = client.EC2.DescribeSnapshotsPagesWithContext(ctx, &ec2.DescribeSnapshotsInput{
+		OwnerIds: aws.StringSlice([]string{"self"}),
+	}, func(page *ec2.DescribeSnapshotsOutput, lastPage bool) bool {
+		for _, snapshot := range page.Snapshots {
+			if aws.StringValue(snapshot.State) != ec2.SnapshotStateCompleted ||
+				aws.StringValue(snapshot.VolumeId) == unknownSourceVolumeID ||
+				volumeIDs[aws.StringValue(snapshot.VolumeId)] ||
+				imageSnapshotIDs[aws.StringValue(snapshot.SnapshotId)] {
+				continue
+			}
+			orphanedSnapshots = append(orphanedSnapshots, util.AWSResourceObject{R: &EBSSnapshot{snapshot}})
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return orphanedSnapshots, nil
+}
+
+// GetEBSSnapshotPricing returns the monthly price of a GB of snapshot storage by tier
+func (client *Client) GetEBSSnapshotPricing(ctx context.Context, region string) (EBSSnapshotPricing, error) {
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.EC2,
+		Filters: []*pricing.Filter{
+			termMatch("productFamily", "Storage Snapshot"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pricing := EBSSnapshotPricing{}
+
+	for _, priceItem := range priceItems {
+		// Usage types outside of us-east-1 are prefixed with the region, e.g. USW2-EBS:SnapshotUsage
+		var tier EBSSnapshotTier
+		for usageType, usageTypeTier := range ebsSnapshotUsageTypes {
+			if strings.HasSuffix(priceItem.Product.Attributes.UsageType, usageType) {
+				tier = usageTypeTier
+			}
+		}
+		if tier == "" {
+			continue
+		}
+
+		dimension, err := priceItem.UnboundedDimension()
+		if err != nil {
+			return nil, err
+		}
+
+		if dimension.Unit != "GB-Mo" {
+			return nil, util.PricingError
+		}
+
+		rate, err := dimension.Rate()
+		if err != nil {
+			return nil, err
+		}
+
+		pricing[tier] = &util.Price{
+			Unit: "GB-Mo",
+			Rate: rate,
+		}
+	}
+
+	return pricing, nil
+}
@@ -0,0 +1,186 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+)
+
+func (m *mockedEC2) DescribeImagesPagesWithContext(ctx context.Context, input *ec2.DescribeImagesInput, fn func(*ec2.DescribeImagesOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*ec2.DescribeImagesOutput), true)
+	}
+	return args.Error(1)
+}
+
+func (m *mockedEC2) DescribeSnapshotsPagesWithContext(ctx context.Context, input *ec2.DescribeSnapshotsInput, fn func(*ec2.DescribeSnapshotsOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*ec2.DescribeSnapshotsOutput), true)
+	}
+	return args.Error(1)
+}
+
+func (suite *EC2TestSuite) MockSnapshots() {
+	suite.m.On("DescribeVolumesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeVolumesOutput{
+			Volumes: []*ec2.Volume{{VolumeId: aws.String("vol-live")}},
+		}, nil)
+
+	suite.m.On("DescribeImagesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeImagesOutput{
+			Images: []*ec2.Image{{
+				ImageId: aws.String("ami-1"),
+				BlockDeviceMappings: []*ec2.BlockDeviceMapping{
+					{DeviceName: aws.String("/dev/sdb")},
+					{Ebs: &ec2.EbsBlockDevice{SnapshotId: aws.String("snap-image")}},
+				},
+			}},
+		}, nil)
+
+	suite.m.On("DescribeSnapshotsPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeSnapshotsOutput{
+			Snapshots: []*ec2.Snapshot{
+				{ // orphaned
+					SnapshotId: aws.String("snap-orphaned"),
+					VolumeId:   aws.String("vol-deleted"),
+					VolumeSize: aws.Int64(100),
+					State:      aws.String(ec2.SnapshotStateCompleted),
+				},
+				{ // orphaned and archived
+					SnapshotId:  aws.String("snap-archived"),
+					VolumeId:    aws.String("vol-deleted"),
+					VolumeSize:  aws.Int64(50),
+					State:       aws.String(ec2.SnapshotStateCompleted),
+					StorageTier: aws.String(ec2.StorageTierArchive),
+				},
+				{ // volume still exists
+					SnapshotId: aws.String("snap-live"),
+					VolumeId:   aws.String("vol-live"),
+					VolumeSize: aws.Int64(100),
+					State:      aws.String(ec2.SnapshotStateCompleted),
+				},
+				{ // backs an AMI
+					SnapshotId: aws.String("snap-image"),
+					VolumeId:   aws.String("vol-deleted"),
+					VolumeSize: aws.Int64(8),
+					State:      aws.String(ec2.SnapshotStateCompleted),
+				},
+				{ // copied, so its source volume is unknown
+					SnapshotId: aws.String("snap-copied"),
+					VolumeId:   aws.String("vol-ffffffff"),
+					VolumeSize: aws.Int64(100),
+					State:      aws.String(ec2.SnapshotStateCompleted),
+				},
+				{ // still being taken
+					SnapshotId: aws.String("snap-pending"),
+					VolumeId:   aws.String("vol-deleted"),
+					VolumeSize: aws.Int64(100),
+					State:      aws.String(ec2.SnapshotStatePending),
+				},
+			},
+		}, nil)
+}
+
+func (suite *EC2TestSuite) TestAnalyzeEBSSnapshotWaste() {
+	assert := assert.New(suite.T())
+
+	suite.MockSnapshots()
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "USW2-EBS:SnapshotArchiveStorage"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.0125000000"},
+			),
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "USW2-EBS:SnapshotUsage"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.0500000000"},
+			),
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "USW2-EBS:FastSnapshotRestore"}, "DSU-Hr",
+				priceRange{begin: "0", end: "Inf", usd: "0.7500000000"},
+			),
+		}, nil)
+
+	wastedResources, err := suite.client.AnalyzeEBSSnapshotWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+	if assert.Equal(2, len(wastedResources)) {
+		assert.Equal("snap-orphaned", wastedResources[0].Resource.R.ID())
+		assert.Equal(ebsSnapshotType, wastedResources[0].Resource.R.Type())
+		assert.Equal("Mo", wastedResources[0].Price.Unit)
+		assert.InDelta(0.05*100, wastedResources[0].Price.Rate, 1e-9)
+		assert.Equal(RuleEBSSnapshotOrphaned, wastedResources[0].Reason.Rule)
+		assert.Equal("100", wastedResources[0].Evidence["VolumeSize"])
+		assert.Equal("standard", wastedResources[0].Evidence["StorageTier"])
+
+		// Archived snapshots are priced at the archive rate
+		assert.Equal("snap-archived", wastedResources[1].Resource.R.ID())
+		assert.InDelta(0.0125*50, wastedResources[1].Price.Rate, 1e-9)
+		assert.Equal("archive", wastedResources[1].Evidence["StorageTier"])
+	}
+
+	input := suite.m.Calls[2].Arguments.Get(1).(*ec2.DescribeSnapshotsInput)
+	assert.Equal([]string{"self"}, aws.StringValueSlice(input.OwnerIds))
+}
+
+func (suite *EC2TestSuite) TestGetOrphanedEBSSnapshots() {
+	assert := assert.New(suite.T())
+
+	suite.MockSnapshots()
+
+	orphanedSnapshots, err := suite.client.GetOrphanedEBSSnapshots(context.TODO())
+	assert.Nil(err)
+
+	var snapshotIDs []string
+	for _, snapshot := range orphanedSnapshots {
+		snapshotIDs = append(snapshotIDs, snapshot.R.ID())
+	}
+	assert.Equal([]string{"snap-orphaned", "snap-archived"}, snapshotIDs)
+}
+
+func (suite *EC2TestSuite) TestGetEBSSnapshotPricing() {
+	assert := assert.New(suite.T())
+
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "EBS:SnapshotUsage"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.0500000000"},
+			),
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "EBS:SnapshotArchiveStorage"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.0125000000"},
+			),
+		}, nil).Once()
+
+	pricing, err := suite.client.GetEBSSnapshotPricing(context.TODO(), suite.region)
+	if assert.Nil(err) {
+		assert.Equal(0.05, pricing[EBSSnapshotTierStandard].Rate)
+		assert.Equal(0.0125, pricing[EBSSnapshotTierArchive].Rate)
+	}
+
+	// Test error cases
+	suite.MockPricingError()
+
+	pricing, err = suite.client.GetEBSSnapshotPricing(context.TODO(), suite.region)
+	assert.Nil(pricing)
+	assert.NotNil(err)
+}
+
+func (suite *EC2TestSuite) TestGetOrphanedEBSSnapshotsError() {
+	assert := assert.New(suite.T())
+
+	suite.m.On("DescribeVolumesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeVolumesOutput{}, nil)
+	suite.m.On("DescribeImagesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("error"))
+
+	orphanedSnapshots, err := suite.client.GetOrphanedEBSSnapshots(context.TODO())
+	assert.Nil(orphanedSnapshots)
+	assert.NotNil(err)
+}
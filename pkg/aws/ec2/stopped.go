@@ -0,0 +1,266 @@
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/pkg/errors"
+
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+const (
+	stoppedInstanceType = "Stopped EC2 Instance"
+
+	// DefaultStoppedInstanceAge is how long an instance must have been stopped by default to be waste
+	DefaultStoppedInstanceAge = 30 * 24 * time.Hour
+
+	// maxFilterValues is the most values the EC2 API accepts in a single filter
+	maxFilterValues = 200
+)
+
+// stateTransitionTime matches the time in the state transition reason of a stopped
+// instance, e.g. "User initiated (2021-01-15 10:23:45 GMT)"
+var stateTransitionTime = regexp.MustCompile(`\((\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) GMT\)`)
+
+// StoppedInstance is a stopped instance along with the volumes and addresses it keeps paying for
+type StoppedInstance struct {
+	r         *ec2.Instance
+	volumes   []*ec2.Volume
+	addresses []*ec2.Address
+}
+
+func (r StoppedInstance) Type() string {
+	return stoppedInstanceType
+}
+
+func (r StoppedInstance) ID() string {
+	return aws.StringValue(r.r.InstanceId)
+}
+
+func (r StoppedInstance) Tags() map[string]string {
+	return tagMap(r.r.Tags)
+}
+
+func (r StoppedInstance) reason() util.Reason {
+	explanation := fmt.Sprintf("%s is stopped, still paying for %d volumes and %d Elastic IP addresses", r.ID(), len(r.volumes), len(r.addresses))
+	if stopped, ok := stoppedSince(r.r); ok {
+		explanation = fmt.Sprintf("%s is stopped since %s, still paying for %d volumes and %d Elastic IP addresses",
+			r.ID(), stopped.Format("2006-01-02"), len(r.volumes), len(r.addresses))
+	}
+
+	return util.Reason{
+		Rule:        RuleStoppedInstanceResource,
+		Explanation: explanation,
+	}
+}
+
+func (r StoppedInstance) evidence() map[string]string {
+	var volumeIDs, allocationIDs []string
+	for _, volume := range r.volumes {
+		volumeIDs = append(volumeIDs, aws.StringValue(volume.VolumeId))
+	}
+	for _, address := range r.addresses {
+		allocationIDs = append(allocationIDs, aws.StringValue(address.AllocationId))
+	}
+
+	return map[string]string{
+		util.EvidenceStateTransitionReason: aws.StringValue(r.r.StateTransitionReason),
+		util.EvidenceVolumeIDs:             strings.Join(volumeIDs, ","),
+		util.EvidenceAllocationIDs:         strings.Join(allocationIDs, ","),
+	}
+}
+
+// stoppedSince returns when an instance was stopped according to its state transition reason
+func stoppedSince(instance *ec2.Instance) (time.Time, bool) {
+	match := stateTransitionTime.FindStringSubmatch(aws.StringValue(instance.StateTransitionReason))
+	if match == nil {
+		return time.Time{}, false
+	}
+
+	stopped, err := time.Parse("2006-01-02 15:04:05", match[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return stopped, true
+}
+
+func (client *Client) stoppedInstanceAge() time.Duration {
+	if client.StoppedInstanceAge > 0 {
+		return client.StoppedInstanceAge
+	}
+	return DefaultStoppedInstanceAge
+}
+
+// AnalyzeStoppedInstanceWaste prices the volumes and Elastic IP addresses of instances
+// stopped for longer than the stopped instance age, as a single hourly rate per instance
+func (client *Client) AnalyzeStoppedInstanceWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
+	stoppedInstances, err := client.GetStoppedInstances(ctx)
+	resourceErrs, err := util.SplitResourceErrors(err)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(stoppedInstances) == 0 {
+		return nil, resourceErrs.ErrorOrNil()
+	}
+
+	volumePricing, err := client.GetEBSVolumePricing(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	addressPricing, err := client.GetElasticIPAddressPricing(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+	if addressPricing.Unit != "Hrs" {
+		return nil, util.PricingError
+	}
+
+	var wastedResources []util.AWSWastedResource
+
+	for _, stoppedResource := range stoppedInstances {
+		stoppedInstance, ok := stoppedResource.R.(*StoppedInstance)
+		if !ok {
+			return nil, util.PricingError
+		}
+
+		var rate float64
+		var err error
+
+		for _, volume := range stoppedInstance.volumes {
+			var monthly float64
+			monthly, err = volumePricing.MonthlyRate(&EBSVolume{volume})
+			if err != nil {
+				err = errors.Wrap(err, aws.StringValue(volume.VolumeId))
+				break
+			}
+			rate += monthly / util.HoursPerMonth
+		}
+
+		if err != nil {
+			resourceErrs = resourceErrs.Append(stoppedInstance.ID(), util.NewResourceError(stoppedInstance.ID(), "GetProducts", err))
+			continue
+		}
+
+		rate += addressPricing.Rate * float64(len(stoppedInstance.addresses))
+
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: stoppedResource,
+			Price: util.Price{
+				Unit: "Hr",
+				Rate: rate,
+			},
+			Reason:   stoppedInstance.reason(),
+			Evidence: stoppedInstance.evidence(),
+		})
+	}
+
+	return wastedResources, resourceErrs.ErrorOrNil()
+}
+
+// GetStoppedInstances returns the instances stopped for longer than the stopped instance
+// age with their attached volumes and associated addresses. Instances whose state
+// transition reason has no time are left out, as their age is unknown.
+func (client *Client) GetStoppedInstances(ctx context.Context) ([]util.AWSResourceObject, error) {
+	var instances []*StoppedInstance
+
+	stoppedBefore := time.Now().Add(-client.stoppedInstanceAge())
+
+	err := client.EC2.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{ec2.InstanceStateNameStopped}),
+			},
+		},
+	}, func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range page.Reservations {
+			for _, instance := range reservation.Instances {
+				if stopped, ok := stoppedSince(instance); ok && stopped.Before(stoppedBefore) {
+					instances = append(instances, &StoppedInstance{r: instance})
+				}
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(instances) == 0 {
+		return nil, nil
+	}
+
+	instancesByID := make(map[string]*StoppedInstance, len(instances))
+	var instanceIDs []string
+	for _, instance := range instances {
+		instancesByID[instance.ID()] = instance
+		instanceIDs = append(instanceIDs, instance.ID())
+	}
+
+	// The volumes of a batch of instances that can't be described leave out only those instances
+	failed := make(map[string]error)
+
+	for begin := 0; begin < len(instanceIDs); begin += maxFilterValues {
+		end := begin + maxFilterValues
+		if end > len(instanceIDs) {
+			end = len(instanceIDs)
+		}
+
+		err = client.EC2.DescribeVolumesPagesWithContext(ctx, &ec2.DescribeVolumesInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("attachment.instance-id"),
+					Values: aws.StringSlice(instanceIDs[begin:end]),
+				},
+			},
+		}, func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			for _, volume := range page.Volumes {
+				for _, attachment := range volume.Attachments {
+					if instance, ok := instancesByID[aws.StringValue(attachment.InstanceId)]; ok {
+						instance.volumes = append(instance.volumes, volume)
+					}
+				}
+			}
+			return true
+		})
+
+		if err != nil {
+			for _, instanceID := range instanceIDs[begin:end] {
+				failed[instanceID] = util.NewResourceError(instanceID, "DescribeVolumes", err)
+			}
+		}
+	}
+
+	resp, err := client.EC2.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, address := range resp.Addresses {
+		if instance, ok := instancesByID[aws.StringValue(address.InstanceId)]; ok {
+			instance.addresses = append(instance.addresses, address)
+		}
+	}
+
+	var stoppedInstances []util.AWSResourceObject
+	var resourceErrs util.ResourceErrors
+	for _, instance := range instances {
+		if err, ok := failed[instance.ID()]; ok {
+			resourceErrs = resourceErrs.Append(instance.ID(), err)
+			continue
+		}
+		stoppedInstances = append(stoppedInstances, util.AWSResourceObject{R: instance})
+	}
+
+	return stoppedInstances, resourceErrs.ErrorOrNil()
+}
@@ -0,0 +1,234 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+// productFamily matches the GetProducts calls filtering on a product family
+func productFamily(family string) interface{} {
+	return mock.MatchedBy(func(input *pricing.GetProductsInput) bool {
+		for _, filter := range input.Filters {
+			if aws.StringValue(filter.Field) == "productFamily" {
+				return aws.StringValue(filter.Value) == family
+			}
+		}
+		return false
+	})
+}
+
+func TestStoppedSince(t *testing.T) {
+	assert := assert.New(t)
+
+	stopped, ok := stoppedSince(&ec2.Instance{StateTransitionReason: aws.String("User initiated (2021-01-15 10:23:45 GMT)")})
+	if assert.True(ok) {
+		assert.Equal(time.Date(2021, 1, 15, 10, 23, 45, 0, time.UTC), stopped)
+	}
+
+	_, ok = stoppedSince(&ec2.Instance{StateTransitionReason: aws.String("")})
+	assert.False(ok)
+
+	_, ok = stoppedSince(&ec2.Instance{})
+	assert.False(ok)
+}
+
+func (suite *EC2TestSuite) MockStoppedInstances() {
+	recent := time.Now().Add(-time.Hour).UTC().Format("2006-01-02 15:04:05")
+
+	suite.m.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{
+				Instances: []*ec2.Instance{
+					{
+						InstanceId:            aws.String("old"),
+						StateTransitionReason: aws.String("User initiated (2020-01-15 10:23:45 GMT)"),
+					},
+					{
+						InstanceId:            aws.String("recent"),
+						StateTransitionReason: aws.String("User initiated (" + recent + " GMT)"),
+					},
+					{
+						InstanceId:            aws.String("unknown"),
+						StateTransitionReason: aws.String("Server.ScheduledStop"),
+					},
+				},
+			}},
+		}, nil)
+
+	suite.m.On("DescribeVolumesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeVolumesOutput{
+			Volumes: []*ec2.Volume{
+				{
+					VolumeId:    aws.String("vol1"),
+					Size:        aws.Int64(100),
+					VolumeType:  aws.String("gp2"),
+					Attachments: []*ec2.VolumeAttachment{{InstanceId: aws.String("old")}},
+				},
+				{
+					VolumeId:    aws.String("vol2"),
+					Size:        aws.Int64(50),
+					VolumeType:  aws.String("gp2"),
+					Attachments: []*ec2.VolumeAttachment{{InstanceId: aws.String("old")}},
+				},
+			},
+		}, nil)
+
+	suite.m.On("DescribeAddressesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeAddressesOutput{
+			Addresses: []*ec2.Address{
+				{AllocationId: aws.String("allocation1"), InstanceId: aws.String("old")},
+				{AllocationId: aws.String("allocation2"), InstanceId: aws.String("running")},
+			},
+		}, nil)
+}
+
+func (suite *EC2TestSuite) TestAnalyzeStoppedInstanceWaste() {
+	assert := assert.New(suite.T())
+
+	suite.MockStoppedInstances()
+	suite.p.On("GetProducts", mock.Anything, productFamily("Storage")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{VolumeAPIName: "gp2"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.1000000000"},
+			),
+		}, nil)
+	suite.p.On("GetProducts", mock.Anything, productFamily("IP Address")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{}, "Hrs",
+				priceRange{begin: "1", end: "Inf", usd: "0.0050000000"},
+				priceRange{begin: "0", end: "1", usd: "0.0"},
+			),
+		}, nil)
+
+	wastedResources, err := suite.client.AnalyzeStoppedInstanceWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+	if assert.Equal(1, len(wastedResources)) {
+		assert.Equal("old", wastedResources[0].Resource.R.ID())
+		assert.Equal(stoppedInstanceType, wastedResources[0].Resource.R.Type())
+		assert.Equal("Hr", wastedResources[0].Price.Unit)
+		// 150 GB of gp2 and one address
+		assert.InDelta(0.1*150/util.HoursPerMonth+0.005, wastedResources[0].Price.Rate, 1e-9)
+		assert.Equal(RuleStoppedInstanceResource, wastedResources[0].Reason.Rule)
+		assert.Equal("vol1,vol2", wastedResources[0].Evidence[util.EvidenceVolumeIDs])
+		assert.Equal("allocation1", wastedResources[0].Evidence[util.EvidenceAllocationIDs])
+	}
+
+	input := suite.m.Calls[1].Arguments.Get(1).(*ec2.DescribeVolumesInput)
+	assert.Equal([]string{"old"}, aws.StringValueSlice(input.Filters[0].Values))
+}
+
+func (suite *EC2TestSuite) TestAnalyzeStoppedInstanceWastePricingError() {
+	assert := assert.New(suite.T())
+
+	suite.m.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{
+				Instances: []*ec2.Instance{
+					{InstanceId: aws.String("old"), StateTransitionReason: aws.String("User initiated (2020-01-15 10:23:45 GMT)")},
+					{InstanceId: aws.String("old-io2"), StateTransitionReason: aws.String("User initiated (2020-01-15 10:23:45 GMT)")},
+				},
+			}},
+		}, nil)
+	suite.m.On("DescribeVolumesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeVolumesOutput{
+			Volumes: []*ec2.Volume{
+				{
+					VolumeId:    aws.String("vol1"),
+					Size:        aws.Int64(100),
+					VolumeType:  aws.String("gp2"),
+					Attachments: []*ec2.VolumeAttachment{{InstanceId: aws.String("old")}},
+				},
+				{
+					VolumeId:    aws.String("vol2"),
+					Size:        aws.Int64(100),
+					VolumeType:  aws.String("io2"),
+					Attachments: []*ec2.VolumeAttachment{{InstanceId: aws.String("old-io2")}},
+				},
+			},
+		}, nil)
+	suite.m.On("DescribeAddressesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeAddressesOutput{}, nil)
+	suite.p.On("GetProducts", mock.Anything, productFamily("Storage")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{VolumeAPIName: "gp2"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.1000000000"},
+			),
+		}, nil)
+	suite.p.On("GetProducts", mock.Anything, productFamily("IP Address")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{}, "Hrs",
+				priceRange{begin: "0", end: "Inf", usd: "0.0050000000"},
+			),
+		}, nil)
+
+	// The io2 volume has no price, the other instance is still reported
+	wastedResources, err := suite.client.AnalyzeStoppedInstanceWaste(context.TODO(), suite.region)
+	if assert.Equal(1, len(wastedResources)) {
+		assert.Equal("old", wastedResources[0].Resource.R.ID())
+	}
+
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("old-io2", resourceErrs[0].ResourceID)
+		assert.Equal("GetProducts", resourceErrs[0].Operation)
+		assert.Contains(resourceErrs[0].Error(), "vol2")
+	}
+}
+
+func (suite *EC2TestSuite) TestGetStoppedInstancesVolumesError() {
+	assert := assert.New(suite.T())
+
+	suite.m.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{{
+				Instances: []*ec2.Instance{
+					{InstanceId: aws.String("old"), StateTransitionReason: aws.String("User initiated (2020-01-15 10:23:45 GMT)")},
+				},
+			}},
+		}, nil)
+	suite.m.On("DescribeVolumesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("error"))
+	suite.m.On("DescribeAddressesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&ec2.DescribeAddressesOutput{}, nil)
+
+	stoppedInstances, err := suite.client.GetStoppedInstances(context.TODO())
+	assert.Equal(0, len(stoppedInstances))
+
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("old", resourceErrs[0].ResourceID)
+		assert.Equal("DescribeVolumes", resourceErrs[0].Operation)
+	}
+}
+
+func (suite *EC2TestSuite) TestAnalyzeStoppedInstanceWasteError() {
+	assert := assert.New(suite.T())
+
+	suite.MockStoppedInstances()
+	suite.MockPricingError()
+
+	wastedResources, err := suite.client.AnalyzeStoppedInstanceWaste(context.TODO(), suite.region)
+	assert.Nil(wastedResources)
+	assert.NotNil(err)
+
+	suite.m = new(mockedEC2)
+	suite.m.On("DescribeInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("error"))
+	suite.client.EC2 = suite.m
+
+	stoppedInstances, err := suite.client.GetStoppedInstances(context.TODO())
+	assert.Nil(stoppedInstances)
+	assert.NotNil(err)
+}
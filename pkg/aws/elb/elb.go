@@ -0,0 +1,487 @@
+package elb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/pkg/errors"
+
+	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+type LoadBalancerType string
+
+const (
+	Application LoadBalancerType = "Application Load Balancer"
+	Network     LoadBalancerType = "Network Load Balancer"
+	Classic     LoadBalancerType = "Classic Load Balancer"
+)
+
+const (
+	UsageTypeLoadBalancerHours = "LoadBalancerUsage"
+
+	analyzerService = "elb"
+
+	AnalyzerIdleLoadBalancer = "idle-load-balancer"
+
+	// RuleNoTargets and RuleNoTraffic identify why a load balancer is idle
+	RuleNoTargets = "load-balancer-no-targets"
+	RuleNoTraffic = "load-balancer-no-traffic"
+)
+
+const (
+	// DefaultLookback is how far back load balancer traffic is checked by default
+	DefaultLookback = 14 * 24 * time.Hour
+	// DefaultPeriod is the period of the traffic data points by default
+	DefaultPeriod = 24 * time.Hour
+	// DefaultStatistic is the statistic of the traffic data points by default
+	DefaultStatistic = "Sum"
+)
+
+// productFamilies maps each load balancer type to its product family in the AWSELB price list
+var productFamilies = map[LoadBalancerType]string{
+	Application: "Load Balancer-Application",
+	Network:     "Load Balancer-Network",
+	Classic:     "Load Balancer",
+}
+
+type Client struct {
+	ELB        elbiface.ELBAPI
+	ELBV2      elbv2iface.ELBV2API
+	Cloudwatch cloudwatchiface.CloudWatchAPI
+	Pricing    pricingWaste.PricingInterface
+	// Concurrency is the maximum number of per-load balancer API calls in flight
+	Concurrency int
+	// IdleMetrics tune the traffic checked by the idle load balancer analyzer, the defaults
+	// for zero fields. A load balancer is idle if its traffic never exceeds the min usage.
+	IdleMetrics util.MetricSettings
+}
+
+type LoadBalancer struct {
+	name             string
+	loadBalancerType LoadBalancerType
+	// metrics are the traffic metrics of the load balancer, it's idle when none exceeds the min usage
+	metrics []util.MetricQuery
+	// targets tells whether anything is registered behind the load balancer
+	targets func(ctx context.Context) (bool, error)
+	// listTags returns the tags of the load balancer, only listed for idle load balancers
+	listTags func(ctx context.Context) (map[string]string, error)
+	tags     map[string]string
+	// reason and evidence explain why an idle load balancer is idle
+	reason   util.Reason
+	evidence map[string]string
+}
+
+func (r LoadBalancer) Type() string {
+	return string(r.loadBalancerType)
+}
+
+func (r LoadBalancer) ID() string {
+	return r.name
+}
+
+func (r LoadBalancer) Tags() map[string]string {
+	return r.tags
+}
+
+// RegisterAnalyzers adds the load balancer analyzers to the registry
+func (client *Client) RegisterAnalyzers(registry *util.Registry) error {
+	return registry.Register(
+		util.NewAnalyzer(AnalyzerIdleLoadBalancer, analyzerService, client.AnalyzeIdleLoadBalancerWaste),
+	)
+}
+
+// idleMetrics returns the traffic metric settings of the client, falling back to the defaults
+func (client *Client) idleMetrics() util.MetricSettings {
+	return client.IdleMetrics.WithDefaults(util.MetricSettings{
+		Lookback: DefaultLookback,
+		Period:   DefaultPeriod,
+		Stat:     DefaultStatistic,
+	})
+}
+
+func (client *Client) AnalyzeIdleLoadBalancerWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
+	idleLoadBalancers, err := client.GetIdleLoadBalancers(ctx)
+	resourceErrs, err := util.SplitResourceErrors(err)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[LoadBalancerType]*util.Price)
+	priceErrs := make(map[LoadBalancerType]error)
+
+	var wastedResources []util.AWSWastedResource
+
+	for _, idleResource := range idleLoadBalancers {
+		idleLoadBalancer, ok := idleResource.R.(*LoadBalancer)
+		if !ok {
+			return nil, util.PricingError
+		}
+
+		loadBalancerType := idleLoadBalancer.loadBalancerType
+		price, ok := prices[loadBalancerType]
+		if !ok && priceErrs[loadBalancerType] == nil {
+			price, err = client.GetLoadBalancerPricing(ctx, region, loadBalancerType)
+			prices[loadBalancerType], priceErrs[loadBalancerType] = price, err
+		}
+		if err := priceErrs[loadBalancerType]; err != nil {
+			resourceErrs = resourceErrs.Append(idleLoadBalancer.name, util.NewResourceError(idleLoadBalancer.name, "GetProducts", err))
+			continue
+		}
+
+		// Capacity units are only charged for traffic, so an idle load balancer costs its hourly fee
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: idleResource,
+			Price:    *price,
+			Reason:   idleLoadBalancer.reason,
+			Evidence: idleLoadBalancer.evidence,
+		})
+	}
+
+	return wastedResources, resourceErrs.ErrorOrNil()
+}
+
+// GetIdleLoadBalancers returns the load balancers older than the lookback window that
+// have no registered targets or whose traffic never exceeded the min usage during the window
+func (client *Client) GetIdleLoadBalancers(ctx context.Context) ([]util.AWSResourceObject, error) {
+	createdBefore := time.Now().Add(-client.idleMetrics().Lookback)
+
+	var loadBalancers []*LoadBalancer
+
+	err := client.ELBV2.DescribeLoadBalancersPagesWithContext(ctx, &elbv2.DescribeLoadBalancersInput{},
+		func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+			for _, loadBalancer := range page.LoadBalancers {
+				if loadBalancer.State == nil || aws.StringValue(loadBalancer.State.Code) != elbv2.LoadBalancerStateEnumActive ||
+					loadBalancer.CreatedTime == nil || loadBalancer.CreatedTime.After(createdBefore) {
+					continue
+				}
+
+				if lb := client.newV2LoadBalancer(loadBalancer); lb != nil {
+					loadBalancers = append(loadBalancers, lb)
+				}
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.ELB.DescribeLoadBalancersPagesWithContext(ctx, &elb.DescribeLoadBalancersInput{},
+		func(page *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
+			for _, loadBalancer := range page.LoadBalancerDescriptions {
+				if loadBalancer.CreatedTime == nil || loadBalancer.CreatedTime.After(createdBefore) {
+					continue
+				}
+
+				loadBalancers = append(loadBalancers, client.newClassicLoadBalancer(loadBalancer))
+			}
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	idle := make([]bool, len(loadBalancers))
+	errs := make([]error, len(loadBalancers))
+	tagErrs := make([]error, len(loadBalancers))
+
+	err = util.ForEach(ctx, client.Concurrency, len(loadBalancers), func(i int) {
+		idle[i], errs[i] = client.isIdleLoadBalancer(ctx, loadBalancers[i])
+		if idle[i] && errs[i] == nil {
+			// The waste is still reported without its tags
+			loadBalancers[i].tags, tagErrs[i] = loadBalancers[i].listTags(ctx)
+		}
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var idleLoadBalancers []util.AWSResourceObject
+	var resourceErrs util.ResourceErrors
+	for i, loadBalancer := range loadBalancers {
+		if errs[i] != nil {
+			resourceErrs = resourceErrs.Append(loadBalancer.name, errs[i])
+			continue
+		}
+		if idle[i] {
+			idleLoadBalancers = append(idleLoadBalancers, util.AWSResourceObject{R: loadBalancer})
+		}
+		if tagErrs[i] != nil {
+			resourceErrs = resourceErrs.Append(loadBalancer.name, tagErrs[i])
+		}
+	}
+
+	return idleLoadBalancers, resourceErrs.ErrorOrNil()
+}
+
+// isIdleLoadBalancer checks the targets of a load balancer and then its traffic
+func (client *Client) isIdleLoadBalancer(ctx context.Context, loadBalancer *LoadBalancer) (bool, error) {
+	hasTargets, err := loadBalancer.targets(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !hasTargets {
+		loadBalancer.reason = util.Reason{
+			Rule:        RuleNoTargets,
+			Explanation: fmt.Sprintf("no targets are registered behind %s", loadBalancer.name),
+		}
+		loadBalancer.evidence[util.EvidenceTargetCount] = "0"
+		return true, nil
+	}
+
+	settings := client.idleMetrics()
+
+	results := make([]*util.MetricResult, len(loadBalancer.metrics))
+	names := make([]string, len(loadBalancer.metrics))
+	for i, metric := range loadBalancer.metrics {
+		result, err := util.GetMetric(ctx, client.Cloudwatch, metric)
+		if err != nil {
+			return false, util.NewResourceError(loadBalancer.name, "GetMetricData", err)
+		}
+		if result.Max > settings.MinUsage {
+			return false, nil
+		}
+		results[i] = result
+		names[i] = metric.MetricName
+	}
+
+	verb := "was"
+	if len(names) > 1 {
+		verb = "were"
+	}
+	loadBalancer.reason = util.Reason{
+		Rule:        RuleNoTraffic,
+		Explanation: fmt.Sprintf("%s %s 0 over %s", strings.Join(names, " and "), verb, util.FormatDuration(settings.Lookback)),
+	}
+	if settings.MinUsage > 0 {
+		loadBalancer.reason.Explanation = fmt.Sprintf("%s never exceeded %g every %s over %s",
+			strings.Join(names, " and "), settings.MinUsage, util.FormatDuration(settings.Period), util.FormatDuration(settings.Lookback))
+	}
+	for i, result := range results {
+		result.AddEvidence(loadBalancer.evidence, names[i], loadBalancer.metrics[i])
+	}
+	return true, nil
+}
+
+// newV2LoadBalancer describes an application or network load balancer, or returns nil for other types
+func (client *Client) newV2LoadBalancer(loadBalancer *elbv2.LoadBalancer) *LoadBalancer {
+	settings := client.idleMetrics()
+
+	// CloudWatch identifies a load balancer by the end of its ARN, e.g. app/my-alb/50dc6c495c0c9188
+	dimension := aws.StringValue(loadBalancer.LoadBalancerArn)
+	if i := strings.Index(dimension, ":loadbalancer/"); i >= 0 {
+		dimension = dimension[i+len(":loadbalancer/"):]
+	}
+
+	metric := util.MetricQuery{
+		ID:         "traffic",
+		Dimensions: map[string]string{"LoadBalancer": dimension},
+		Stat:       settings.Stat,
+		Period:     settings.Period,
+		Lookback:   settings.Lookback,
+	}
+
+	lb := &LoadBalancer{
+		name:     aws.StringValue(loadBalancer.LoadBalancerName),
+		evidence: map[string]string{util.EvidenceCreatedTime: util.FormatTime(aws.TimeValue(loadBalancer.CreatedTime))},
+		targets: func(ctx context.Context) (bool, error) {
+			return client.hasV2Targets(ctx, loadBalancer.LoadBalancerName, loadBalancer.LoadBalancerArn)
+		},
+		listTags: func(ctx context.Context) (map[string]string, error) {
+			return client.getV2Tags(ctx, loadBalancer.LoadBalancerName, loadBalancer.LoadBalancerArn)
+		},
+	}
+
+	switch aws.StringValue(loadBalancer.Type) {
+	case elbv2.LoadBalancerTypeEnumApplication:
+		lb.loadBalancerType = Application
+		metric.Namespace = "AWS/ApplicationELB"
+		metric.MetricName = "RequestCount"
+	case elbv2.LoadBalancerTypeEnumNetwork:
+		lb.loadBalancerType = Network
+		metric.Namespace = "AWS/NetworkELB"
+		metric.MetricName = "ProcessedBytes"
+	default:
+		return nil
+	}
+	lb.metrics = []util.MetricQuery{metric}
+
+	return lb
+}
+
+// newClassicLoadBalancer describes a classic load balancer
+func (client *Client) newClassicLoadBalancer(loadBalancer *elb.LoadBalancerDescription) *LoadBalancer {
+	settings := client.idleMetrics()
+
+	metric := func(name string) util.MetricQuery {
+		return util.MetricQuery{
+			ID:         "traffic",
+			Namespace:  "AWS/ELB",
+			MetricName: name,
+			Dimensions: map[string]string{"LoadBalancerName": aws.StringValue(loadBalancer.LoadBalancerName)},
+			Stat:       settings.Stat,
+			Period:     settings.Period,
+			Lookback:   settings.Lookback,
+		}
+	}
+
+	// Requests are only counted by HTTP listeners, while the bytes of every listener are
+	// estimated, so a load balancer mixing HTTP and TCP listeners must be quiet on both
+	var metrics []util.MetricQuery
+	for _, listener := range loadBalancer.ListenerDescriptions {
+		if listener.Listener == nil {
+			continue
+		}
+		switch strings.ToUpper(aws.StringValue(listener.Listener.Protocol)) {
+		case "HTTP", "HTTPS":
+			metrics = []util.MetricQuery{metric("RequestCount")}
+		}
+	}
+	metrics = append(metrics, metric("EstimatedProcessedBytes"))
+
+	return &LoadBalancer{
+		name:             aws.StringValue(loadBalancer.LoadBalancerName),
+		loadBalancerType: Classic,
+		evidence:         map[string]string{util.EvidenceCreatedTime: util.FormatTime(aws.TimeValue(loadBalancer.CreatedTime))},
+		metrics:          metrics,
+		targets: func(ctx context.Context) (bool, error) {
+			return len(loadBalancer.Instances) > 0, nil
+		},
+		listTags: func(ctx context.Context) (map[string]string, error) {
+			return client.getClassicTags(ctx, loadBalancer.LoadBalancerName)
+		},
+	}
+}
+
+// hasV2Targets tells whether any target group of a load balancer has a registered target
+func (client *Client) hasV2Targets(ctx context.Context, loadBalancerName *string, loadBalancerArn *string) (bool, error) {
+	var targetGroupArns []*string
+
+	err := client.ELBV2.DescribeTargetGroupsPagesWithContext(ctx, &elbv2.DescribeTargetGroupsInput{
+		LoadBalancerArn: loadBalancerArn,
+	}, func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+		for _, targetGroup := range page.TargetGroups {
+			targetGroupArns = append(targetGroupArns, targetGroup.TargetGroupArn)
+		}
+		return true
+	})
+
+	if err != nil {
+		return false, util.NewResourceError(aws.StringValue(loadBalancerName), "DescribeTargetGroups", err)
+	}
+
+	for _, targetGroupArn := range targetGroupArns {
+		resp, err := client.ELBV2.DescribeTargetHealthWithContext(ctx, &elbv2.DescribeTargetHealthInput{
+			TargetGroupArn: targetGroupArn,
+		})
+		if err != nil {
+			return false, util.NewResourceError(aws.StringValue(loadBalancerName), "DescribeTargetHealth", err)
+		}
+
+		if len(resp.TargetHealthDescriptions) > 0 {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getV2Tags returns the tags of an application or network load balancer by key
+func (client *Client) getV2Tags(ctx context.Context, loadBalancerName *string, loadBalancerArn *string) (map[string]string, error) {
+	resp, err := client.ELBV2.DescribeTagsWithContext(ctx, &elbv2.DescribeTagsInput{
+		ResourceArns: []*string{loadBalancerArn},
+	})
+	if err != nil {
+		return nil, util.NewResourceError(aws.StringValue(loadBalancerName), "DescribeTags", err)
+	}
+
+	tags := make(map[string]string)
+	for _, description := range resp.TagDescriptions {
+		for _, tag := range description.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// getClassicTags returns the tags of a classic load balancer by key
+func (client *Client) getClassicTags(ctx context.Context, loadBalancerName *string) (map[string]string, error) {
+	resp, err := client.ELB.DescribeTagsWithContext(ctx, &elb.DescribeTagsInput{
+		LoadBalancerNames: []*string{loadBalancerName},
+	})
+	if err != nil {
+		return nil, util.NewResourceError(aws.StringValue(loadBalancerName), "DescribeTags", err)
+	}
+
+	tags := make(map[string]string)
+	for _, description := range resp.TagDescriptions {
+		for _, tag := range description.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// termMatch returns a TERM_MATCH pricing filter
+func termMatch(field string, value string) *pricing.Filter {
+	return &pricing.Filter{
+		Type:  aws.String(pricing.FilterTypeTermMatch),
+		Field: aws.String(field),
+		Value: aws.String(value),
+	}
+}
+
+// GetLoadBalancerPricing returns the hourly fee of a load balancer type
+func (client *Client) GetLoadBalancerPricing(ctx context.Context, region string, loadBalancerType LoadBalancerType) (*util.Price, error) {
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.ELB,
+		Filters: []*pricing.Filter{
+			termMatch("productFamily", productFamilies[loadBalancerType]),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, priceItem := range priceItems {
+		// Usage types outside of us-east-1 are prefixed with the region, e.g. USW2-LoadBalancerUsage
+		if !strings.HasSuffix(priceItem.Product.Attributes.UsageType, UsageTypeLoadBalancerHours) {
+			continue
+		}
+
+		dimension, err := priceItem.UnboundedDimension()
+		if err != nil {
+			return nil, err
+		}
+
+		if dimension.Unit != "Hrs" {
+			return nil, util.PricingError
+		}
+
+		rate, err := dimension.Rate()
+		if err != nil {
+			return nil, err
+		}
+
+		return &util.Price{
+			Unit: "Hr",
+			Rate: rate,
+		}, nil
+	}
+
+	return nil, errors.Wrap(util.NoResourceFoundError, fmt.Sprintf("no hourly price for %s", loadBalancerType))
+}
@@ -0,0 +1,400 @@
+package elb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	pricingTest "github.com/cloudwaste/cloudwaste/pkg/aws/pricing/test"
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+type mockedELB struct {
+	mock.Mock
+	elbiface.ELBAPI
+}
+
+type mockedELBV2 struct {
+	mock.Mock
+	elbv2iface.ELBV2API
+}
+
+type mockedCloudwatch struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (m *mockedELB) DescribeLoadBalancersPagesWithContext(ctx context.Context, input *elb.DescribeLoadBalancersInput, fn func(*elb.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*elb.DescribeLoadBalancersOutput), true)
+	}
+	return args.Error(1)
+}
+
+func (m *mockedELBV2) DescribeLoadBalancersPagesWithContext(ctx context.Context, input *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*elbv2.DescribeLoadBalancersOutput), true)
+	}
+	return args.Error(1)
+}
+
+// targetGroups holds the target groups of each load balancer by ARN
+var targetGroups = map[string][]string{
+	"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/busy-alb/1":  {"tg-busy"},
+	"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/quiet-alb/2": {"tg-quiet"},
+	"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/net/empty-nlb/3": {"tg-empty"},
+}
+
+// targets holds the number of registered targets by target group
+var targets = map[string]int{
+	"tg-busy":  2,
+	"tg-quiet": 1,
+	"tg-empty": 0,
+}
+
+// traffic holds the daily traffic data points by CloudWatch load balancer dimension and metric
+var traffic = map[string][]float64{
+	"app/busy-alb/1/RequestCount":       {0, 1500},
+	"app/quiet-alb/2/RequestCount":      {0, 0},
+	"busy-clb/RequestCount":             {10},
+	"mixed-clb/RequestCount":            {0},
+	"mixed-clb/EstimatedProcessedBytes": {0, 5000000},
+}
+
+func (m *mockedELBV2) DescribeTargetGroupsPagesWithContext(ctx context.Context, input *elbv2.DescribeTargetGroupsInput, fn func(*elbv2.DescribeTargetGroupsOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	output := &elbv2.DescribeTargetGroupsOutput{}
+	for _, arn := range targetGroups[*input.LoadBalancerArn] {
+		output.TargetGroups = append(output.TargetGroups, &elbv2.TargetGroup{TargetGroupArn: aws.String(arn)})
+	}
+
+	if args.Error(0) == nil {
+		fn(output, true)
+	}
+	return args.Error(0)
+}
+
+func (m *mockedELBV2) DescribeTargetHealthWithContext(ctx context.Context, input *elbv2.DescribeTargetHealthInput, options ...request.Option) (*elbv2.DescribeTargetHealthOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	output := &elbv2.DescribeTargetHealthOutput{}
+	for i := 0; i < targets[*input.TargetGroupArn]; i++ {
+		output.TargetHealthDescriptions = append(output.TargetHealthDescriptions, &elbv2.TargetHealthDescription{})
+	}
+
+	return output, args.Error(0)
+}
+
+// loadBalancerTags holds the tags of each load balancer by name
+var loadBalancerTags = map[string]map[string]string{
+	"empty-nlb": {"env": "dr"},
+	"empty-clb": {"partner": "acme"},
+}
+
+func (m *mockedELBV2) DescribeTagsWithContext(ctx context.Context, input *elbv2.DescribeTagsInput, options ...request.Option) (*elbv2.DescribeTagsOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	// The name of a load balancer is the second to last part of its ARN
+	parts := strings.Split(*input.ResourceArns[0], "/")
+	description := &elbv2.TagDescription{ResourceArn: input.ResourceArns[0]}
+	for key, value := range loadBalancerTags[parts[len(parts)-2]] {
+		description.Tags = append(description.Tags, &elbv2.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	return &elbv2.DescribeTagsOutput{TagDescriptions: []*elbv2.TagDescription{description}}, args.Error(0)
+}
+
+func (m *mockedELB) DescribeTagsWithContext(ctx context.Context, input *elb.DescribeTagsInput, options ...request.Option) (*elb.DescribeTagsOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	description := &elb.TagDescription{LoadBalancerName: input.LoadBalancerNames[0]}
+	for key, value := range loadBalancerTags[*input.LoadBalancerNames[0]] {
+		description.Tags = append(description.Tags, &elb.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	return &elb.DescribeTagsOutput{TagDescriptions: []*elb.TagDescription{description}}, args.Error(0)
+}
+
+func (m *mockedCloudwatch) GetMetricDataWithContext(ctx context.Context, input *cloudwatch.GetMetricDataInput, options ...request.Option) (*cloudwatch.GetMetricDataOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	metric := input.MetricDataQueries[0].MetricStat.Metric
+	return &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []*cloudwatch.MetricDataResult{{
+			Id:     aws.String("traffic"),
+			Values: aws.Float64Slice(traffic[*metric.Dimensions[0].Value+"/"+*metric.MetricName]),
+		}},
+	}, args.Error(0)
+}
+
+type ELBTestSuite struct {
+	suite.Suite
+	e      *mockedELB
+	e2     *mockedELBV2
+	c      *mockedCloudwatch
+	p      *pricingTest.MockedPricingInterface
+	region string
+	client Client
+}
+
+func (suite *ELBTestSuite) SetupTest() {
+	suite.e = new(mockedELB)
+	suite.e2 = new(mockedELBV2)
+	suite.c = new(mockedCloudwatch)
+	suite.p = new(pricingTest.MockedPricingInterface)
+	suite.region = "us-east-1"
+	suite.client = Client{ELB: suite.e, ELBV2: suite.e2, Cloudwatch: suite.c, Pricing: suite.p}
+}
+
+func (suite *ELBTestSuite) MockLoadBalancers() {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	young := time.Now().Add(-time.Hour)
+
+	newV2 := func(name string, loadBalancerType string, arn string, created time.Time) *elbv2.LoadBalancer {
+		return &elbv2.LoadBalancer{
+			LoadBalancerName: aws.String(name),
+			LoadBalancerArn:  aws.String(arn),
+			Type:             aws.String(loadBalancerType),
+			CreatedTime:      aws.Time(created),
+			State:            &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumActive)},
+		}
+	}
+
+	suite.e2.On("DescribeLoadBalancersPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&elbv2.DescribeLoadBalancersOutput{
+			LoadBalancers: []*elbv2.LoadBalancer{
+				newV2("busy-alb", elbv2.LoadBalancerTypeEnumApplication, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/busy-alb/1", old),
+				newV2("quiet-alb", elbv2.LoadBalancerTypeEnumApplication, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/quiet-alb/2", old),
+				newV2("empty-nlb", elbv2.LoadBalancerTypeEnumNetwork, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/net/empty-nlb/3", old),
+				newV2("young-alb", elbv2.LoadBalancerTypeEnumApplication, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/young-alb/4", young),
+				newV2("gateway", elbv2.LoadBalancerTypeEnumGateway, "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/gwy/gateway/5", old),
+			},
+		}, nil)
+	suite.e2.On("DescribeTargetGroupsPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.e2.On("DescribeTargetHealthWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.e2.On("DescribeTagsWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.e.On("DescribeTagsWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	suite.e.On("DescribeLoadBalancersPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&elb.DescribeLoadBalancersOutput{
+			LoadBalancerDescriptions: []*elb.LoadBalancerDescription{
+				{
+					LoadBalancerName: aws.String("busy-clb"),
+					CreatedTime:      aws.Time(old),
+					Instances:        []*elb.Instance{{InstanceId: aws.String("i-1")}},
+					ListenerDescriptions: []*elb.ListenerDescription{
+						{Listener: &elb.Listener{Protocol: aws.String("HTTP")}},
+					},
+				},
+				{ // serves TCP traffic alongside an unused HTTP listener
+					LoadBalancerName: aws.String("mixed-clb"),
+					CreatedTime:      aws.Time(old),
+					Instances:        []*elb.Instance{{InstanceId: aws.String("i-2")}},
+					ListenerDescriptions: []*elb.ListenerDescription{
+						{Listener: &elb.Listener{Protocol: aws.String("HTTP")}},
+						{Listener: &elb.Listener{Protocol: aws.String("TCP")}},
+					},
+				},
+				{
+					LoadBalancerName: aws.String("empty-clb"),
+					CreatedTime:      aws.Time(old),
+				},
+			},
+		}, nil)
+}
+
+func (suite *ELBTestSuite) TestGetIdleLoadBalancers() {
+	assert := assert.New(suite.T())
+
+	suite.MockLoadBalancers()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	idleLoadBalancers, err := suite.client.GetIdleLoadBalancers(context.TODO())
+	assert.Nil(err)
+
+	var ids []string
+	for _, idle := range idleLoadBalancers {
+		ids = append(ids, idle.R.ID())
+	}
+	assert.Equal([]string{"quiet-alb", "empty-nlb", "empty-clb"}, ids)
+	assert.Equal(string(Network), idleLoadBalancers[1].R.Type())
+	assert.Equal(string(Classic), idleLoadBalancers[2].R.Type())
+	assert.Equal(map[string]string{}, idleLoadBalancers[0].R.Tags())
+	assert.Equal(map[string]string{"env": "dr"}, idleLoadBalancers[1].R.Tags())
+	assert.Equal(map[string]string{"partner": "acme"}, idleLoadBalancers[2].R.Tags())
+
+	// Only load balancers with targets have their traffic checked, classic load balancers
+	// with an HTTP listener on both requests and bytes until either shows traffic
+	suite.c.AssertNumberOfCalls(suite.T(), "GetMetricDataWithContext", 5)
+	metricNames := make(map[string][]string)
+	for _, call := range suite.c.Calls {
+		input := call.Arguments.Get(1).(*cloudwatch.GetMetricDataInput)
+		metric := input.MetricDataQueries[0].MetricStat.Metric
+		metricNames[*metric.Dimensions[0].Value] = append(metricNames[*metric.Dimensions[0].Value], *metric.MetricName)
+		if *metric.Dimensions[0].Value == "busy-clb" {
+			assert.Equal("AWS/ELB", *metric.Namespace)
+		}
+	}
+	assert.Equal([]string{"RequestCount"}, metricNames["busy-clb"])
+	assert.Equal([]string{"RequestCount", "EstimatedProcessedBytes"}, metricNames["mixed-clb"])
+	assert.Equal("0", idleLoadBalancers[0].R.(*LoadBalancer).evidence["RequestCount.Sum.max"])
+
+	// Traffic up to the min usage is idle
+	suite.c = new(mockedCloudwatch)
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.client.Cloudwatch = suite.c
+	suite.client.IdleMetrics = util.MetricSettings{Period: time.Hour, MinUsage: 10}
+
+	idleLoadBalancers, err = suite.client.GetIdleLoadBalancers(context.TODO())
+	assert.Nil(err)
+	assert.Equal(4, len(idleLoadBalancers))
+	for _, idle := range idleLoadBalancers {
+		if idle.R.ID() == "busy-clb" {
+			lb := idle.R.(*LoadBalancer)
+			assert.Contains(lb.reason.Explanation, "RequestCount and EstimatedProcessedBytes never exceeded 10")
+			assert.Equal("10", lb.evidence["RequestCount.Sum.max"])
+			assert.Contains(lb.evidence, "EstimatedProcessedBytes.Sum.max")
+		}
+	}
+	input := suite.c.Calls[0].Arguments.Get(1).(*cloudwatch.GetMetricDataInput)
+	assert.Equal(int64(3600), aws.Int64Value(input.MetricDataQueries[0].MetricStat.Period))
+	suite.client.IdleMetrics = util.MetricSettings{}
+
+	// Test error cases
+	suite.c = new(mockedCloudwatch)
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("error"))
+	suite.client.Cloudwatch = suite.c
+
+	// Load balancers without targets are still idle when the traffic of the others can't be checked
+	idleLoadBalancers, err = suite.client.GetIdleLoadBalancers(context.TODO())
+	assert.Equal(2, len(idleLoadBalancers))
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(4, len(resourceErrs)) {
+		assert.Equal("busy-alb", resourceErrs[0].ResourceID)
+		assert.Equal("GetMetricData", resourceErrs[0].Operation)
+	}
+}
+
+func (suite *ELBTestSuite) TestAnalyzeIdleLoadBalancerWaste() {
+	assert := assert.New(suite.T())
+
+	suite.MockLoadBalancers()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{
+			{
+				Product: pricing.AWSPriceItemProduct{
+					Attributes: pricing.AWSPriceItemProductAttributes{UsageType: "USE1-LCUUsage"},
+				},
+			},
+			{
+				Product: pricing.AWSPriceItemProduct{
+					Attributes: pricing.AWSPriceItemProductAttributes{UsageType: "LoadBalancerUsage"},
+				},
+				Terms: pricing.AWSPriceItemTerms{
+					OnDemand: map[string]pricing.AWSPriceItemOnDemand{
+						"1": {PriceDimensions: map[string]pricing.AWSPriceItemPriceDimension{
+							"1.1": {
+								BeginRange:   "0",
+								EndRange:     "Inf",
+								Unit:         "Hrs",
+								PricePerUnit: pricing.AWSPriceItemPricePerUnit{USD: "0.0225000000"},
+							},
+						}},
+					},
+				},
+			},
+		}, nil)
+
+	wastedResources, err := suite.client.AnalyzeIdleLoadBalancerWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+	if assert.Equal(3, len(wastedResources)) {
+		for _, wastedResource := range wastedResources {
+			assert.Equal(util.Price{Unit: "Hr", Rate: 0.0225}, wastedResource.Price)
+			assert.NotEmpty(wastedResource.Reason.Explanation)
+			assert.Contains(wastedResource.Evidence, "CreatedTime")
+		}
+	}
+
+	// Each load balancer type is priced once
+	suite.p.AssertNumberOfCalls(suite.T(), "GetProducts", 3)
+}
+
+func (suite *ELBTestSuite) TestAnalyzeIdleLoadBalancerWastePricingError() {
+	assert := assert.New(suite.T())
+
+	suite.MockLoadBalancers()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.p.On("GetProducts", mock.Anything, mock.MatchedBy(func(input *pricing.GetProductsInput) bool {
+		return aws.StringValue(input.Filters[0].Value) == productFamilies[Classic]
+	})).Return(nil, errors.New("error"))
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{{
+			Product: pricing.AWSPriceItemProduct{
+				Attributes: pricing.AWSPriceItemProductAttributes{UsageType: "LoadBalancerUsage"},
+			},
+			Terms: pricing.AWSPriceItemTerms{
+				OnDemand: map[string]pricing.AWSPriceItemOnDemand{
+					"1": {PriceDimensions: map[string]pricing.AWSPriceItemPriceDimension{
+						"1.1": {BeginRange: "0", EndRange: "Inf", Unit: "Hrs", PricePerUnit: pricing.AWSPriceItemPricePerUnit{USD: "0.0225000000"}},
+					}},
+				},
+			},
+		}}, nil)
+
+	// Classic load balancers can't be priced, the others are still reported
+	wastedResources, err := suite.client.AnalyzeIdleLoadBalancerWaste(context.TODO(), suite.region)
+	assert.Equal(2, len(wastedResources))
+
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("empty-clb", resourceErrs[0].ResourceID)
+		assert.Equal("GetProducts", resourceErrs[0].Operation)
+	}
+}
+
+func (suite *ELBTestSuite) TestGetLoadBalancerPricingError() {
+	assert := assert.New(suite.T())
+
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{}, nil)
+
+	price, err := suite.client.GetLoadBalancerPricing(context.TODO(), suite.region, Application)
+	assert.Nil(price)
+	assert.True(errors.Is(err, util.NoResourceFoundError))
+}
+
+func TestELBTestSuite(t *testing.T) {
+	suite.Run(t, new(ELBTestSuite))
+}
@@ -0,0 +1,224 @@
+package pricing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+const (
+	// DefaultCacheTTL is how long cached price lists are used before being fetched again
+	DefaultCacheTTL = 24 * time.Hour
+
+	// cacheVersion is hashed into every key. Bump it whenever the persisted price items
+	// change shape, so older entries are fetched again rather than decoded wrongly.
+	cacheVersion = 1
+)
+
+// CachedClient is a PricingInterface that keeps the price lists returned by another
+// PricingInterface in memory and, when Dir is set, on disk for TTL
+type CachedClient struct {
+	Pricing PricingInterface
+	// Dir is the directory price lists are persisted to. Empty disables persistence.
+	Dir string
+	TTL time.Duration
+	// Refresh ignores price lists persisted by earlier runs
+	Refresh bool
+
+	mu     sync.Mutex
+	locks  map[string]*sync.Mutex
+	memory map[string][]*AWSPriceItem
+}
+
+type cacheEntry struct {
+	CreatedAt time.Time       `json:"createdAt"`
+	Items     []*AWSPriceItem `json:"items"`
+}
+
+type cacheKey struct {
+	Version     int         `json:"version"`
+	Region      string      `json:"region"`
+	ServiceCode ServiceCode `json:"serviceCode"`
+	Filters     [][3]string `json:"filters"`
+}
+
+// DefaultCacheDir returns the directory for persisted price lists under the user cache dir
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "cloudwaste", "pricing"), nil
+}
+
+func (client *CachedClient) GetProducts(ctx context.Context, options *GetProductsInput) ([]*AWSPriceItem, error) {
+	key, err := client.key(options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only one lookup per key at a time so concurrent analyzers share a single fetch
+	unlock := client.lock(key)
+	defer unlock()
+
+	if priceItems, ok := client.fromMemory(key); ok {
+		return priceItems, nil
+	}
+
+	if priceItems, ok := client.fromDisk(key); ok {
+		client.toMemory(key, priceItems)
+		return priceItems, nil
+	}
+
+	priceItems, err := client.Pricing.GetProducts(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	client.toMemory(key, priceItems)
+	// Persisting is best effort, a read-only cache dir only makes the next run slower
+	_ = client.toDisk(key, priceItems)
+
+	return priceItems, nil
+}
+
+// key hashes the cache version and the service code, region and filters of a request.
+// Filter order doesn't matter.
+func (client *CachedClient) key(options *GetProductsInput) (string, error) {
+	k := cacheKey{
+		Version:     cacheVersion,
+		Region:      options.Region,
+		ServiceCode: options.ServiceCode,
+		Filters:     [][3]string{},
+	}
+	for _, filter := range options.Filters {
+		k.Filters = append(k.Filters, [3]string{
+			aws.StringValue(filter.Type),
+			aws.StringValue(filter.Field),
+			aws.StringValue(filter.Value),
+		})
+	}
+	sort.Slice(k.Filters, func(i, j int) bool {
+		for f := range k.Filters[i] {
+			if k.Filters[i][f] != k.Filters[j][f] {
+				return k.Filters[i][f] < k.Filters[j][f]
+			}
+		}
+		return false
+	})
+
+	b, err := json.Marshal(k)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (client *CachedClient) lock(key string) func() {
+	client.mu.Lock()
+	if client.locks == nil {
+		client.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := client.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		client.locks[key] = l
+	}
+	client.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+func (client *CachedClient) fromMemory(key string) ([]*AWSPriceItem, bool) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	priceItems, ok := client.memory[key]
+	return priceItems, ok
+}
+
+func (client *CachedClient) toMemory(key string, priceItems []*AWSPriceItem) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if client.memory == nil {
+		client.memory = make(map[string][]*AWSPriceItem)
+	}
+	client.memory[key] = priceItems
+}
+
+func (client *CachedClient) path(key string) string {
+	return filepath.Join(client.Dir, key+".json")
+}
+
+// fromDisk returns the persisted price list if it exists and hasn't expired.
+// Unreadable entries are treated as missing and get overwritten.
+func (client *CachedClient) fromDisk(key string) ([]*AWSPriceItem, bool) {
+	if client.Dir == "" || client.Refresh {
+		return nil, false
+	}
+
+	b, err := ioutil.ReadFile(client.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return nil, false
+	}
+
+	if time.Since(entry.CreatedAt) > client.TTL {
+		return nil, false
+	}
+
+	return entry.Items, true
+}
+
+func (client *CachedClient) toDisk(key string, priceItems []*AWSPriceItem) error {
+	if client.Dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(client.Dir, 0755); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(cacheEntry{
+		CreatedAt: time.Now(),
+		Items:     priceItems,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a concurrent run never reads a partial entry
+	f, err := ioutil.TempFile(client.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), client.path(key))
+}
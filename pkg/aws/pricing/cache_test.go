@@ -0,0 +1,162 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type mockedPricingInterface struct {
+	mock.Mock
+}
+
+func (m *mockedPricingInterface) GetProducts(ctx context.Context, options *GetProductsInput) ([]*AWSPriceItem, error) {
+	args := m.Called(ctx, options)
+
+	if args.Error(1) == nil {
+		return args.Get(0).([]*AWSPriceItem), nil
+	}
+	return nil, args.Error(1)
+}
+
+type CacheTestSuite struct {
+	suite.Suite
+	mockedPricing *mockedPricingInterface
+	dir           string
+	input         *GetProductsInput
+	priceItems    []*AWSPriceItem
+}
+
+func (suite *CacheTestSuite) SetupTest() {
+	dir, err := ioutil.TempDir("", "cloudwaste-pricing")
+	suite.Require().Nil(err)
+
+	suite.dir = dir
+	suite.mockedPricing = new(mockedPricingInterface)
+	suite.input = &GetProductsInput{
+		Region:      "us-east-1",
+		ServiceCode: EC2,
+		Filters: []*pricing.Filter{
+			{
+				Type:  aws.String("TERM_MATCH"),
+				Field: aws.String("productFamily"),
+				Value: aws.String("Storage"),
+			},
+			{
+				Type:  aws.String("TERM_MATCH"),
+				Field: aws.String("volumeApiName"),
+				Value: aws.String("gp2"),
+			},
+		},
+	}
+	suite.priceItems = []*AWSPriceItem{
+		{
+			Product: AWSPriceItemProduct{
+				Attributes: AWSPriceItemProductAttributes{
+					Location:  "US East (N. Virginia)",
+					UsageType: "EBS:VolumeUsage.gp2",
+				},
+			},
+		},
+	}
+}
+
+func (suite *CacheTestSuite) TearDownTest() {
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *CacheTestSuite) newClient() *CachedClient {
+	return &CachedClient{
+		Pricing: suite.mockedPricing,
+		Dir:     suite.dir,
+		TTL:     time.Hour,
+	}
+}
+
+func (suite *CacheTestSuite) TestGetProductsMemory() {
+	assert := assert.New(suite.T())
+
+	suite.mockedPricing.On("GetProducts", mock.Anything, mock.Anything).
+		Return(suite.priceItems, nil).Once()
+
+	client := &CachedClient{Pricing: suite.mockedPricing}
+
+	priceItems, err := client.GetProducts(context.Background(), suite.input)
+	assert.Nil(err)
+	assert.Equal(suite.priceItems, priceItems)
+
+	// Filters in a different order hit the same entry
+	reordered := *suite.input
+	reordered.Filters = []*pricing.Filter{suite.input.Filters[1], suite.input.Filters[0]}
+
+	priceItems, err = client.GetProducts(context.Background(), &reordered)
+	assert.Nil(err)
+	assert.Equal(suite.priceItems, priceItems)
+
+	suite.mockedPricing.AssertNumberOfCalls(suite.T(), "GetProducts", 1)
+}
+
+func (suite *CacheTestSuite) TestGetProductsDisk() {
+	assert := assert.New(suite.T())
+
+	suite.mockedPricing.On("GetProducts", mock.Anything, mock.Anything).
+		Return(suite.priceItems, nil).Once()
+
+	_, err := suite.newClient().GetProducts(context.Background(), suite.input)
+	assert.Nil(err)
+
+	// A new client, like the next run, reads from disk
+	priceItems, err := suite.newClient().GetProducts(context.Background(), suite.input)
+	assert.Nil(err)
+	assert.Equal(suite.priceItems, priceItems)
+	suite.mockedPricing.AssertNumberOfCalls(suite.T(), "GetProducts", 1)
+
+	// Refresh and expired entries are fetched again
+	suite.mockedPricing.On("GetProducts", mock.Anything, mock.Anything).
+		Return(suite.priceItems, nil).Twice()
+
+	client := suite.newClient()
+	client.Refresh = true
+	_, err = client.GetProducts(context.Background(), suite.input)
+	assert.Nil(err)
+
+	client = suite.newClient()
+	client.TTL = 0
+	_, err = client.GetProducts(context.Background(), suite.input)
+	assert.Nil(err)
+
+	suite.mockedPricing.AssertNumberOfCalls(suite.T(), "GetProducts", 3)
+}
+
+func (suite *CacheTestSuite) TestGetProductsError() {
+	assert := assert.New(suite.T())
+
+	suite.mockedPricing.On("GetProducts", mock.Anything, mock.Anything).
+		Return(nil, errors.New("error")).Once()
+	suite.mockedPricing.On("GetProducts", mock.Anything, mock.Anything).
+		Return(suite.priceItems, nil).Once()
+
+	client := suite.newClient()
+
+	priceItems, err := client.GetProducts(context.Background(), suite.input)
+	assert.NotNil(err)
+	assert.Nil(priceItems)
+
+	// Errors aren't cached
+	priceItems, err = client.GetProducts(context.Background(), suite.input)
+	assert.Nil(err)
+	assert.Equal(suite.priceItems, priceItems)
+}
+
+func TestCacheTestSuite(t *testing.T) {
+	suite.Run(t, new(CacheTestSuite))
+}
@@ -0,0 +1,144 @@
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	NoPriceDimensionError       = errors.New("Couldn't find a price dimension")
+	couldntParsePriceError      = errors.New("Couldn't parse price")
+	couldntParsePriceRangeError = errors.New("Couldn't parse price range")
+)
+
+// Rate parses the price per unit, in USD or in CNY for the China regions
+func (p AWSPriceItemPricePerUnit) Rate() (float64, error) {
+	price := p.USD
+	if price == "" {
+		price = p.CNY
+	}
+
+	rate, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return 0, errors.Wrap(couldntParsePriceError, err.Error())
+	}
+
+	return rate, nil
+}
+
+// Rate parses the price per unit of the dimension
+func (d AWSPriceItemPriceDimension) Rate() (float64, error) {
+	return d.PricePerUnit.Rate()
+}
+
+// Range parses the tier of usage the dimension applies to, an EndRange of "Inf" is unbounded
+func (d AWSPriceItemPriceDimension) Range() (begin float64, end float64, err error) {
+	begin, err = parseRange(d.BeginRange, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	end, err = parseRange(d.EndRange, math.Inf(1))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return begin, end, nil
+}
+
+func parseRange(value string, empty float64) (float64, error) {
+	switch value {
+	case "":
+		return empty, nil
+	case "Inf":
+		return math.Inf(1), nil
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, errors.Wrap(couldntParsePriceRangeError, fmt.Sprintf("%q", value))
+	}
+
+	return f, nil
+}
+
+// Contains reports whether quantity falls in the tier [BeginRange, EndRange) of the dimension
+func (d AWSPriceItemPriceDimension) Contains(quantity float64) (bool, error) {
+	begin, end, err := d.Range()
+	if err != nil {
+		return false, err
+	}
+
+	return begin <= quantity && (quantity < end || math.IsInf(end, 1)), nil
+}
+
+// Dimensions returns every on demand price dimension of the price item ordered by tier
+func (item *AWSPriceItem) Dimensions() ([]AWSPriceItemPriceDimension, error) {
+	var dimensions []AWSPriceItemPriceDimension
+	var begins []float64
+
+	for _, term := range item.Terms.OnDemand {
+		for _, dimension := range term.PriceDimensions {
+			begin, _, err := dimension.Range()
+			if err != nil {
+				return nil, err
+			}
+
+			dimensions = append(dimensions, dimension)
+			begins = append(begins, begin)
+		}
+	}
+
+	sort.Sort(dimensionsByBegin{dimensions, begins})
+
+	return dimensions, nil
+}
+
+type dimensionsByBegin struct {
+	dimensions []AWSPriceItemPriceDimension
+	begins     []float64
+}
+
+func (d dimensionsByBegin) Len() int {
+	return len(d.dimensions)
+}
+
+func (d dimensionsByBegin) Less(i, j int) bool {
+	return d.begins[i] < d.begins[j]
+}
+
+func (d dimensionsByBegin) Swap(i, j int) {
+	d.dimensions[i], d.dimensions[j] = d.dimensions[j], d.dimensions[i]
+	d.begins[i], d.begins[j] = d.begins[j], d.begins[i]
+}
+
+// Dimension returns the on demand price dimension of the tier quantity falls in, e.g.
+// Dimension(1) skips a free tier covering [0, 1)
+func (item *AWSPriceItem) Dimension(quantity float64) (*AWSPriceItemPriceDimension, error) {
+	dimensions, err := item.Dimensions()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range dimensions {
+		ok, err := dimensions[i].Contains(quantity)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &dimensions[i], nil
+		}
+	}
+
+	return nil, errors.Wrap(NoPriceDimensionError, fmt.Sprintf("for quantity %v", quantity))
+}
+
+// UnboundedDimension returns the on demand price dimension of the last, unbounded tier,
+// which is the price once any free tier is used up
+func (item *AWSPriceItem) UnboundedDimension() (*AWSPriceItemPriceDimension, error) {
+	return item.Dimension(math.Inf(1))
+}
@@ -0,0 +1,94 @@
+package pricing
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func tieredPriceItem() *AWSPriceItem {
+	return &AWSPriceItem{
+		Terms: AWSPriceItemTerms{
+			OnDemand: map[string]AWSPriceItemOnDemand{
+				"1": {
+					PriceDimensions: map[string]AWSPriceItemPriceDimension{
+						"1.3": {
+							BeginRange:   "100",
+							EndRange:     "Inf",
+							Unit:         "Hrs",
+							PricePerUnit: AWSPriceItemPricePerUnit{USD: "0.0050000000"},
+						},
+						"1.1": {
+							BeginRange:   "0",
+							EndRange:     "1",
+							Unit:         "Hrs",
+							PricePerUnit: AWSPriceItemPricePerUnit{USD: "0.0000000000"},
+						},
+						"1.2": {
+							BeginRange:   "1",
+							EndRange:     "100",
+							Unit:         "Hrs",
+							PricePerUnit: AWSPriceItemPricePerUnit{USD: "0.1000000000"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDimensions(t *testing.T) {
+	assert := assert.New(t)
+
+	dimensions, err := tieredPriceItem().Dimensions()
+	assert.Nil(err)
+	if assert.Equal(3, len(dimensions)) {
+		assert.Equal("0", dimensions[0].BeginRange)
+		assert.Equal("1", dimensions[1].BeginRange)
+		assert.Equal("100", dimensions[2].BeginRange)
+	}
+}
+
+func TestDimension(t *testing.T) {
+	assert := assert.New(t)
+
+	item := tieredPriceItem()
+
+	for quantity, expectedRate := range map[float64]float64{
+		0:    0,
+		0.5:  0,
+		1:    0.1,
+		99:   0.1,
+		100:  0.005,
+		1e12: 0.005,
+	} {
+		dimension, err := item.Dimension(quantity)
+		if assert.Nil(err) {
+			rate, err := dimension.Rate()
+			assert.Nil(err)
+			assert.Equal(expectedRate, rate, "quantity %v", quantity)
+		}
+	}
+
+	dimension, err := item.UnboundedDimension()
+	if assert.Nil(err) {
+		assert.Equal("100", dimension.BeginRange)
+	}
+
+	// Test error cases
+	dimension, err = item.Dimension(-1)
+	assert.Nil(dimension)
+	assert.True(errors.Is(err, NoPriceDimensionError))
+
+	dimension, err = (&AWSPriceItem{}).UnboundedDimension()
+	assert.Nil(dimension)
+	assert.True(errors.Is(err, NoPriceDimensionError))
+
+	item.Terms.OnDemand["1"].PriceDimensions["1.1"] = AWSPriceItemPriceDimension{BeginRange: "zero"}
+	_, err = item.Dimensions()
+	assert.True(errors.Is(err, couldntParsePriceRangeError))
+
+	_, err = AWSPriceItemPriceDimension{PricePerUnit: AWSPriceItemPricePerUnit{USD: "free"}}.Rate()
+	assert.True(errors.Is(err, couldntParsePriceError))
+}
@@ -0,0 +1,490 @@
+package pricing
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/pkg/errors"
+)
+
+var (
+	OfferFileNotFoundError = errors.New("Couldn't find an offer file for the service")
+	UnknownFilterTypeError = errors.New("Unknown pricing filter type")
+	couldntParseOfferError = errors.New("Couldn't parse offer file")
+)
+
+const (
+	termTypeOnDemand = "OnDemand"
+)
+
+// FileClient is a PricingInterface that reads AWS Price List bulk offer files in
+// the JSON or CSV format instead of calling the Pricing API. The offer file of each
+// service is only read once, its products are then looked up by the filter fields.
+type FileClient struct {
+	// Path is either a single offer file or a directory holding <ServiceCode>.json,
+	// <ServiceCode>.csv or the <ServiceCode>/current/index.{json,csv} layout of the bulk API
+	Path string
+
+	mu     sync.Mutex
+	offers map[ServiceCode]*offerIndex
+}
+
+// offerProduct is a product of an offer file and its on demand terms, laid out
+// like a product returned by the Pricing API
+type offerProduct struct {
+	ProductFamily string
+	SKU           string
+	Attributes    map[string]interface{}
+	OnDemand      map[string]interface{}
+}
+
+// offerIndex holds the products of an offer file, read on first use, along with an index
+// of them by the value of each field filtered on so far
+type offerIndex struct {
+	once     sync.Once
+	err      error
+	products []*offerProduct
+
+	mu      sync.Mutex
+	byField map[string]map[string][]*offerProduct
+}
+
+func (client *FileClient) GetProducts(ctx context.Context, options *GetProductsInput) ([]*AWSPriceItem, error) {
+	filters, _, err := options.filters()
+	if err != nil {
+		return nil, err
+	}
+	for _, filter := range filters {
+		if aws.StringValue(filter.Type) != pricing.FilterTypeTermMatch {
+			return nil, errors.Wrap(UnknownFilterTypeError, aws.StringValue(filter.Type))
+		}
+	}
+
+	offer, err := client.offer(options.ServiceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	products := offer.lookup(filters)
+
+	priceItems := make([]*AWSPriceItem, 0, len(products))
+	for _, product := range products {
+		priceItem, err := decodePriceItem(aws.JSONValue{
+			"serviceCode": string(options.ServiceCode),
+			"product": map[string]interface{}{
+				"productFamily": product.ProductFamily,
+				"sku":           product.SKU,
+				"attributes":    product.Attributes,
+			},
+			"terms": map[string]interface{}{
+				termTypeOnDemand: product.OnDemand,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		priceItems = append(priceItems, priceItem)
+	}
+
+	return priceItems, nil
+}
+
+// offer returns the products of the offer file of a service, reading it on first use
+func (client *FileClient) offer(serviceCode ServiceCode) (*offerIndex, error) {
+	client.mu.Lock()
+	if client.offers == nil {
+		client.offers = make(map[ServiceCode]*offerIndex)
+	}
+	offer, ok := client.offers[serviceCode]
+	if !ok {
+		offer = &offerIndex{byField: make(map[string]map[string][]*offerProduct)}
+		client.offers[serviceCode] = offer
+	}
+	client.mu.Unlock()
+
+	// Other services are read meanwhile, while callers of this one wait for it
+	offer.once.Do(func() {
+		offer.products, offer.err = client.readOffer(serviceCode)
+	})
+
+	return offer, offer.err
+}
+
+// readOffer reads every product of the offer file of a service, sorted by SKU
+func (client *FileClient) readOffer(serviceCode ServiceCode) ([]*offerProduct, error) {
+	path, err := client.offerFile(serviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var products map[string]*offerProduct
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		products, err = readCSVOffer(f, serviceCode)
+	} else {
+		products, err = readJSONOffer(f, serviceCode)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	sorted := make([]*offerProduct, 0, len(products))
+	for _, product := range products {
+		sorted = append(sorted, product)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].SKU < sorted[j].SKU
+	})
+
+	return sorted, nil
+}
+
+// lookup returns the products matching every TERM_MATCH filter in SKU order. The products
+// with the value of the first filter are found through the index, and checked against the rest.
+func (offer *offerIndex) lookup(filters []*pricing.Filter) []*offerProduct {
+	if len(filters) == 0 {
+		return offer.products
+	}
+
+	candidates := offer.withValue(normalizeField(aws.StringValue(filters[0].Field)), aws.StringValue(filters[0].Value))
+
+	var products []*offerProduct
+	for _, product := range candidates {
+		if product.matches(filters[1:]) {
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+// withValue returns the products whose field has a value, compared case-insensitively,
+// indexing the products by the field on first use
+func (offer *offerIndex) withValue(field string, value string) []*offerProduct {
+	offer.mu.Lock()
+	defer offer.mu.Unlock()
+
+	index, ok := offer.byField[field]
+	if !ok {
+		index = make(map[string][]*offerProduct)
+		for _, product := range offer.products {
+			v := strings.ToLower(product.field(field))
+			index[v] = append(index[v], product)
+		}
+		offer.byField[field] = index
+	}
+
+	return index[strings.ToLower(value)]
+}
+
+// offerFile finds the offer file for a service
+func (client *FileClient) offerFile(serviceCode ServiceCode) (string, error) {
+	info, err := os.Stat(client.Path)
+	if err != nil {
+		return "", err
+	}
+
+	if !info.IsDir() {
+		return client.Path, nil
+	}
+
+	for _, name := range []string{
+		string(serviceCode) + ".json",
+		string(serviceCode) + ".csv",
+		filepath.Join(string(serviceCode), "current", "index.json"),
+		filepath.Join(string(serviceCode), "current", "index.csv"),
+	} {
+		path := filepath.Join(client.Path, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+
+	return "", errors.Wrap(OfferFileNotFoundError, fmt.Sprintf("%s in %s", serviceCode, client.Path))
+}
+
+// normalizeField makes the field names of filters, JSON attributes and CSV
+// columns comparable, e.g. "Volume API Name" and "volumeApiName"
+func normalizeField(field string) string {
+	return strings.ToLower(strings.Replace(field, " ", "", -1))
+}
+
+// field returns the value of a normalized field of the product, empty if it has none
+func (product *offerProduct) field(field string) string {
+	switch field {
+	case "productfamily":
+		return product.ProductFamily
+	case "sku":
+		return product.SKU
+	}
+
+	for k, v := range product.Attributes {
+		if normalizeField(k) == field {
+			value, _ := v.(string)
+			return value
+		}
+	}
+	return ""
+}
+
+// matches reports whether a product matches every TERM_MATCH filter, comparing
+// field names and values case-insensitively
+func (product *offerProduct) matches(filters []*pricing.Filter) bool {
+	for _, filter := range filters {
+		if !strings.EqualFold(product.field(normalizeField(aws.StringValue(filter.Field))), aws.StringValue(filter.Value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readJSONOffer streams a JSON offer file, keeping the on demand terms of its products
+func readJSONOffer(r io.Reader, serviceCode ServiceCode) (map[string]*offerProduct, error) {
+	decoder := json.NewDecoder(r)
+
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	products := make(map[string]*offerProduct)
+
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "offerCode":
+			var offerCode string
+			if err := decoder.Decode(&offerCode); err != nil {
+				return nil, err
+			}
+			if offerCode != string(serviceCode) {
+				return nil, errors.Wrap(OfferFileNotFoundError, fmt.Sprintf("offer file is for %s, not %s", offerCode, serviceCode))
+			}
+		case "products":
+			err = forEachKey(decoder, func(sku string) error {
+				var product struct {
+					SKU           string                 `json:"sku"`
+					ProductFamily string                 `json:"productFamily"`
+					Attributes    map[string]interface{} `json:"attributes"`
+				}
+				if err := decoder.Decode(&product); err != nil {
+					return err
+				}
+
+				products[sku] = &offerProduct{
+					ProductFamily: product.ProductFamily,
+					SKU:           sku,
+					Attributes:    product.Attributes,
+					OnDemand:      map[string]interface{}{},
+				}
+				return nil
+			})
+		case "terms":
+			err = forEachKey(decoder, func(termType string) error {
+				if termType != termTypeOnDemand {
+					return skipValue(decoder)
+				}
+
+				return forEachKey(decoder, func(sku string) error {
+					product, ok := products[sku]
+					if !ok {
+						return skipValue(decoder)
+					}
+
+					return decoder.Decode(&product.OnDemand)
+				})
+			})
+		default:
+			err = skipValue(decoder)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return products, nil
+}
+
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return errors.Wrap(couldntParseOfferError, fmt.Sprintf("expected %v but got %v", delim, token))
+	}
+
+	return nil
+}
+
+// forEachKey calls fn for every key of the next JSON object, fn must consume the value
+func forEachKey(decoder *json.Decoder, fn func(key string) error) error {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		key, ok := token.(string)
+		if !ok {
+			return errors.Wrap(couldntParseOfferError, fmt.Sprintf("expected a key but got %v", token))
+		}
+
+		if err := fn(key); err != nil {
+			return err
+		}
+	}
+
+	return expectDelim(decoder, '}')
+}
+
+func skipValue(decoder *json.Decoder) error {
+	var skipped json.RawMessage
+	return decoder.Decode(&skipped)
+}
+
+// csvNonAttributeColumns are the columns of a CSV offer file that aren't product
+// attributes, they describe the price or are top level fields of the product
+var csvNonAttributeColumns = map[string]bool{
+	"SKU":                 true,
+	"OfferTermCode":       true,
+	"RateCode":            true,
+	"TermType":            true,
+	"PriceDescription":    true,
+	"EffectiveDate":       true,
+	"StartingRange":       true,
+	"EndingRange":         true,
+	"Unit":                true,
+	"PricePerUnit":        true,
+	"Currency":            true,
+	"RelatedTo":           true,
+	"LeaseContractLength": true,
+	"PurchaseOption":      true,
+	"OfferingClass":       true,
+	"Product Family":      true,
+}
+
+// csvAttributeName turns a CSV column like "Instance Type" into an attribute name like "instanceType"
+func csvAttributeName(column string) string {
+	name := strings.Replace(column, " ", "", -1)
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
+
+// readCSVOffer reads a CSV offer file, where every row is a single price dimension
+func readCSVOffer(r io.Reader, serviceCode ServiceCode) (map[string]*offerProduct, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	// The header is preceded by "Key","Value" metadata rows
+	var header []string
+	for header == nil {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil, errors.Wrap(couldntParseOfferError, "missing header")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case len(row) == 2 && row[0] == "OfferCode" && row[1] != string(serviceCode):
+			return nil, errors.Wrap(OfferFileNotFoundError, fmt.Sprintf("offer file is for %s, not %s", row[1], serviceCode))
+		case len(row) > 0 && row[0] == "SKU":
+			header = append([]string{}, row...)
+		}
+	}
+
+	products := make(map[string]*offerProduct)
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) != len(header) {
+			return nil, errors.Wrap(couldntParseOfferError, fmt.Sprintf("row has %d columns, header has %d", len(row), len(header)))
+		}
+
+		columns := make(map[string]string, len(header))
+		for i, column := range header {
+			columns[column] = row[i]
+		}
+
+		if columns["TermType"] != termTypeOnDemand {
+			continue
+		}
+
+		sku := columns["SKU"]
+		product, ok := products[sku]
+		if !ok {
+			product = &offerProduct{
+				ProductFamily: columns["Product Family"],
+				SKU:           sku,
+				Attributes:    map[string]interface{}{},
+				OnDemand:      map[string]interface{}{},
+			}
+			for column, value := range columns {
+				if !csvNonAttributeColumns[column] && value != "" {
+					product.Attributes[csvAttributeName(column)] = value
+				}
+			}
+			products[sku] = product
+		}
+
+		termCode := sku + "." + columns["OfferTermCode"]
+		term, ok := product.OnDemand[termCode].(map[string]interface{})
+		if !ok {
+			term = map[string]interface{}{
+				"sku":             sku,
+				"offerTermCode":   columns["OfferTermCode"],
+				"effectiveDate":   columns["EffectiveDate"],
+				"priceDimensions": map[string]interface{}{},
+			}
+			product.OnDemand[termCode] = term
+		}
+
+		term["priceDimensions"].(map[string]interface{})[columns["RateCode"]] = map[string]interface{}{
+			"rateCode":    columns["RateCode"],
+			"description": columns["PriceDescription"],
+			"beginRange":  columns["StartingRange"],
+			"endRange":    columns["EndingRange"],
+			"unit":        columns["Unit"],
+			"pricePerUnit": map[string]interface{}{
+				columns["Currency"]: columns["PricePerUnit"],
+			},
+		}
+	}
+
+	return products, nil
+}
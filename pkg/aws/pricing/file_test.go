@@ -0,0 +1,120 @@
+package pricing
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func storageInput(region string) *GetProductsInput {
+	return &GetProductsInput{
+		Region:      region,
+		ServiceCode: EC2,
+		Filters: []*pricing.Filter{
+			{
+				Type:  aws.String("TERM_MATCH"),
+				Field: aws.String("productFamily"),
+				Value: aws.String("Storage"),
+			},
+		},
+	}
+}
+
+func testFileClient(t *testing.T, client *FileClient) {
+	assert := assert.New(t)
+
+	priceItems, err := client.GetProducts(context.Background(), storageInput("us-east-1"))
+	if assert.Nil(err) && assert.Equal(1, len(priceItems)) {
+		priceItem := priceItems[0]
+		assert.Equal("US East (N. Virginia)", priceItem.Product.Attributes.Location)
+		assert.Equal("EBS:VolumeUsage.gp2", priceItem.Product.Attributes.UsageType)
+
+		term, ok := priceItem.Terms.OnDemand["SKUGP2USE1.JRTCKXETXF"]
+		if assert.True(ok) {
+			dimension := term.PriceDimensions["SKUGP2USE1.JRTCKXETXF.6YS6EN2CT7"]
+			assert.Equal("0", dimension.BeginRange)
+			assert.Equal("Inf", dimension.EndRange)
+			assert.Equal("0.1000000000", dimension.PricePerUnit.USD)
+		}
+	}
+
+	// Attribute filters
+	priceItems, err = client.GetProducts(context.Background(), &GetProductsInput{
+		Region:      "us-east-1",
+		ServiceCode: EC2,
+		Filters: []*pricing.Filter{
+			{
+				Type:  aws.String("TERM_MATCH"),
+				Field: aws.String("usagetype"),
+				Value: aws.String("NatGateway-Hours"),
+			},
+		},
+	})
+	if assert.Nil(err) && assert.Equal(1, len(priceItems)) {
+		// Only on demand terms are kept
+		assert.Equal(1, len(priceItems[0].Terms.OnDemand))
+	}
+
+	priceItems, err = client.GetProducts(context.Background(), storageInput("eu-west-1"))
+	assert.Nil(err)
+	assert.Equal(0, len(priceItems))
+
+	// Test error cases
+	priceItems, err = client.GetProducts(context.Background(), &GetProductsInput{
+		Region:      "us-east-1",
+		ServiceCode: DynamoDB,
+	})
+	assert.Nil(priceItems)
+	assert.True(errors.Is(err, OfferFileNotFoundError))
+}
+
+func TestFileClientJSON(t *testing.T) {
+	testFileClient(t, &FileClient{Path: filepath.Join("testdata", "AmazonEC2.json")})
+}
+
+func TestFileClientCSV(t *testing.T) {
+	testFileClient(t, &FileClient{Path: filepath.Join("testdata", "AmazonEC2.csv")})
+}
+
+func TestFileClientDirectory(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "cloudwaste-offers")
+	if !assert.Nil(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	b, err := ioutil.ReadFile(filepath.Join("testdata", "AmazonEC2.json"))
+	assert.Nil(err)
+	assert.Nil(os.MkdirAll(filepath.Join(dir, "AmazonEC2", "current"), 0755))
+	assert.Nil(ioutil.WriteFile(filepath.Join(dir, "AmazonEC2", "current", "index.json"), b, 0644))
+
+	client := &FileClient{Path: dir}
+
+	priceItems, err := client.GetProducts(context.Background(), storageInput("us-west-2"))
+	if assert.Nil(err) && assert.Equal(1, len(priceItems)) {
+		assert.Equal("USW2-EBS:VolumeUsage.gp2", priceItems[0].Product.Attributes.UsageType)
+	}
+
+	// The offer file is only read once, other filters are looked up in its products
+	assert.Nil(os.Remove(filepath.Join(dir, "AmazonEC2", "current", "index.json")))
+	priceItems, err = client.GetProducts(context.Background(), storageInput("us-east-1"))
+	if assert.Nil(err) && assert.Equal(1, len(priceItems)) {
+		assert.Equal("EBS:VolumeUsage.gp2", priceItems[0].Product.Attributes.UsageType)
+	}
+
+	priceItems, err = client.GetProducts(context.Background(), &GetProductsInput{
+		Region:      "us-east-1",
+		ServiceCode: DynamoDB,
+	})
+	assert.Nil(priceItems)
+	assert.True(errors.Is(err, OfferFileNotFoundError))
+}
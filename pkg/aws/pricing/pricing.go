@@ -2,13 +2,16 @@ package pricing
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/service/pricing"
 	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
-
 	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
 )
 
 type ServiceCode string
@@ -16,6 +19,8 @@ type ServiceCode string
 const (
 	DynamoDB ServiceCode = "AmazonDynamoDB"
 	EC2      ServiceCode = "AmazonEC2"
+	ELB      ServiceCode = "AWSELB"
+	RDS      ServiceCode = "AmazonRDS"
 )
 
 type PricingInterface interface {
@@ -23,7 +28,12 @@ type PricingInterface interface {
 }
 
 type Client struct {
+	// Pricing is the Pricing API in us-east-1, which serves the aws and aws-us-gov partitions.
+	// It only takes credentials of the aws partition, GovCloud is priced with those too.
 	Pricing pricingiface.PricingAPI
+	// PartitionPricing holds the Pricing API of other endpoints by region, e.g. cn-northwest-1
+	// for China, each with credentials of its own partition
+	PartitionPricing map[string]pricingiface.PricingAPI
 }
 
 type GetProductsInput struct {
@@ -33,19 +43,38 @@ type GetProductsInput struct {
 }
 
 type AWSPriceItemProductAttributes struct {
-	Location  string `json:"location"`
-	UsageType string `json:"usagetype"`
+	Location      string `json:"location"`
+	UsageType     string `json:"usagetype"`
+	Group         string `json:"group"`
+	VolumeAPIName string `json:"volumeApiName"`
+	InstanceType  string `json:"instanceType"`
+	// Attributes of EC2 instances in the AmazonEC2 price list
+	Tenancy         string `json:"tenancy"`
+	OperatingSystem string `json:"operatingSystem"`
+	PreInstalledSw  string `json:"preInstalledSw"`
+	CapacityStatus  string `json:"capacitystatus"`
+	// Attributes of the AmazonRDS price list
+	DatabaseEngine   string `json:"databaseEngine"`
+	DatabaseEdition  string `json:"databaseEdition"`
+	DeploymentOption string `json:"deploymentOption"`
+	LicenseModel     string `json:"licenseModel"`
+	VolumeType       string `json:"volumeType"`
 }
 type AWSPriceItemProduct struct {
-	Attributes AWSPriceItemProductAttributes `json:"attributes"`
+	ProductFamily string                        `json:"productFamily"`
+	SKU           string                        `json:"sku"`
+	Attributes    AWSPriceItemProductAttributes `json:"attributes"`
 }
 type AWSPriceItemPricePerUnit struct {
 	USD string `json:"USD"`
+	// CNY is set instead of USD for the China regions
+	CNY string `json:"CNY,omitempty"`
 }
 type AWSPriceItemPriceDimension struct {
 	Description  string                   `json:"description"`
 	BeginRange   string                   `json:"beginRange"`
 	EndRange     string                   `json:"endRange"`
+	Unit         string                   `json:"unit"`
 	PricePerUnit AWSPriceItemPricePerUnit `json:"pricePerUnit"`
 }
 type AWSPriceItemOnDemand struct {
@@ -62,37 +91,80 @@ type AWSPriceItem struct {
 	Terms   AWSPriceItemTerms   `json:"terms"`
 }
 
+// filters returns the filters of the request plus one for the location of its region
+func (options *GetProductsInput) filters() ([]*pricing.Filter, *util.Location, error) {
+	location, err := util.ResolveLocation(options.Region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filters := append([]*pricing.Filter{}, options.Filters...)
+	return append(filters, &pricing.Filter{
+		Type:  aws.String("TERM_MATCH"),
+		Field: aws.String("location"),
+		Value: aws.String(location.Name),
+	}), location, nil
+}
+
+// api returns the Pricing API serving a location
+func (client *Client) api(location *util.Location) (pricingiface.PricingAPI, error) {
+	if location.PricingRegion == endpoints.UsEast1RegionID {
+		return client.Pricing, nil
+	}
+
+	if api, ok := client.PartitionPricing[location.PricingRegion]; ok {
+		return api, nil
+	}
+
+	return nil, errors.Wrap(util.UnknownRegionError, fmt.Sprintf("no Pricing API configured for %s", location.Region))
+}
+
+// decodePriceItem decodes a single product of a price list
+func decodePriceItem(priceItemJson aws.JSONValue) (*AWSPriceItem, error) {
+	var priceItem AWSPriceItem
+	err := mapstructure.Decode(priceItemJson, &priceItem)
+	if err != nil {
+		return nil, err
+	}
+
+	return &priceItem, nil
+}
+
 func (client *Client) GetProducts(ctx context.Context, options *GetProductsInput) ([]*AWSPriceItem, error) {
-	regionName := endpoints.AwsPartition().Regions()[options.Region].Description()
+	filters, location, err := options.filters()
+	if err != nil {
+		return nil, err
+	}
+
+	api, err := client.api(location)
+	if err != nil {
+		return nil, err
+	}
 
 	var priceItems []*AWSPriceItem
 	var cbErr error = nil
 
-	filters := append(options.Filters, &pricing.Filter{
-		Type:  aws.String("TERM_MATCH"),
-		Field: aws.String("location"),
-		Value: aws.String(regionName),
-	})
-
-	err := client.Pricing.GetProductsPagesWithContext(ctx, &pricing.GetProductsInput{
+	err = api.GetProductsPagesWithContext(ctx, &pricing.GetProductsInput{
 		ServiceCode: aws.String(string(options.ServiceCode)),
 		Filters:     filters,
 	}, func(products *pricing.GetProductsOutput, lastPage bool) bool {
 		for _, priceItemJson := range products.PriceList {
-			var priceItem AWSPriceItem
-			err := mapstructure.Decode(priceItemJson, &priceItem)
+			priceItem, err := decodePriceItem(priceItemJson)
 			if err != nil {
 				cbErr = err
 				return false
 			}
 
-			priceItems = append(priceItems, &priceItem)
+			priceItems = append(priceItems, priceItem)
 		}
 
 		return true
 	})
 	if err != nil {
-		return nil, err
+		// The Pricing API only takes credentials of its own partition
+		partition, _ := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), location.PricingRegion)
+		return nil, errors.Wrap(err, fmt.Sprintf("Pricing API in %s, which prices %s with credentials of the %s partition only",
+			location.PricingRegion, location.Region, partition.ID()))
 	}
 	if cbErr != nil {
 		return nil, cbErr
@@ -2,16 +2,19 @@ package pricing
 
 import (
 	"context"
-	"errors"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/pricing"
 	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
 )
 
 type mockedPricing struct {
@@ -118,6 +121,74 @@ func (suite *PricingTestSuite) TestGetProducts() {
 	assert.Nil(priceItems)
 }
 
+func (suite *PricingTestSuite) TestGetProductsPartitions() {
+	assert := assert.New(suite.T())
+
+	chinaPricing := new(mockedPricing)
+	suite.client.PartitionPricing = map[string]pricingiface.PricingAPI{
+		"cn-northwest-1": chinaPricing,
+	}
+
+	chinaPricing.On("GetProductsPagesWithContext", mock.Anything, mock.MatchedBy(func(input *pricing.GetProductsInput) bool {
+		location := input.Filters[len(input.Filters)-1]
+		return aws.StringValue(location.Field) == "location" && aws.StringValue(location.Value) == "China (Ningxia)"
+	}), mock.Anything, mock.Anything, mock.Anything).
+		Return(&pricing.GetProductsOutput{
+			PriceList: []aws.JSONValue{
+				{
+					"product": aws.JSONValue{
+						"attributes": aws.JSONValue{
+							"location": "China (Ningxia)",
+						},
+					},
+				},
+			},
+		}, nil).Once()
+
+	priceItems, err := suite.client.GetProducts(context.Background(), &GetProductsInput{
+		Region:      "cn-northwest-1",
+		ServiceCode: EC2,
+	})
+	if assert.Nil(err) && assert.Equal(1, len(priceItems)) {
+		assert.Equal("China (Ningxia)", priceItems[0].Product.Attributes.Location)
+	}
+	suite.mockedPricing.AssertNotCalled(suite.T(), "GetProductsPagesWithContext")
+
+	// Test error cases
+	suite.client.PartitionPricing = nil
+	priceItems, err = suite.client.GetProducts(context.Background(), &GetProductsInput{
+		Region:      "cn-northwest-1",
+		ServiceCode: EC2,
+	})
+	assert.Nil(priceItems)
+	assert.True(errors.Is(err, util.UnknownRegionError))
+
+	// Credentials of another partition fail with the partition the endpoint takes
+	chinaPricing = new(mockedPricing)
+	suite.client.PartitionPricing = map[string]pricingiface.PricingAPI{
+		"cn-northwest-1": chinaPricing,
+	}
+	chinaPricing.On("GetProductsPagesWithContext", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(&pricing.GetProductsOutput{}, awserr.New("UnrecognizedClientException", "The security token included in the request is invalid", nil)).Once()
+
+	priceItems, err = suite.client.GetProducts(context.Background(), &GetProductsInput{
+		Region:      "cn-north-1",
+		ServiceCode: EC2,
+	})
+	assert.Nil(priceItems)
+	if assert.NotNil(err) {
+		assert.Contains(err.Error(), "aws-cn partition")
+		assert.Equal("UnrecognizedClientException", util.NewResourceError("", "", err).Code)
+	}
+
+	priceItems, err = suite.client.GetProducts(context.Background(), &GetProductsInput{
+		Region:      "mars-central-1",
+		ServiceCode: EC2,
+	})
+	assert.Nil(priceItems)
+	assert.True(errors.Is(err, util.UnknownRegionError))
+}
+
 func TestPricingTestSuite(t *testing.T) {
 	suite.Run(t, new(PricingTestSuite))
 }
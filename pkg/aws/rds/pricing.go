@@ -0,0 +1,300 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/pkg/errors"
+
+	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+var (
+	unknownEngineError      = errors.New("Unknown database engine")
+	unknownStorageTypeError = errors.New("Unknown storage type")
+	multiplePriceCodesError = errors.New("Couldn't find single price code")
+)
+
+const (
+	usageTypeChargedBackup = "ChargedBackupUsage"
+)
+
+// priceEngines maps an engine of the RDS API to its database engine and edition in the
+// price list. The multitenant container database engines and RDS Custom share the price
+// list entries of their engine.
+var priceEngines = map[string][2]string{
+	"aurora":               {"Aurora MySQL", ""},
+	"aurora-mysql":         {"Aurora MySQL", ""},
+	"aurora-postgresql":    {"Aurora PostgreSQL", ""},
+	"mariadb":              {"MariaDB", ""},
+	"mysql":                {"MySQL", ""},
+	"postgres":             {"PostgreSQL", ""},
+	"db2-ae":               {"Db2", "Advanced"},
+	"db2-se":               {"Db2", "Standard"},
+	"oracle-ee":            {"Oracle", "Enterprise"},
+	"oracle-ee-cdb":        {"Oracle", "Enterprise"},
+	"oracle-se":            {"Oracle", "Standard"},
+	"oracle-se1":           {"Oracle", "Standard One"},
+	"oracle-se2":           {"Oracle", "Standard Two"},
+	"oracle-se2-cdb":       {"Oracle", "Standard Two"},
+	"sqlserver-ee":         {"SQL Server", "Enterprise"},
+	"sqlserver-se":         {"SQL Server", "Standard"},
+	"sqlserver-ex":         {"SQL Server", "Express"},
+	"sqlserver-web":        {"SQL Server", "Web"},
+	"custom-oracle-ee":     {"Oracle", "Enterprise"},
+	"custom-oracle-ee-cdb": {"Oracle", "Enterprise"},
+	"custom-oracle-se2":    {"Oracle", "Standard Two"},
+	"custom-sqlserver-ee":  {"SQL Server", "Enterprise"},
+	"custom-sqlserver-se":  {"SQL Server", "Standard"},
+	"custom-sqlserver-web": {"SQL Server", "Web"},
+}
+
+// priceLicenseModels maps a license model of the RDS API to its name in the price list
+var priceLicenseModels = map[string]string{
+	"license-included":       "License included",
+	"bring-your-own-license": "Bring your own license",
+	"general-public-license": "No license required",
+	"postgresql-license":     "No license required",
+}
+
+// priceVolumeTypes maps a storage type of the RDS API to its volume type in the price list
+var priceVolumeTypes = map[string]string{
+	"standard": "Magnetic",
+	"gp2":      "General Purpose",
+	"gp3":      "General Purpose-GP3",
+	"io1":      "Provisioned IOPS",
+	"io2":      "Provisioned IOPS-IO2",
+}
+
+// priceAuroraStorage maps the storage type of an Aurora cluster to the storage of its
+// instances in the price list, I/O-Optimized instances having a price of their own
+var priceAuroraStorage = map[string]string{
+	"":             "EBS Only",
+	"aurora":       "EBS Only",
+	"aurora-iopt1": "Aurora IO Optimization Mode",
+}
+
+// io2UsageType marks the provisioned IOPS of io2 storage in the usage types of the price
+// list, e.g. RDS:PIOPS-IO2, the other provisioned IOPS are io1's
+const io2UsageType = "IO2"
+
+// deploymentOption returns the deployment option of an instance in the price list
+func deploymentOption(instance *rds.DBInstance) string {
+	if aws.BoolValue(instance.MultiAZ) {
+		return "Multi-AZ"
+	}
+	return "Single-AZ"
+}
+
+// termMatch returns a TERM_MATCH pricing filter
+func termMatch(field string, value string) *pricing.Filter {
+	return &pricing.Filter{
+		Type:  aws.String(pricing.FilterTypeTermMatch),
+		Field: aws.String(field),
+		Value: aws.String(value),
+	}
+}
+
+// unboundedRates returns the rates of the price items charged in the unit, in order
+func unboundedRates(priceItems []*pricingWaste.AWSPriceItem, unit string) ([]float64, error) {
+	var rates []float64
+	for _, priceItem := range priceItems {
+		dimension, err := priceItem.UnboundedDimension()
+		if errors.Is(err, pricingWaste.NoPriceDimensionError) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if dimension.Unit == unit {
+			rate, err := dimension.Rate()
+			if err != nil {
+				return nil, err
+			}
+			rates = append(rates, rate)
+		}
+	}
+
+	return rates, nil
+}
+
+// unboundedRate returns the rate of the first price item charged in the unit
+func unboundedRate(priceItems []*pricingWaste.AWSPriceItem, unit string) (float64, error) {
+	rates, err := unboundedRates(priceItems, unit)
+	if err != nil {
+		return 0, err
+	}
+	if len(rates) == 0 {
+		return 0, util.NoResourceFoundError
+	}
+	return rates[0], nil
+}
+
+// GetDBInstancePricing returns the hourly price of an instance by its class, engine,
+// license model and deployment option, and the storage type of Aurora instances. It
+// fails unless a single price matches.
+func (client *Client) GetDBInstancePricing(ctx context.Context, region string, instance *rds.DBInstance) (*util.Price, error) {
+	engine, ok := priceEngines[aws.StringValue(instance.Engine)]
+	if !ok {
+		return nil, errors.Wrap(unknownEngineError, aws.StringValue(instance.Engine))
+	}
+
+	filters := []*pricing.Filter{
+		termMatch("productFamily", "Database Instance"),
+		termMatch("instanceType", aws.StringValue(instance.DBInstanceClass)),
+		termMatch("databaseEngine", engine[0]),
+		termMatch("deploymentOption", deploymentOption(instance)),
+	}
+	if engine[1] != "" {
+		filters = append(filters, termMatch("databaseEdition", engine[1]))
+	}
+	if licenseModel, ok := priceLicenseModels[aws.StringValue(instance.LicenseModel)]; ok {
+		filters = append(filters, termMatch("licenseModel", licenseModel))
+	}
+	if isAurora(aws.StringValue(instance.Engine)) {
+		storage, ok := priceAuroraStorage[aws.StringValue(instance.StorageType)]
+		if !ok {
+			return nil, errors.Wrap(unknownStorageTypeError, aws.StringValue(instance.StorageType))
+		}
+		filters = append(filters, termMatch("storage", storage))
+	}
+
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.RDS,
+		Filters:     filters,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := unboundedRates(priceItems, "Hrs")
+	if err != nil {
+		return nil, err
+	}
+	switch len(rates) {
+	case 0:
+		return nil, errors.Wrap(util.NoResourceFoundError, fmt.Sprintf("no hourly price for %s %s", aws.StringValue(instance.DBInstanceClass), engine[0]))
+	case 1:
+	default:
+		return nil, errors.Wrap(multiplePriceCodesError, fmt.Sprintf("%d hourly prices for %s %s", len(rates), aws.StringValue(instance.DBInstanceClass), engine[0]))
+	}
+
+	return &util.Price{
+		Unit: "Hr",
+		Rate: rates[0],
+	}, nil
+}
+
+// GetDBStoragePricing returns the monthly price of the storage allocated to an instance,
+// including the provisioned IOPS of io1 and io2 storage
+func (client *Client) GetDBStoragePricing(ctx context.Context, region string, instance *rds.DBInstance) (*util.Price, error) {
+	storageType := aws.StringValue(instance.StorageType)
+	volumeType, ok := priceVolumeTypes[storageType]
+	if !ok {
+		return nil, errors.Wrap(unknownStorageTypeError, storageType)
+	}
+
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.RDS,
+		Filters: []*pricing.Filter{
+			termMatch("productFamily", "Database Storage"),
+			termMatch("volumeType", volumeType),
+			termMatch("deploymentOption", deploymentOption(instance)),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Storage is priced for any engine except the ones that have their own price, e.g. SQL Server
+	engine := priceEngines[aws.StringValue(instance.Engine)][0]
+	var enginePriceItems, anyPriceItems []*pricingWaste.AWSPriceItem
+	for _, priceItem := range priceItems {
+		switch priceItem.Product.Attributes.DatabaseEngine {
+		case engine:
+			enginePriceItems = append(enginePriceItems, priceItem)
+		case "Any", "":
+			anyPriceItems = append(anyPriceItems, priceItem)
+		}
+	}
+
+	rate, err := unboundedRate(append(enginePriceItems, anyPriceItems...), "GB-Mo")
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("no storage price for %s", volumeType))
+	}
+
+	monthly := rate * float64(aws.Int64Value(instance.AllocatedStorage))
+
+	if (storageType == "io1" || storageType == "io2") && aws.Int64Value(instance.Iops) > 0 {
+		priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+			Region:      region,
+			ServiceCode: pricingWaste.RDS,
+			Filters: []*pricing.Filter{
+				termMatch("productFamily", "Provisioned IOPS"),
+				termMatch("deploymentOption", deploymentOption(instance)),
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var iopsPriceItems []*pricingWaste.AWSPriceItem
+		for _, priceItem := range priceItems {
+			if strings.Contains(priceItem.Product.Attributes.UsageType, io2UsageType) == (storageType == "io2") {
+				iopsPriceItems = append(iopsPriceItems, priceItem)
+			}
+		}
+
+		rate, err := unboundedRate(iopsPriceItems, "IOPS-Mo")
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("no provisioned IOPS price for %s", storageType))
+		}
+
+		monthly += rate * float64(aws.Int64Value(instance.Iops))
+	}
+
+	return &util.Price{
+		Unit: "Mo",
+		Rate: monthly,
+	}, nil
+}
+
+// GetDBSnapshotPricing returns the monthly price of a GB of backup storage
+func (client *Client) GetDBSnapshotPricing(ctx context.Context, region string) (*util.Price, error) {
+	priceItems, err := client.Pricing.GetProducts(ctx, &pricingWaste.GetProductsInput{
+		Region:      region,
+		ServiceCode: pricingWaste.RDS,
+		Filters: []*pricing.Filter{
+			termMatch("productFamily", "Storage Snapshot"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Usage types outside of us-east-1 are prefixed with the region, e.g. USW2-RDS:ChargedBackupUsage
+	var backupPriceItems []*pricingWaste.AWSPriceItem
+	for _, priceItem := range priceItems {
+		if strings.HasSuffix(priceItem.Product.Attributes.UsageType, usageTypeChargedBackup) {
+			backupPriceItems = append(backupPriceItems, priceItem)
+		}
+	}
+
+	rate, err := unboundedRate(backupPriceItems, "GB-Mo")
+	if err != nil {
+		return nil, errors.Wrap(err, "no backup storage price")
+	}
+
+	return &util.Price{
+		Unit: "GB-Mo",
+		Rate: rate,
+	}, nil
+}
@@ -0,0 +1,408 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+
+	pricingWaste "github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+const (
+	dbInstanceType = "RDS DB Instance"
+	dbClusterType  = "RDS DB Cluster"
+	dbSnapshotType = "RDS DB Snapshot"
+
+	analyzerService = "rds"
+
+	AnalyzerIdleDatabase     = "rds-idle-database"
+	AnalyzerStoppedInstance  = "rds-stopped-instance"
+	AnalyzerOrphanedSnapshot = "rds-orphaned-snapshot"
+)
+
+// Rules identify why a resource is wasted
+const (
+	RuleDatabaseIdle     = "rds-database-idle"
+	RuleInstanceStopped  = "rds-instance-stopped"
+	RuleSnapshotOrphaned = "rds-snapshot-orphaned"
+)
+
+const (
+	// DefaultLookback is how far back database connections are checked by default
+	DefaultLookback = 14 * 24 * time.Hour
+	// DefaultPeriod is the period of the connections data points by default
+	DefaultPeriod = 24 * time.Hour
+	// DefaultStatistic is the statistic of the connections data points by default
+	DefaultStatistic = "Maximum"
+)
+
+type Client struct {
+	RDS        rdsiface.RDSAPI
+	Cloudwatch cloudwatchiface.CloudWatchAPI
+	Pricing    pricingWaste.PricingInterface
+	// Concurrency is the maximum number of per-database API calls in flight
+	Concurrency int
+	// IdleMetrics tune the connections checked by the idle database analyzer, the defaults
+	// for zero fields. A database is idle if its connections never exceed the min usage.
+	IdleMetrics util.MetricSettings
+}
+
+type DBInstance struct {
+	r *rds.DBInstance
+	// evidence is the window connections were checked over and the most connections, only for idle instances
+	evidence map[string]string
+}
+
+type DBCluster struct {
+	r *rds.DBCluster
+	// members are the instances of the cluster
+	members []*rds.DBInstance
+	// evidence is the window connections were checked over and the most connections
+	evidence map[string]string
+}
+
+func (r DBInstance) Type() string {
+	return dbInstanceType
+}
+
+func (r DBInstance) ID() string {
+	return aws.StringValue(r.r.DBInstanceIdentifier)
+}
+
+func (r DBInstance) Tags() map[string]string {
+	return tagMap(r.r.TagList)
+}
+
+func (r DBCluster) Type() string {
+	return dbClusterType
+}
+
+func (r DBCluster) ID() string {
+	return aws.StringValue(r.r.DBClusterIdentifier)
+}
+
+func (r DBCluster) Tags() map[string]string {
+	return tagMap(r.r.TagList)
+}
+
+// tagMap returns the tags of a resource by key
+func tagMap(tags []*rds.Tag) map[string]string {
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		m[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return m
+}
+
+// RegisterAnalyzers adds the RDS analyzers to the registry
+func (client *Client) RegisterAnalyzers(registry *util.Registry) error {
+	return registry.Register(
+		util.NewAnalyzer(AnalyzerIdleDatabase, analyzerService, client.AnalyzeIdleDatabaseWaste),
+		util.NewAnalyzer(AnalyzerStoppedInstance, analyzerService, client.AnalyzeStoppedDBInstanceWaste),
+		util.NewAnalyzer(AnalyzerOrphanedSnapshot, analyzerService, client.AnalyzeOrphanedDBSnapshotWaste),
+	)
+}
+
+// idleMetrics returns the connections metric settings of the client, falling back to the defaults
+func (client *Client) idleMetrics() util.MetricSettings {
+	return client.IdleMetrics.WithDefaults(util.MetricSettings{
+		Lookback: DefaultLookback,
+		Period:   DefaultPeriod,
+		Stat:     DefaultStatistic,
+	})
+}
+
+// isAurora reports whether an engine stores its data in an Aurora cluster volume
+func isAurora(engine string) bool {
+	return strings.HasPrefix(engine, "aurora")
+}
+
+// AnalyzeIdleDatabaseWaste prices the instances and Aurora clusters whose connections
+// never exceeded the min usage over the lookback window
+func (client *Client) AnalyzeIdleDatabaseWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
+	idleDatabases, err := client.GetIdleDatabases(ctx)
+	resourceErrs, err := util.SplitResourceErrors(err)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := client.idleMetrics()
+	reason := util.Reason{
+		Rule:        RuleDatabaseIdle,
+		Explanation: fmt.Sprintf("no connections over %s", util.FormatDuration(settings.Lookback)),
+	}
+	if settings.MinUsage > 0 {
+		reason.Explanation = fmt.Sprintf("at most %g connections every %s over %s",
+			settings.MinUsage, util.FormatDuration(settings.Period), util.FormatDuration(settings.Lookback))
+	}
+
+	var wastedResources []util.AWSWastedResource
+
+	for _, idleResource := range idleDatabases {
+		var rate float64
+		var evidence map[string]string
+		var err error
+
+		switch idle := idleResource.R.(type) {
+		case *DBInstance:
+			evidence = idle.evidence
+			rate, err = client.instanceHourlyRate(ctx, region, idle.r)
+		case *DBCluster:
+			evidence = idle.evidence
+			// Aurora storage is billed by the bytes used, which only CloudWatch knows,
+			// so an idle cluster is priced by its instances
+			for _, member := range idle.members {
+				// Instances are priced by the storage type of their cluster, standard or I/O-Optimized
+				priced := *member
+				if idle.r.StorageType != nil {
+					priced.StorageType = idle.r.StorageType
+				}
+
+				var memberRate float64
+				memberRate, err = client.instanceHourlyRate(ctx, region, &priced)
+				if err != nil {
+					break
+				}
+				rate += memberRate
+			}
+		default:
+			return nil, util.PricingError
+		}
+
+		if err != nil {
+			resourceErrs = resourceErrs.Append(idleResource.R.ID(), util.NewResourceError(idleResource.R.ID(), "GetProducts", err))
+			continue
+		}
+
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: idleResource,
+			Price: util.Price{
+				Unit: "Hr",
+				Rate: rate,
+			},
+			Reason:   reason,
+			Evidence: evidence,
+		})
+	}
+
+	return wastedResources, resourceErrs.ErrorOrNil()
+}
+
+// instanceHourlyRate returns the hourly rate of an instance including its storage
+func (client *Client) instanceHourlyRate(ctx context.Context, region string, instance *rds.DBInstance) (float64, error) {
+	instancePrice, err := client.GetDBInstancePricing(ctx, region, instance)
+	if err != nil {
+		return 0, err
+	}
+
+	if isAurora(aws.StringValue(instance.Engine)) {
+		return instancePrice.Rate, nil
+	}
+
+	storagePrice, err := client.GetDBStoragePricing(ctx, region, instance)
+	if err != nil {
+		return 0, err
+	}
+
+	return instancePrice.Rate + storagePrice.Rate/util.HoursPerMonth, nil
+}
+
+// AnalyzeStoppedDBInstanceWaste prices the storage that stopped instances keep paying for
+func (client *Client) AnalyzeStoppedDBInstanceWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
+	stoppedInstances, err := client.GetStoppedDBInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var wastedResources []util.AWSWastedResource
+	var resourceErrs util.ResourceErrors
+
+	for _, stoppedResource := range stoppedInstances {
+		stoppedInstance, ok := stoppedResource.R.(*DBInstance)
+		if !ok {
+			return nil, util.PricingError
+		}
+
+		price, err := client.GetDBStoragePricing(ctx, region, stoppedInstance.r)
+		if err != nil {
+			resourceErrs = resourceErrs.Append(stoppedInstance.ID(), util.NewResourceError(stoppedInstance.ID(), "GetProducts", err))
+			continue
+		}
+
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: stoppedResource,
+			Price:    *price,
+			Reason: util.Reason{
+				Rule:        RuleInstanceStopped,
+				Explanation: fmt.Sprintf("%s is stopped, still paying for %d GB of storage", stoppedInstance.ID(), aws.Int64Value(stoppedInstance.r.AllocatedStorage)),
+			},
+			Evidence: map[string]string{
+				util.EvidenceDBInstanceStatus: aws.StringValue(stoppedInstance.r.DBInstanceStatus),
+				util.EvidenceAllocatedStorage: strconv.FormatInt(aws.Int64Value(stoppedInstance.r.AllocatedStorage), 10),
+				util.EvidenceStorageType:      aws.StringValue(stoppedInstance.r.StorageType),
+			},
+		})
+	}
+
+	return wastedResources, resourceErrs.ErrorOrNil()
+}
+
+// describeDBInstances returns every instance of the region
+func (client *Client) describeDBInstances(ctx context.Context) ([]*rds.DBInstance, error) {
+	var instances []*rds.DBInstance
+
+	err := client.RDS.DescribeDBInstancesPagesWithContext(ctx, &rds.DescribeDBInstancesInput{},
+		func(page *rds.DescribeDBInstancesOutput, lastPage bool) bool {
+			instances = append(instances, page.DBInstances...)
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return instances, nil
+}
+
+// GetStoppedDBInstances returns the stopped instances, Aurora instances are left
+// out as their storage belongs to the cluster
+func (client *Client) GetStoppedDBInstances(ctx context.Context) ([]util.AWSResourceObject, error) {
+	instances, err := client.describeDBInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var stoppedInstances []util.AWSResourceObject
+	for _, instance := range instances {
+		if aws.StringValue(instance.DBInstanceStatus) == "stopped" && !isAurora(aws.StringValue(instance.Engine)) {
+			stoppedInstances = append(stoppedInstances, util.AWSResourceObject{R: &DBInstance{r: instance}})
+		}
+	}
+
+	return stoppedInstances, nil
+}
+
+// GetIdleDatabases returns the available instances and Aurora clusters older than the
+// lookback window whose connections never exceeded the min usage during it. Instances of a cluster are only
+// reported through their cluster.
+func (client *Client) GetIdleDatabases(ctx context.Context) ([]util.AWSResourceObject, error) {
+	instances, err := client.describeDBInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var clusters []*rds.DBCluster
+
+	err = client.RDS.DescribeDBClustersPagesWithContext(ctx, &rds.DescribeDBClustersInput{},
+		func(page *rds.DescribeDBClustersOutput, lastPage bool) bool {
+			clusters = append(clusters, page.DBClusters...)
+			return true
+		})
+
+	if err != nil {
+		return nil, err
+	}
+
+	settings := client.idleMetrics()
+	createdBefore := time.Now().Add(-settings.Lookback)
+
+	instancesByID := make(map[string]*rds.DBInstance, len(instances))
+	for _, instance := range instances {
+		instancesByID[aws.StringValue(instance.DBInstanceIdentifier)] = instance
+	}
+
+	var candidates []util.AWSResourceObject
+	var dimensions []map[string]string
+
+	for _, instance := range instances {
+		if aws.StringValue(instance.DBInstanceStatus) != "available" || instance.DBClusterIdentifier != nil ||
+			instance.InstanceCreateTime == nil || instance.InstanceCreateTime.After(createdBefore) {
+			continue
+		}
+
+		candidates = append(candidates, util.AWSResourceObject{R: &DBInstance{r: instance}})
+		dimensions = append(dimensions, map[string]string{"DBInstanceIdentifier": aws.StringValue(instance.DBInstanceIdentifier)})
+	}
+
+	for _, cluster := range clusters {
+		// Serverless clusters are billed by capacity units, which scale to zero on their own
+		if aws.StringValue(cluster.Status) != "available" || !isAurora(aws.StringValue(cluster.Engine)) ||
+			aws.StringValue(cluster.EngineMode) == "serverless" ||
+			cluster.ClusterCreateTime == nil || cluster.ClusterCreateTime.After(createdBefore) {
+			continue
+		}
+
+		var members []*rds.DBInstance
+		for _, member := range cluster.DBClusterMembers {
+			if instance, ok := instancesByID[aws.StringValue(member.DBInstanceIdentifier)]; ok {
+				members = append(members, instance)
+			}
+		}
+
+		candidates = append(candidates, util.AWSResourceObject{R: &DBCluster{r: cluster, members: members}})
+		dimensions = append(dimensions, map[string]string{"DBClusterIdentifier": aws.StringValue(cluster.DBClusterIdentifier)})
+	}
+
+	idle := make([]bool, len(candidates))
+	evidence := make([]map[string]string, len(candidates))
+	errs := make([]error, len(candidates))
+
+	err = util.ForEach(ctx, client.Concurrency, len(candidates), func(i int) {
+		query := util.MetricQuery{
+			ID:         "connections",
+			Namespace:  "AWS/RDS",
+			MetricName: "DatabaseConnections",
+			Dimensions: dimensions[i],
+			Stat:       settings.Stat,
+			Period:     settings.Period,
+			Lookback:   settings.Lookback,
+		}
+		result, err := util.GetMetric(ctx, client.Cloudwatch, query)
+		if err != nil {
+			errs[i] = util.NewResourceError(candidates[i].R.ID(), "GetMetricData", err)
+			return
+		}
+
+		idle[i] = result.Max <= settings.MinUsage
+		evidence[i] = make(map[string]string)
+		result.AddEvidence(evidence[i], query.MetricName, query)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var idleDatabases []util.AWSResourceObject
+	var resourceErrs util.ResourceErrors
+	for i, candidate := range candidates {
+		if errs[i] != nil {
+			resourceErrs = resourceErrs.Append(candidate.R.ID(), errs[i])
+			continue
+		}
+		if !idle[i] {
+			continue
+		}
+
+		switch idle := candidate.R.(type) {
+		case *DBInstance:
+			evidence[i][util.EvidenceEngine] = aws.StringValue(idle.r.Engine)
+			evidence[i][util.EvidenceInstanceCreateTime] = util.FormatTime(aws.TimeValue(idle.r.InstanceCreateTime))
+			idle.evidence = evidence[i]
+		case *DBCluster:
+			evidence[i][util.EvidenceEngine] = aws.StringValue(idle.r.Engine)
+			evidence[i][util.EvidenceClusterCreateTime] = util.FormatTime(aws.TimeValue(idle.r.ClusterCreateTime))
+			idle.evidence = evidence[i]
+		}
+		idleDatabases = append(idleDatabases, candidate)
+	}
+
+	return idleDatabases, resourceErrs.ErrorOrNil()
+}
@@ -0,0 +1,542 @@
+package rds
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+	pricingTest "github.com/cloudwaste/cloudwaste/pkg/aws/pricing/test"
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+type mockedRDS struct {
+	mock.Mock
+	rdsiface.RDSAPI
+}
+
+type mockedCloudwatch struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (m *mockedRDS) DescribeDBInstancesPagesWithContext(ctx context.Context, input *rds.DescribeDBInstancesInput, fn func(*rds.DescribeDBInstancesOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*rds.DescribeDBInstancesOutput), true)
+	}
+	return args.Error(1)
+}
+
+func (m *mockedRDS) DescribeDBClustersPagesWithContext(ctx context.Context, input *rds.DescribeDBClustersInput, fn func(*rds.DescribeDBClustersOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*rds.DescribeDBClustersOutput), true)
+	}
+	return args.Error(1)
+}
+
+func (m *mockedRDS) DescribeDBSnapshotsPagesWithContext(ctx context.Context, input *rds.DescribeDBSnapshotsInput, fn func(*rds.DescribeDBSnapshotsOutput, bool) bool, opts ...request.Option) error {
+	args := m.Called(ctx, input, fn)
+
+	if args.Error(1) == nil {
+		fn(args.Get(0).(*rds.DescribeDBSnapshotsOutput), true)
+	}
+	return args.Error(1)
+}
+
+// connections holds the DatabaseConnections data points by instance or cluster identifier
+var connections = map[string][]float64{
+	"idle-instance":  {0, 0},
+	"busy-instance":  {0, 3},
+	"idle-cluster":   {},
+	"busy-cluster":   {1},
+	"young-instance": {0},
+}
+
+func (m *mockedCloudwatch) GetMetricDataWithContext(ctx context.Context, input *cloudwatch.GetMetricDataInput, options ...request.Option) (*cloudwatch.GetMetricDataOutput, error) {
+	args := m.Called(ctx, input, options)
+
+	id := *input.MetricDataQueries[0].MetricStat.Metric.Dimensions[0].Value
+	return &cloudwatch.GetMetricDataOutput{
+		MetricDataResults: []*cloudwatch.MetricDataResult{{
+			Id:     aws.String("connections"),
+			Values: aws.Float64Slice(connections[id]),
+		}},
+	}, args.Error(0)
+}
+
+type priceRange struct {
+	begin string
+	end   string
+	usd   string
+}
+
+func newPriceItem(attributes pricing.AWSPriceItemProductAttributes, unit string, ranges ...priceRange) *pricing.AWSPriceItem {
+	dimensions := make(map[string]pricing.AWSPriceItemPriceDimension)
+	for i, r := range ranges {
+		dimensions[string(rune('1'+i))] = pricing.AWSPriceItemPriceDimension{
+			Unit:         unit,
+			BeginRange:   r.begin,
+			EndRange:     r.end,
+			PricePerUnit: pricing.AWSPriceItemPricePerUnit{USD: r.usd},
+		}
+	}
+
+	return &pricing.AWSPriceItem{
+		Product: pricing.AWSPriceItemProduct{Attributes: attributes},
+		Terms: pricing.AWSPriceItemTerms{
+			OnDemand: map[string]pricing.AWSPriceItemOnDemand{
+				"1": {PriceDimensions: dimensions},
+			},
+		},
+	}
+}
+
+// productFamily matches the GetProducts calls filtering on a product family
+func productFamily(family string) interface{} {
+	return mock.MatchedBy(func(input *pricing.GetProductsInput) bool {
+		for _, filter := range input.Filters {
+			if aws.StringValue(filter.Field) == "productFamily" {
+				return aws.StringValue(filter.Value) == family
+			}
+		}
+		return false
+	})
+}
+
+type RDSTestSuite struct {
+	suite.Suite
+	m      *mockedRDS
+	c      *mockedCloudwatch
+	p      *pricingTest.MockedPricingInterface
+	region string
+	client Client
+}
+
+func (suite *RDSTestSuite) SetupTest() {
+	suite.m = new(mockedRDS)
+	suite.c = new(mockedCloudwatch)
+	suite.p = new(pricingTest.MockedPricingInterface)
+	suite.region = "us-east-1"
+	suite.client = Client{RDS: suite.m, Cloudwatch: suite.c, Pricing: suite.p}
+}
+
+func (suite *RDSTestSuite) MockPricingGood() {
+	suite.p.On("GetProducts", mock.Anything, productFamily("Database Instance")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{InstanceType: "db.t3.micro"}, "Hrs",
+				priceRange{begin: "0", end: "Inf", usd: "0.0170000000"},
+			),
+		}, nil)
+	suite.p.On("GetProducts", mock.Anything, productFamily("Database Storage")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{DatabaseEngine: "SQL Server", VolumeType: "General Purpose"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.2300000000"},
+			),
+			newPriceItem(pricing.AWSPriceItemProductAttributes{DatabaseEngine: "Any", VolumeType: "General Purpose"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.1150000000"},
+			),
+		}, nil)
+	suite.p.On("GetProducts", mock.Anything, productFamily("Provisioned IOPS")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{}, "IOPS-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.1000000000"},
+			),
+		}, nil)
+}
+
+func (suite *RDSTestSuite) MockDatabases() {
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	young := time.Now().Add(-time.Hour)
+
+	suite.m.On("DescribeDBInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&rds.DescribeDBInstancesOutput{
+			DBInstances: []*rds.DBInstance{
+				{
+					DBInstanceIdentifier: aws.String("idle-instance"),
+					DBInstanceClass:      aws.String("db.t3.micro"),
+					DBInstanceStatus:     aws.String("available"),
+					Engine:               aws.String("postgres"),
+					StorageType:          aws.String("gp2"),
+					AllocatedStorage:     aws.Int64(100),
+					InstanceCreateTime:   &old,
+				},
+				{
+					DBInstanceIdentifier: aws.String("busy-instance"),
+					DBInstanceStatus:     aws.String("available"),
+					Engine:               aws.String("postgres"),
+					InstanceCreateTime:   &old,
+				},
+				{
+					DBInstanceIdentifier: aws.String("young-instance"),
+					DBInstanceStatus:     aws.String("available"),
+					Engine:               aws.String("postgres"),
+					InstanceCreateTime:   &young,
+				},
+				{
+					DBInstanceIdentifier: aws.String("stopped-instance"),
+					DBInstanceClass:      aws.String("db.t3.micro"),
+					DBInstanceStatus:     aws.String("stopped"),
+					Engine:               aws.String("mysql"),
+					StorageType:          aws.String("io1"),
+					AllocatedStorage:     aws.Int64(100),
+					Iops:                 aws.Int64(1000),
+					InstanceCreateTime:   &old,
+				},
+				{
+					DBInstanceIdentifier: aws.String("stopped-gp2-instance"),
+					DBInstanceClass:      aws.String("db.t3.micro"),
+					DBInstanceStatus:     aws.String("stopped"),
+					Engine:               aws.String("mariadb"),
+					StorageType:          aws.String("gp2"),
+					AllocatedStorage:     aws.Int64(20),
+					InstanceCreateTime:   &old,
+				},
+				{
+					DBInstanceIdentifier: aws.String("idle-cluster-1"),
+					DBInstanceClass:      aws.String("db.t3.micro"),
+					DBInstanceStatus:     aws.String("available"),
+					DBClusterIdentifier:  aws.String("idle-cluster"),
+					Engine:               aws.String("aurora-mysql"),
+					InstanceCreateTime:   &old,
+				},
+				{
+					DBInstanceIdentifier: aws.String("idle-cluster-2"),
+					DBInstanceClass:      aws.String("db.t3.micro"),
+					DBInstanceStatus:     aws.String("available"),
+					DBClusterIdentifier:  aws.String("idle-cluster"),
+					Engine:               aws.String("aurora-mysql"),
+					InstanceCreateTime:   &old,
+				},
+			},
+		}, nil)
+
+	suite.m.On("DescribeDBClustersPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&rds.DescribeDBClustersOutput{
+			DBClusters: []*rds.DBCluster{
+				{
+					DBClusterIdentifier: aws.String("idle-cluster"),
+					Status:              aws.String("available"),
+					Engine:              aws.String("aurora-mysql"),
+					EngineMode:          aws.String("provisioned"),
+					ClusterCreateTime:   &old,
+					DBClusterMembers: []*rds.DBClusterMember{
+						{DBInstanceIdentifier: aws.String("idle-cluster-1")},
+						{DBInstanceIdentifier: aws.String("idle-cluster-2")},
+					},
+				},
+				{
+					DBClusterIdentifier: aws.String("busy-cluster"),
+					Status:              aws.String("available"),
+					Engine:              aws.String("aurora-postgresql"),
+					ClusterCreateTime:   &old,
+				},
+				{
+					DBClusterIdentifier: aws.String("serverless-cluster"),
+					Status:              aws.String("available"),
+					Engine:              aws.String("aurora-mysql"),
+					EngineMode:          aws.String("serverless"),
+					ClusterCreateTime:   &old,
+				},
+			},
+		}, nil)
+}
+
+func (suite *RDSTestSuite) TestGetIdleDatabases() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	idleDatabases, err := suite.client.GetIdleDatabases(context.TODO())
+	assert.Nil(err)
+	if assert.Equal(2, len(idleDatabases)) {
+		assert.Equal("idle-instance", idleDatabases[0].R.ID())
+		assert.Equal(dbInstanceType, idleDatabases[0].R.Type())
+		assert.Equal("idle-cluster", idleDatabases[1].R.ID())
+		assert.Equal(dbClusterType, idleDatabases[1].R.Type())
+	}
+
+	// Only the old enough, provisioned databases are checked
+	suite.c.AssertNumberOfCalls(suite.T(), "GetMetricDataWithContext", 4)
+}
+
+func (suite *RDSTestSuite) TestGetIdleDatabasesMinUsage() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+	suite.client.IdleMetrics = util.MetricSettings{Stat: "Average", MinUsage: 1}
+	defer func() { suite.client.IdleMetrics = util.MetricSettings{} }()
+
+	idleDatabases, err := suite.client.GetIdleDatabases(context.TODO())
+	assert.Nil(err)
+
+	var ids []string
+	for _, idle := range idleDatabases {
+		ids = append(ids, idle.R.ID())
+	}
+	assert.Equal([]string{"idle-instance", "idle-cluster", "busy-cluster"}, ids)
+
+	input := suite.c.Calls[0].Arguments.Get(1).(*cloudwatch.GetMetricDataInput)
+	assert.Equal("Average", aws.StringValue(input.MetricDataQueries[0].MetricStat.Stat))
+}
+
+func (suite *RDSTestSuite) TestGetIdleDatabasesError() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("error"))
+
+	idleDatabases, err := suite.client.GetIdleDatabases(context.TODO())
+	assert.Nil(idleDatabases)
+	assert.NotNil(err)
+}
+
+func (suite *RDSTestSuite) TestAnalyzeIdleDatabaseWaste() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.MockPricingGood()
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	wastedResources, err := suite.client.AnalyzeIdleDatabaseWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+	if assert.Equal(2, len(wastedResources)) {
+		// The instance pays for its storage on top of its class
+		assert.Equal("Hr", wastedResources[0].Price.Unit)
+		assert.InDelta(0.017+0.115*100/util.HoursPerMonth, wastedResources[0].Price.Rate, 1e-9)
+
+		// The cluster pays for both of its instances
+		assert.Equal("Hr", wastedResources[1].Price.Unit)
+		assert.InDelta(2*0.017, wastedResources[1].Price.Rate, 1e-9)
+
+		assert.Equal("0", wastedResources[0].Evidence["DatabaseConnections.Maximum.max"])
+		assert.Equal("1d", wastedResources[1].Evidence[util.EvidencePeriod])
+		assert.Contains(wastedResources[1].Evidence, util.EvidenceMetricStartTime)
+		assert.Equal(RuleDatabaseIdle, wastedResources[0].Reason.Rule)
+		assert.Equal("no connections over 14d", wastedResources[0].Reason.Explanation)
+	}
+}
+
+func (suite *RDSTestSuite) TestAnalyzeIdleDatabaseWastePricingError() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.p.On("GetProducts", mock.Anything, productFamily("Database Instance")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{InstanceType: "db.t3.micro"}, "Hrs",
+				priceRange{begin: "0", end: "Inf", usd: "0.0170000000"},
+			),
+		}, nil)
+	suite.p.On("GetProducts", mock.Anything, productFamily("Database Storage")).
+		Return(nil, errors.New("error"))
+	suite.c.On("GetMetricDataWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil)
+
+	// The storage of the instance can't be priced, the Aurora cluster doesn't need it
+	wastedResources, err := suite.client.AnalyzeIdleDatabaseWaste(context.TODO(), suite.region)
+	if assert.Equal(1, len(wastedResources)) {
+		assert.Equal("idle-cluster", wastedResources[0].Resource.R.ID())
+	}
+
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("idle-instance", resourceErrs[0].ResourceID)
+		assert.Equal("GetProducts", resourceErrs[0].Operation)
+	}
+}
+
+func (suite *RDSTestSuite) TestAnalyzeStoppedDBInstanceWaste() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.MockPricingGood()
+
+	wastedResources, err := suite.client.AnalyzeStoppedDBInstanceWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+	if assert.Equal(2, len(wastedResources)) {
+		assert.Equal("stopped-instance", wastedResources[0].Resource.R.ID())
+		assert.Equal("Mo", wastedResources[0].Price.Unit)
+		// 100 GB of storage plus 1000 provisioned IOPS
+		assert.InDelta(0.115*100+0.1*1000, wastedResources[0].Price.Rate, 1e-9)
+		assert.Equal(util.Reason{Rule: RuleInstanceStopped, Explanation: "stopped-instance is stopped, still paying for 100 GB of storage"}, wastedResources[0].Reason)
+		assert.Equal("stopped", wastedResources[0].Evidence["DBInstanceStatus"])
+
+		assert.Equal("stopped-gp2-instance", wastedResources[1].Resource.R.ID())
+		assert.InDelta(0.115*20, wastedResources[1].Price.Rate, 1e-9)
+	}
+}
+
+func (suite *RDSTestSuite) TestAnalyzeStoppedDBInstanceWastePricingError() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.p.On("GetProducts", mock.Anything, productFamily("Database Storage")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{DatabaseEngine: "Any", VolumeType: "General Purpose"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.1150000000"},
+			),
+		}, nil)
+	suite.p.On("GetProducts", mock.Anything, productFamily("Provisioned IOPS")).
+		Return(nil, errors.New("error"))
+
+	// The io1 instance can't be priced, the gp2 one is still reported
+	wastedResources, err := suite.client.AnalyzeStoppedDBInstanceWaste(context.TODO(), suite.region)
+	if assert.Equal(1, len(wastedResources)) {
+		assert.Equal("stopped-gp2-instance", wastedResources[0].Resource.R.ID())
+	}
+
+	resourceErrs, err := util.SplitResourceErrors(err)
+	assert.Nil(err)
+	if assert.Equal(1, len(resourceErrs)) {
+		assert.Equal("stopped-instance", resourceErrs[0].ResourceID)
+		assert.Equal("GetProducts", resourceErrs[0].Operation)
+	}
+}
+
+func (suite *RDSTestSuite) TestGetDBInstancePricing() {
+	assert := assert.New(suite.T())
+
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return([]*pricing.AWSPriceItem{}, nil)
+
+	instance := &rds.DBInstance{
+		DBInstanceClass: aws.String("db.m5.large"),
+		Engine:          aws.String("sqlserver-se"),
+		LicenseModel:    aws.String("license-included"),
+		MultiAZ:         aws.Bool(true),
+	}
+
+	price, err := suite.client.GetDBInstancePricing(context.TODO(), suite.region, instance)
+	assert.Nil(price)
+	assert.True(errors.Is(err, util.NoResourceFoundError))
+
+	input := suite.p.Calls[0].Arguments.Get(1).(*pricing.GetProductsInput)
+	assert.Equal(pricing.RDS, input.ServiceCode)
+
+	filters := make(map[string]string)
+	for _, filter := range input.Filters {
+		filters[aws.StringValue(filter.Field)] = aws.StringValue(filter.Value)
+	}
+	assert.Equal(map[string]string{
+		"productFamily":    "Database Instance",
+		"instanceType":     "db.m5.large",
+		"databaseEngine":   "SQL Server",
+		"databaseEdition":  "Standard",
+		"deploymentOption": "Multi-AZ",
+		"licenseModel":     "License included",
+	}, filters)
+
+	// Test error cases
+	price, err = suite.client.GetDBInstancePricing(context.TODO(), suite.region, &rds.DBInstance{Engine: aws.String("neptune")})
+	assert.Nil(price)
+	assert.True(errors.Is(err, unknownEngineError))
+
+	price, err = suite.client.GetDBStoragePricing(context.TODO(), suite.region, &rds.DBInstance{StorageType: aws.String("aurora")})
+	assert.Nil(price)
+	assert.True(errors.Is(err, unknownStorageTypeError))
+}
+
+func (suite *RDSTestSuite) TestGetDBInstancePricingAurora() {
+	assert := assert.New(suite.T())
+
+	suite.p.On("GetProducts", mock.Anything, productFamily("Database Instance")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{InstanceType: "db.r6g.large"}, "Hrs",
+				priceRange{begin: "0", end: "Inf", usd: "0.338"}),
+		}, nil).Once()
+
+	instance := &rds.DBInstance{
+		DBInstanceClass: aws.String("db.r6g.large"),
+		Engine:          aws.String("aurora-postgresql"),
+		StorageType:     aws.String("aurora-iopt1"),
+	}
+
+	price, err := suite.client.GetDBInstancePricing(context.TODO(), suite.region, instance)
+	assert.Nil(err)
+	assert.Equal(0.338, price.Rate)
+
+	input := suite.p.Calls[0].Arguments.Get(1).(*pricing.GetProductsInput)
+	filters := make(map[string]string)
+	for _, filter := range input.Filters {
+		filters[aws.StringValue(filter.Field)] = aws.StringValue(filter.Value)
+	}
+	assert.Equal("Aurora IO Optimization Mode", filters["storage"])
+
+	// Test error cases
+	suite.p.On("GetProducts", mock.Anything, productFamily("Database Instance")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{InstanceType: "db.r6g.large"}, "Hrs",
+				priceRange{begin: "0", end: "Inf", usd: "0.26"}),
+			newPriceItem(pricing.AWSPriceItemProductAttributes{InstanceType: "db.r6g.large"}, "Hrs",
+				priceRange{begin: "0", end: "Inf", usd: "0.338"}),
+		}, nil).Once()
+
+	price, err = suite.client.GetDBInstancePricing(context.TODO(), suite.region, instance)
+	assert.Nil(price)
+	assert.True(errors.Is(err, multiplePriceCodesError))
+
+	instance.StorageType = aws.String("gp3")
+	price, err = suite.client.GetDBInstancePricing(context.TODO(), suite.region, instance)
+	assert.Nil(price)
+	assert.True(errors.Is(err, unknownStorageTypeError))
+}
+
+func (suite *RDSTestSuite) TestGetDBStoragePricingIO2() {
+	assert := assert.New(suite.T())
+
+	suite.p.On("GetProducts", mock.Anything, productFamily("Database Storage")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{VolumeType: "Provisioned IOPS-IO2"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.125"}),
+		}, nil)
+	suite.p.On("GetProducts", mock.Anything, productFamily("Provisioned IOPS")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "RDS:PIOPS"}, "IOPS-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.1"}),
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "RDS:PIOPS-IO2"}, "IOPS-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.2"}),
+		}, nil)
+
+	price, err := suite.client.GetDBStoragePricing(context.TODO(), suite.region, &rds.DBInstance{
+		Engine:           aws.String("oracle-ee-cdb"),
+		StorageType:      aws.String("io2"),
+		AllocatedStorage: aws.Int64(100),
+		Iops:             aws.Int64(1000),
+	})
+	assert.Nil(err)
+	if assert.NotNil(price) {
+		assert.InDelta(0.125*100+0.2*1000, price.Rate, 1e-9)
+	}
+
+	input := suite.p.Calls[0].Arguments.Get(1).(*pricing.GetProductsInput)
+	for _, filter := range input.Filters {
+		if aws.StringValue(filter.Field) == "volumeType" {
+			assert.Equal("Provisioned IOPS-IO2", aws.StringValue(filter.Value))
+		}
+	}
+}
+
+func TestRDSTestSuite(t *testing.T) {
+	suite.Run(t, new(RDSTestSuite))
+}
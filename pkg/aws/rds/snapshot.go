@@ -0,0 +1,107 @@
+package rds
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+
+	util "github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+type DBSnapshot struct {
+	r *rds.DBSnapshot
+}
+
+func (r DBSnapshot) Type() string {
+	return dbSnapshotType
+}
+
+func (r DBSnapshot) ID() string {
+	return aws.StringValue(r.r.DBSnapshotIdentifier)
+}
+
+func (r DBSnapshot) Tags() map[string]string {
+	return tagMap(r.r.TagList)
+}
+
+// AnalyzeOrphanedDBSnapshotWaste prices the manual snapshots of deleted instances. The
+// API doesn't expose the size of a snapshot, so they're priced by the storage allocated
+// to the instance, which is an upper bound.
+func (client *Client) AnalyzeOrphanedDBSnapshotWaste(ctx context.Context, region string) ([]util.AWSWastedResource, error) {
+	orphanedSnapshots, err := client.GetOrphanedDBSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(orphanedSnapshots) == 0 {
+		return nil, nil
+	}
+
+	price, err := client.GetDBSnapshotPricing(ctx, region)
+	if err != nil {
+		return nil, err
+	}
+
+	var wastedResources []util.AWSWastedResource
+
+	for _, orphanedResource := range orphanedSnapshots {
+		orphanedSnapshot, ok := orphanedResource.R.(*DBSnapshot)
+		if !ok {
+			return nil, util.PricingError
+		}
+
+		wastedResources = append(wastedResources, util.AWSWastedResource{
+			Resource: orphanedResource,
+			Price: util.Price{
+				Unit: "Mo",
+				Rate: price.Rate * float64(aws.Int64Value(orphanedSnapshot.r.AllocatedStorage)),
+			},
+			Reason: util.Reason{
+				Rule:        RuleSnapshotOrphaned,
+				Explanation: fmt.Sprintf("instance %s no longer exists", aws.StringValue(orphanedSnapshot.r.DBInstanceIdentifier)),
+			},
+			Evidence: map[string]string{
+				util.EvidenceDBInstanceIdentifier: aws.StringValue(orphanedSnapshot.r.DBInstanceIdentifier),
+				util.EvidenceSnapshotCreateTime:   util.FormatTime(aws.TimeValue(orphanedSnapshot.r.SnapshotCreateTime)),
+				util.EvidenceAllocatedStorage:     strconv.FormatInt(aws.Int64Value(orphanedSnapshot.r.AllocatedStorage), 10),
+			},
+		})
+	}
+
+	return wastedResources, nil
+}
+
+// GetOrphanedDBSnapshots returns the available manual snapshots whose instance no longer exists
+func (client *Client) GetOrphanedDBSnapshots(ctx context.Context) ([]util.AWSResourceObject, error) {
+	instances, err := client.describeDBInstances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceIDs := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		instanceIDs[aws.StringValue(instance.DBInstanceIdentifier)] = true
+	}
+
+	var orphanedSnapshots []util.AWSResourceObject
+
+	err = client.RDS.DescribeDBSnapshotsPagesWithContext(ctx, &rds.DescribeDBSnapshotsInput{
+		SnapshotType: aws.String("manual"),
+	}, func(page *rds.DescribeDBSnapshotsOutput, lastPage bool) bool {
+		for _, snapshot := range page.DBSnapshots {
+			if aws.StringValue(snapshot.Status) == "available" && !instanceIDs[aws.StringValue(snapshot.DBInstanceIdentifier)] {
+				orphanedSnapshots = append(orphanedSnapshots, util.AWSResourceObject{R: &DBSnapshot{snapshot}})
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return orphanedSnapshots, nil
+}
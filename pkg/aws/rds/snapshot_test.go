@@ -0,0 +1,91 @@
+package rds
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/pricing"
+)
+
+func (suite *RDSTestSuite) MockSnapshots() {
+	suite.m.On("DescribeDBSnapshotsPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(&rds.DescribeDBSnapshotsOutput{
+			DBSnapshots: []*rds.DBSnapshot{
+				{ // orphaned
+					DBSnapshotIdentifier: aws.String("snap1"),
+					DBInstanceIdentifier: aws.String("deleted-instance"),
+					Status:               aws.String("available"),
+					AllocatedStorage:     aws.Int64(20),
+				},
+				{ // instance still exists
+					DBSnapshotIdentifier: aws.String("snap2"),
+					DBInstanceIdentifier: aws.String("idle-instance"),
+					Status:               aws.String("available"),
+					AllocatedStorage:     aws.Int64(100),
+				},
+				{ // still being created
+					DBSnapshotIdentifier: aws.String("snap3"),
+					DBInstanceIdentifier: aws.String("deleted-instance"),
+					Status:               aws.String("creating"),
+					AllocatedStorage:     aws.Int64(20),
+				},
+			},
+		}, nil)
+}
+
+func (suite *RDSTestSuite) TestAnalyzeOrphanedDBSnapshotWaste() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.MockSnapshots()
+	suite.p.On("GetProducts", mock.Anything, productFamily("Storage Snapshot")).
+		Return([]*pricing.AWSPriceItem{
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "USW2-Aurora:BackupUsage"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.0210000000"},
+			),
+			newPriceItem(pricing.AWSPriceItemProductAttributes{UsageType: "USW2-RDS:ChargedBackupUsage"}, "GB-Mo",
+				priceRange{begin: "0", end: "Inf", usd: "0.0950000000"},
+			),
+		}, nil)
+
+	wastedResources, err := suite.client.AnalyzeOrphanedDBSnapshotWaste(context.TODO(), suite.region)
+	assert.Nil(err)
+	if assert.Equal(1, len(wastedResources)) {
+		assert.Equal("snap1", wastedResources[0].Resource.R.ID())
+		assert.Equal(dbSnapshotType, wastedResources[0].Resource.R.Type())
+		assert.Equal("Mo", wastedResources[0].Price.Unit)
+		assert.InDelta(0.095*20, wastedResources[0].Price.Rate, 1e-9)
+		assert.Equal(RuleSnapshotOrphaned, wastedResources[0].Reason.Rule)
+		assert.Equal("20", wastedResources[0].Evidence["AllocatedStorage"])
+	}
+
+	input := suite.m.Calls[1].Arguments.Get(1).(*rds.DescribeDBSnapshotsInput)
+	assert.Equal("manual", aws.StringValue(input.SnapshotType))
+}
+
+func (suite *RDSTestSuite) TestAnalyzeOrphanedDBSnapshotWasteError() {
+	assert := assert.New(suite.T())
+
+	suite.MockDatabases()
+	suite.MockSnapshots()
+	suite.p.On("GetProducts", mock.Anything, mock.Anything).
+		Return(nil, errors.New("error"))
+
+	wastedResources, err := suite.client.AnalyzeOrphanedDBSnapshotWaste(context.TODO(), suite.region)
+	assert.Nil(wastedResources)
+	assert.NotNil(err)
+
+	suite.m = new(mockedRDS)
+	suite.m.On("DescribeDBInstancesPagesWithContext", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("error"))
+	suite.client.RDS = suite.m
+
+	orphanedSnapshots, err := suite.client.GetOrphanedDBSnapshots(context.TODO())
+	assert.Nil(orphanedSnapshots)
+	assert.NotNil(err)
+}
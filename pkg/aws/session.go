@@ -0,0 +1,251 @@
+package aws
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+const (
+	// defaultEndpoint is the service name of the endpoint URL used by every service without its own
+	defaultEndpoint = "default"
+
+	// sessionTokenProviderName is the provider name of the credentials of an MFA session
+	sessionTokenProviderName = "SessionTokenProvider"
+	// sessionTokenExpiryWindow is how long before they expire the credentials of an MFA session are renewed
+	sessionTokenExpiryWindow = time.Minute
+)
+
+var (
+	UnknownEndpointServiceError = errors.New("Unknown endpoint URL service")
+
+	// endpointServices are the endpoint IDs of the services called by a scan
+	endpointServices = map[string]bool{
+		autoscaling.EndpointsID:   true,
+		cloudwatch.EndpointsID:    true,
+		dynamodb.EndpointsID:      true,
+		ec2.EndpointsID:           true,
+		elb.EndpointsID:           true,
+		iam.EndpointsID:           true,
+		organizations.EndpointsID: true,
+		pricing.EndpointsID:       true,
+		rds.EndpointsID:           true,
+		sts.EndpointsID:           true,
+	}
+
+	// endpointAliases are the endpoint IDs of services better known by another name
+	endpointAliases = map[string]string{
+		"cloudwatch": cloudwatch.EndpointsID,
+		"elb":        elb.EndpointsID,
+		"elbv2":      elbv2.EndpointsID,
+		"pricing":    pricing.EndpointsID,
+	}
+)
+
+// newSession returns a session with the credentials of a shared config profile, the default
+// profile if empty, calling services at the endpoint URLs of the flags. Unless the limiter
+// is nil, every API call of the session and of the sessions and clients derived from it
+// holds a call of the limiter while it's sent.
+func newSession(profile string, limiter util.Limiter) (*session.Session, error) {
+	resolver, err := endpointResolver(viper.GetStringMapString(util.FlagEndpointURL))
+	if err != nil {
+		return nil, err
+	}
+
+	config := aws.Config{}
+	if resolver != nil {
+		config.EndpointResolver = resolver
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		Profile:           profile,
+		SharedConfigState: session.SharedConfigEnable,
+		// Profiles assuming a role with an mfa_serial prompt for the token code
+		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if limiter != nil {
+		sess.Handlers.Send.Swap(corehandlers.SendHandler.Name, limitedSendHandler(limiter))
+	}
+
+	return sess, nil
+}
+
+// limitedSendHandler sends each attempt of a request once the limiter allows it. Requests
+// whose context is done while they wait fail as cancelled without being sent.
+func limitedSendHandler(limiter util.Limiter) request.NamedHandler {
+	return request.NamedHandler{
+		Name: corehandlers.SendHandler.Name,
+		Fn: func(r *request.Request) {
+			if err := limiter.Acquire(r.Context()); err != nil {
+				r.HTTPResponse = &http.Response{Body: ioutil.NopCloser(bytes.NewReader(nil))}
+				r.Error = awserr.New(request.CanceledErrorCode, "request context canceled", err)
+				r.Retryable = aws.Bool(false)
+				return
+			}
+			defer limiter.Release()
+
+			corehandlers.SendHandler.Fn(r)
+		},
+	}
+}
+
+// profileNames returns the shared config profiles to scan from the profile flags, only
+// the default profile if none is set
+func profileNames() []string {
+	var profiles []string
+	seen := make(map[string]bool)
+
+	for _, profile := range append([]string{viper.GetString(util.FlagProfile)}, viper.GetStringSlice(util.FlagProfiles)...) {
+		if profile != "" && !seen[profile] {
+			seen[profile] = true
+			profiles = append(profiles, profile)
+		}
+	}
+
+	if len(profiles) == 0 {
+		return []string{""}
+	}
+
+	return profiles
+}
+
+// endpointResolver returns a resolver calling services at custom endpoint URLs, e.g. those of
+// LocalStack, keyed by endpoint ID or service name. The default key applies to every service
+// without its own URL, the others keep their AWS endpoints. It returns nil without any URL.
+func endpointResolver(urls map[string]string) (endpoints.Resolver, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	resolved := make(map[string]string, len(urls))
+	for name, url := range urls {
+		service := strings.ToLower(name)
+		if alias, ok := endpointAliases[service]; ok {
+			service = alias
+		}
+
+		// A typo would silently scan the real AWS account instead
+		if service != defaultEndpoint && !endpointServices[service] {
+			return nil, errors.Wrap(UnknownEndpointServiceError, fmt.Sprintf("%q", name))
+		}
+		resolved[service] = url
+	}
+
+	return endpoints.ResolverFunc(func(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		url, ok := resolved[service]
+		if !ok {
+			url, ok = resolved[defaultEndpoint]
+		}
+		if !ok {
+			return endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+		}
+
+		return endpoints.ResolvedEndpoint{URL: url, SigningRegion: region}, nil
+	}), nil
+}
+
+// withMFA authenticates the assumption of a role with the MFA device of the MFA flags. The
+// token code is prompted for on stdin unless it's set.
+func withMFA(p *stscreds.AssumeRoleProvider) {
+	serial := viper.GetString(util.FlagMFASerial)
+	if serial == "" {
+		return
+	}
+
+	p.SerialNumber = aws.String(serial)
+	if token := viper.GetString(util.FlagMFAToken); token != "" {
+		p.TokenCode = aws.String(token)
+	} else {
+		p.TokenProvider = stscreds.StdinTokenProvider
+	}
+}
+
+// withSessionToken returns a copy of the session with the credentials of an MFA session
+// of the MFA flags, whose roles are then assumed with MFA in each account. The session
+// must be that of an IAM user, as roles can't get session tokens.
+func withSessionToken(sess *session.Session) *session.Session {
+	serial := viper.GetString(util.FlagMFASerial)
+	if serial == "" {
+		return sess
+	}
+
+	return sess.Copy(&aws.Config{
+		Credentials: credentials.NewCredentials(&sessionTokenProvider{
+			Client:        sts.New(sess, aws.NewConfig().WithRegion(homeRegion(sess))),
+			SerialNumber:  serial,
+			TokenCode:     viper.GetString(util.FlagMFAToken),
+			TokenProvider: stscreds.StdinTokenProvider,
+		}),
+	})
+}
+
+// sessionTokenProvider retrieves the credentials of an MFA session with GetSessionToken
+type sessionTokenProvider struct {
+	credentials.Expiry
+
+	Client       stsiface.STSAPI
+	SerialNumber string
+	// TokenCode is the token code of the MFA device, TokenProvider is asked for one if empty
+	TokenCode     string
+	TokenProvider func() (string, error)
+}
+
+func (p *sessionTokenProvider) Retrieve() (credentials.Value, error) {
+	tokenCode := p.TokenCode
+	if tokenCode == "" {
+		var err error
+		if tokenCode, err = p.TokenProvider(); err != nil {
+			return credentials.Value{ProviderName: sessionTokenProviderName}, err
+		}
+	}
+
+	resp, err := p.Client.GetSessionToken(&sts.GetSessionTokenInput{
+		SerialNumber: aws.String(p.SerialNumber),
+		TokenCode:    aws.String(tokenCode),
+	})
+	if err != nil {
+		return credentials.Value{ProviderName: sessionTokenProviderName}, err
+	}
+
+	p.SetExpiration(aws.TimeValue(resp.Credentials.Expiration), sessionTokenExpiryWindow)
+
+	return credentials.Value{
+		AccessKeyID:     aws.StringValue(resp.Credentials.AccessKeyId),
+		SecretAccessKey: aws.StringValue(resp.Credentials.SecretAccessKey),
+		SessionToken:    aws.StringValue(resp.Credentials.SessionToken),
+		ProviderName:    sessionTokenProviderName,
+	}, nil
+}
@@ -0,0 +1,162 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/corehandlers"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+func TestEndpointResolver(t *testing.T) {
+	assert := assert.New(t)
+
+	resolver, err := endpointResolver(nil)
+	assert.Nil(resolver)
+	assert.Nil(err)
+
+	resolver, err = endpointResolver(map[string]string{
+		"ec2":        "http://localhost:4566",
+		"CloudWatch": "http://localhost:5000",
+	})
+	assert.Nil(err)
+
+	endpoint, err := resolver.EndpointFor("ec2", "eu-west-1")
+	assert.Nil(err)
+	assert.Equal("http://localhost:4566", endpoint.URL)
+	assert.Equal("eu-west-1", endpoint.SigningRegion)
+
+	endpoint, err = resolver.EndpointFor("monitoring", "eu-west-1")
+	assert.Nil(err)
+	assert.Equal("http://localhost:5000", endpoint.URL)
+
+	// Services without their own URL keep their AWS endpoint
+	endpoint, err = resolver.EndpointFor("rds", "eu-west-1")
+	assert.Nil(err)
+	assert.Equal("https://rds.eu-west-1.amazonaws.com", endpoint.URL)
+
+	resolver, err = endpointResolver(map[string]string{
+		defaultEndpoint: "http://localhost:4566",
+		"elbv2":         "http://localhost:5000",
+	})
+	assert.Nil(err)
+
+	endpoint, err = resolver.EndpointFor("rds", endpoints.UsEast1RegionID)
+	assert.Nil(err)
+	assert.Equal("http://localhost:4566", endpoint.URL)
+
+	endpoint, err = resolver.EndpointFor("elasticloadbalancing", endpoints.UsEast1RegionID)
+	assert.Nil(err)
+	assert.Equal("http://localhost:5000", endpoint.URL)
+
+	// Test error cases
+	resolver, err = endpointResolver(map[string]string{"ec3": "http://localhost:4566"})
+	assert.Nil(resolver)
+	assert.True(errors.Is(err, UnknownEndpointServiceError))
+}
+
+func TestLimitedSendHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		_, _ = w.Write([]byte(`<GetCallerIdentityResponse><GetCallerIdentityResult><Account>123456789012</Account></GetCallerIdentityResult></GetCallerIdentityResponse>`))
+	}))
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String(endpoints.UsEast1RegionID),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if !assert.Nil(err) {
+		return
+	}
+	limiter := util.NewLimiter(1)
+	sess.Handlers.Send.Swap(corehandlers.SendHandler.Name, limitedSendHandler(limiter))
+
+	output, err := sts.New(sess).GetCallerIdentityWithContext(context.Background(), &sts.GetCallerIdentityInput{})
+	if assert.Nil(err) {
+		assert.Equal("123456789012", aws.StringValue(output.Account))
+	}
+	// The call was released once sent
+	assert.Equal(0, len(limiter))
+
+	// Calls waiting on a busy limiter give up with their context
+	assert.Nil(limiter.Acquire(context.Background()))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	var awsErr awserr.Error
+	if assert.True(errors.As(err, &awsErr)) {
+		assert.Equal(request.CanceledErrorCode, awsErr.Code())
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestSessionTokenProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	var tokenCodes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		assert.Equal("GetSessionToken", r.Form.Get("Action"))
+		assert.Equal("arn:aws:iam::123456789012:mfa/user", r.Form.Get("SerialNumber"))
+		tokenCodes = append(tokenCodes, r.Form.Get("TokenCode"))
+		_, _ = w.Write([]byte(`<GetSessionTokenResponse><GetSessionTokenResult><Credentials>` +
+			`<AccessKeyId>ASIA</AccessKeyId><SecretAccessKey>secret</SecretAccessKey><SessionToken>token</SessionToken>` +
+			`<Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></GetSessionTokenResult></GetSessionTokenResponse>`))
+	}))
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String(endpoints.UsEast1RegionID),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if !assert.Nil(err) {
+		return
+	}
+
+	provider := &sessionTokenProvider{
+		Client:       sts.New(sess),
+		SerialNumber: "arn:aws:iam::123456789012:mfa/user",
+		TokenProvider: func() (string, error) {
+			return "123456", nil
+		},
+	}
+	creds := credentials.NewCredentials(provider)
+
+	value, err := creds.Get()
+	if assert.Nil(err) {
+		assert.Equal("ASIA", value.AccessKeyID)
+		assert.Equal("token", value.SessionToken)
+	}
+
+	// The MFA session is reused until it expires, so the token code is only asked for once
+	_, err = creds.Get()
+	assert.Nil(err)
+	assert.Equal([]string{"123456"}, tokenCodes)
+
+	// Test error cases
+	provider.TokenProvider = func() (string, error) {
+		return "", errors.New("no token")
+	}
+	_, err = credentials.NewCredentials(provider).Get()
+	assert.NotNil(err)
+}
@@ -0,0 +1,149 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	UnknownAnalyzerError   = errors.New("Unknown analyzer")
+	DuplicateAnalyzerError = errors.New("Analyzer is already registered")
+)
+
+// Analyzer finds a single kind of wasted resource in a region
+type Analyzer interface {
+	// Name uniquely identifies the analyzer, e.g. "ebs-volume"
+	Name() string
+	// Service is the AWS service the analyzer inspects, e.g. "ec2"
+	Service() string
+	Analyze(ctx context.Context, region string) ([]AWSWastedResource, error)
+}
+
+// AnalyzeFunc is the signature of the Analyze*Waste methods on the service clients
+type AnalyzeFunc func(ctx context.Context, region string) ([]AWSWastedResource, error)
+
+type analyzer struct {
+	name    string
+	service string
+	analyze AnalyzeFunc
+}
+
+// NewAnalyzer wraps an AnalyzeFunc into an Analyzer
+func NewAnalyzer(name string, service string, analyze AnalyzeFunc) Analyzer {
+	return &analyzer{
+		name:    name,
+		service: service,
+		analyze: analyze,
+	}
+}
+
+func (a *analyzer) Name() string {
+	return a.name
+}
+
+func (a *analyzer) Service() string {
+	return a.service
+}
+
+func (a *analyzer) Analyze(ctx context.Context, region string) ([]AWSWastedResource, error) {
+	return a.analyze(ctx, region)
+}
+
+// Registry holds the analyzers available for a scan and which of them are enabled
+type Registry struct {
+	analyzers []Analyzer
+	byName    map[string]Analyzer
+	enabled   map[string]bool
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		byName:  make(map[string]Analyzer),
+		enabled: make(map[string]bool),
+	}
+}
+
+// Register adds enabled analyzers to the registry
+func (r *Registry) Register(analyzers ...Analyzer) error {
+	for _, a := range analyzers {
+		if _, ok := r.byName[a.Name()]; ok {
+			return errors.Wrap(DuplicateAnalyzerError, a.Name())
+		}
+
+		r.analyzers = append(r.analyzers, a)
+		r.byName[a.Name()] = a
+		r.enabled[a.Name()] = true
+	}
+
+	return nil
+}
+
+// Analyzers returns every registered analyzer in registration order
+func (r *Registry) Analyzers() []Analyzer {
+	return append([]Analyzer{}, r.analyzers...)
+}
+
+// Enabled returns the enabled analyzers in registration order
+func (r *Registry) Enabled() []Analyzer {
+	var enabled []Analyzer
+	for _, a := range r.analyzers {
+		if r.enabled[a.Name()] {
+			enabled = append(enabled, a)
+		}
+	}
+
+	return enabled
+}
+
+// IsEnabled reports whether the named analyzer is registered and enabled
+func (r *Registry) IsEnabled(name string) bool {
+	return r.enabled[name]
+}
+
+// Only enables the named analyzers and disables all others
+func (r *Registry) Only(names ...string) error {
+	if err := r.checkNames(names); err != nil {
+		return err
+	}
+
+	for name := range r.enabled {
+		r.enabled[name] = false
+	}
+
+	return r.Enable(names...)
+}
+
+// Enable enables the named analyzers
+func (r *Registry) Enable(names ...string) error {
+	return r.setEnabled(names, true)
+}
+
+// Disable disables the named analyzers
+func (r *Registry) Disable(names ...string) error {
+	return r.setEnabled(names, false)
+}
+
+func (r *Registry) setEnabled(names []string, enabled bool) error {
+	if err := r.checkNames(names); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		r.enabled[name] = enabled
+	}
+
+	return nil
+}
+
+func (r *Registry) checkNames(names []string) error {
+	for _, name := range names {
+		if _, ok := r.byName[name]; !ok {
+			return errors.Wrap(UnknownAnalyzerError, fmt.Sprintf("%q", name))
+		}
+	}
+
+	return nil
+}
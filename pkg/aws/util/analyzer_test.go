@@ -0,0 +1,57 @@
+package util
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func noopAnalyze(ctx context.Context, region string) ([]AWSWastedResource, error) {
+	return nil, nil
+}
+
+func analyzerNames(analyzers []Analyzer) []string {
+	names := make([]string, 0, len(analyzers))
+	for _, a := range analyzers {
+		names = append(names, a.Name())
+	}
+	return names
+}
+
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	registry := NewRegistry()
+	err := registry.Register(
+		NewAnalyzer("a", "ec2", noopAnalyze),
+		NewAnalyzer("b", "ec2", noopAnalyze),
+		NewAnalyzer("c", "dynamodb", noopAnalyze),
+	)
+	assert.Nil(err)
+
+	assert.Equal([]string{"a", "b", "c"}, analyzerNames(registry.Analyzers()))
+	assert.Equal([]string{"a", "b", "c"}, analyzerNames(registry.Enabled()))
+
+	assert.Nil(registry.Disable("b"))
+	assert.Equal([]string{"a", "c"}, analyzerNames(registry.Enabled()))
+	assert.False(registry.IsEnabled("b"))
+
+	assert.Nil(registry.Only("b"))
+	assert.Equal([]string{"b"}, analyzerNames(registry.Enabled()))
+
+	assert.Nil(registry.Enable("c"))
+	assert.Equal([]string{"b", "c"}, analyzerNames(registry.Enabled()))
+
+	// Test error cases
+	err = registry.Register(NewAnalyzer("a", "ec2", noopAnalyze))
+	assert.True(errors.Is(err, DuplicateAnalyzerError))
+
+	err = registry.Only("b", "unknown")
+	assert.True(errors.Is(err, UnknownAnalyzerError))
+	assert.Equal([]string{"b", "c"}, analyzerNames(registry.Enabled()))
+
+	err = registry.Disable("unknown")
+	assert.True(errors.Is(err, UnknownAnalyzerError))
+}
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"context"
+	"sync"
+)
+
+// ForEach calls fn for every index in [0, n) with at most concurrency calls running
+// at once. It stops starting new calls once ctx is done and then returns ctx.Err().
+// Callers keep results deterministic by writing them into a slice at index i.
+func ForEach(ctx context.Context, concurrency int, n int, fn func(i int)) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return ctx.Err()
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			fn(i)
+		}(i)
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Limiter bounds the number of calls in flight across every goroutine sharing it
+type Limiter chan struct{}
+
+// NewLimiter returns a limiter of at most n calls in flight, at least one
+func NewLimiter(n int) Limiter {
+	if n < 1 {
+		n = 1
+	}
+	return make(Limiter, n)
+}
+
+// Acquire waits for a call to be allowed, or returns ctx.Err() once ctx is done. Every
+// successful Acquire must be followed by a Release.
+func (l Limiter) Acquire(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case l <- struct{}{}:
+		return nil
+	}
+}
+
+// Release ends a call allowed by Acquire
+func (l Limiter) Release() {
+	<-l
+}
@@ -0,0 +1,89 @@
+package util
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForEach(t *testing.T) {
+	assert := assert.New(t)
+
+	var running, maxRunning int32
+	results := make([]int, 20)
+
+	err := ForEach(context.Background(), 3, len(results), func(i int) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		results[i] = i * i
+	})
+
+	assert.Nil(err)
+	assert.True(maxRunning <= 3)
+	for i, result := range results {
+		assert.Equal(i*i, result)
+	}
+}
+
+func TestForEachCancelled(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int32
+
+	err := ForEach(ctx, 1, 10, func(i int) {
+		atomic.AddInt32(&calls, 1)
+		cancel()
+	})
+
+	assert.Equal(context.Canceled, err)
+	assert.True(calls < 10)
+}
+
+func TestLimiter(t *testing.T) {
+	assert := assert.New(t)
+
+	limiter := NewLimiter(2)
+	var running, maxRunning int32
+
+	// Nested pools sharing the limiter still run at most its number of calls at once
+	err := ForEach(context.Background(), 4, 4, func(i int) {
+		_ = ForEach(context.Background(), 4, 4, func(j int) {
+			if err := limiter.Acquire(context.Background()); err != nil {
+				return
+			}
+			defer limiter.Release()
+
+			n := atomic.AddInt32(&running, 1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&running, -1)
+		})
+	})
+	assert.Nil(err)
+	assert.True(maxRunning <= 2)
+
+	// Waiting calls give up once their context is done
+	assert.Nil(limiter.Acquire(context.Background()))
+	assert.Nil(limiter.Acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(context.Canceled, limiter.Acquire(ctx))
+}
@@ -0,0 +1,69 @@
+package util
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// HoursPerMonth is the number of hours AWS bills for an average month
+	HoursPerMonth = 730
+	// HoursPerYear is the number of hours in twelve billing months
+	HoursPerYear = HoursPerMonth * 12
+)
+
+var (
+	UnknownPriceUnitError = errors.New("Unknown price unit")
+)
+
+// Cost is a price normalized to hourly, monthly and yearly figures
+type Cost struct {
+	Hourly  float64 `json:"hourly"`
+	Monthly float64 `json:"monthly"`
+	Yearly  float64 `json:"yearly"`
+}
+
+// hoursPerUnit maps the price units used by the analyzers to the hours they cover
+var hoursPerUnit = map[string]float64{
+	"Hr":    1,
+	"Hrs":   1,
+	"Day":   24,
+	"Days":  24,
+	"Mo":    HoursPerMonth,
+	"Month": HoursPerMonth,
+	"Yr":    HoursPerYear,
+	"Year":  HoursPerYear,
+}
+
+// NewCost normalizes a price into a cost
+func NewCost(price Price) (Cost, error) {
+	hours, ok := hoursPerUnit[price.Unit]
+	if !ok {
+		return Cost{}, errors.Wrap(UnknownPriceUnitError, fmt.Sprintf("%q", price.Unit))
+	}
+
+	hourly := price.Rate / hours
+
+	return Cost{
+		Hourly:  hourly,
+		Monthly: hourly * HoursPerMonth,
+		Yearly:  hourly * HoursPerYear,
+	}, nil
+}
+
+// Add returns the sum of two costs
+func (c Cost) Add(other Cost) Cost {
+	return Cost{
+		Hourly:  c.Hourly + other.Hourly,
+		Monthly: c.Monthly + other.Monthly,
+		Yearly:  c.Yearly + other.Yearly,
+	}
+}
+
+// Cost returns the normalized cost of the wasted resource. Prices in an
+// unknown unit have a zero cost; use NewCost to detect them.
+func (r AWSWastedResource) Cost() Cost {
+	cost, _ := NewCost(r.Price)
+	return cost
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCost(t *testing.T) {
+	assert := assert.New(t)
+
+	cost, err := NewCost(Price{Unit: "Hrs", Rate: 0.045})
+	assert.Nil(err)
+	assert.InDelta(0.045, cost.Hourly, 1e-9)
+	assert.InDelta(32.85, cost.Monthly, 1e-9)
+	assert.InDelta(394.2, cost.Yearly, 1e-9)
+
+	cost, err = NewCost(Price{Unit: "Mo", Rate: 50})
+	assert.Nil(err)
+	assert.InDelta(50.0/730, cost.Hourly, 1e-9)
+	assert.InDelta(50, cost.Monthly, 1e-9)
+	assert.InDelta(600, cost.Yearly, 1e-9)
+
+	// Test error cases
+	cost, err = NewCost(Price{Unit: "GB-Mo", Rate: 0.1})
+	assert.True(errors.Is(err, UnknownPriceUnitError))
+	assert.Equal(Cost{}, cost)
+}
+
+func TestCostAdd(t *testing.T) {
+	assert := assert.New(t)
+
+	total := Cost{Hourly: 1, Monthly: 730, Yearly: 8760}.Add(Cost{Hourly: 2, Monthly: 1460, Yearly: 17520})
+	assert.Equal(Cost{Hourly: 3, Monthly: 2190, Yearly: 26280}, total)
+
+	resource := AWSWastedResource{Price: Price{Unit: "Hr", Rate: 1}}
+	assert.Equal(Cost{Hourly: 1, Monthly: 730, Yearly: 8760}, resource.Cost())
+}
@@ -0,0 +1,96 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+)
+
+// ResourceError is the failure to inspect a single resource. Analyzers collect them
+// instead of giving up, so one resource doesn't hide the waste of all the others.
+type ResourceError struct {
+	// ResourceID identifies the resource, empty when a whole analyzer failed
+	ResourceID string
+	// Operation is the AWS API operation that failed, e.g. DescribeTable
+	Operation string
+	// Code is the AWS error code, e.g. AccessDeniedException, empty for other errors
+	Code string
+	Err  error
+	// Analyzer, Region and AccountID are set by the scan
+	Analyzer  string
+	Region    string
+	AccountID string
+}
+
+// NewResourceError records the failure of an operation on a resource
+func NewResourceError(resourceID string, operation string, err error) *ResourceError {
+	resourceErr := &ResourceError{
+		ResourceID: resourceID,
+		Operation:  operation,
+		Err:        err,
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		resourceErr.Code = awsErr.Code()
+	}
+
+	return resourceErr
+}
+
+func (e *ResourceError) Error() string {
+	var parts []string
+	for _, part := range []string{e.ResourceID, e.Operation} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	return strings.Join(append(parts, e.Err.Error()), ": ")
+}
+
+func (e *ResourceError) Unwrap() error {
+	return e.Err
+}
+
+// ResourceErrors are the failures of the resources an analyzer couldn't inspect. Analyzers
+// return them as their error along with the waste found in every other resource.
+type ResourceErrors []*ResourceError
+
+func (e ResourceErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%d resources failed, first: %v", len(e), e[0])
+}
+
+// Append adds the failure of a resource. The API calls on a resource usually fail
+// with a ResourceError already, anything else is recorded without an operation.
+func (e ResourceErrors) Append(resourceID string, err error) ResourceErrors {
+	var resourceErr *ResourceError
+	if !errors.As(err, &resourceErr) {
+		resourceErr = NewResourceError(resourceID, "", err)
+	}
+
+	return append(e, resourceErr)
+}
+
+// ErrorOrNil returns the failures as an error, or nil if there are none
+func (e ResourceErrors) ErrorOrNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// SplitResourceErrors separates the per-resource failures returned along with partial
+// results from any other error, which fails the whole call
+func SplitResourceErrors(err error) (ResourceErrors, error) {
+	var resourceErrs ResourceErrors
+	if errors.As(err, &resourceErrs) {
+		return resourceErrs, nil
+	}
+	return nil, err
+}
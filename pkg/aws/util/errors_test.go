@@ -0,0 +1,52 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewResourceError(t *testing.T) {
+	assert := assert.New(t)
+
+	awsErr := awserr.New("AccessDeniedException", "not authorized", nil)
+	err := NewResourceError("table1", "DescribeTable", errors.Wrap(awsErr, "describing"))
+	assert.Equal("AccessDeniedException", err.Code)
+	assert.True(errors.Is(err, awsErr))
+	assert.Equal("table1: DescribeTable: describing: AccessDeniedException: not authorized", err.Error())
+
+	err = NewResourceError("", "", errors.New("failed"))
+	assert.Equal("", err.Code)
+	assert.Equal("failed", err.Error())
+}
+
+func TestResourceErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var resourceErrs ResourceErrors
+	assert.Nil(resourceErrs.ErrorOrNil())
+
+	resourceErrs = resourceErrs.Append("vol-1", NewResourceError("vol-1", "DescribeVolumes", errors.New("failed")))
+	resourceErrs = resourceErrs.Append("vol-2", errors.New("failed"))
+	if assert.Equal(2, len(resourceErrs)) {
+		assert.Equal("DescribeVolumes", resourceErrs[0].Operation)
+		assert.Equal("vol-2", resourceErrs[1].ResourceID)
+		assert.Equal("", resourceErrs[1].Operation)
+	}
+	assert.Equal("2 resources failed, first: vol-1: DescribeVolumes: failed", resourceErrs.Error())
+
+	// Per-resource failures are split from any other error
+	split, err := SplitResourceErrors(errors.Wrap(resourceErrs.ErrorOrNil(), "analyzing"))
+	assert.Nil(err)
+	assert.Equal(resourceErrs, split)
+
+	split, err = SplitResourceErrors(errors.New("failed"))
+	assert.Nil(split)
+	assert.NotNil(err)
+
+	split, err = SplitResourceErrors(nil)
+	assert.Nil(split)
+	assert.Nil(err)
+}
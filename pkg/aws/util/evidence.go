@@ -0,0 +1,58 @@
+package util
+
+// Evidence keys are named after the AWS API fields and CloudWatch parameters the values
+// come from, e.g. State or CreateTime, so every analyzer reports them the same way. Values
+// the API doesn't describe, e.g. counts, are named in the same style.
+const (
+	// Resources
+	EvidenceState                 = "State"
+	EvidenceCreateTime            = "CreateTime"
+	EvidenceCreatedTime           = "CreatedTime"
+	EvidenceCreationDate          = "CreationDate"
+	EvidenceStartTime             = "StartTime"
+	EvidenceLaunchTime            = "LaunchTime"
+	EvidenceStateTransitionReason = "StateTransitionReason"
+	EvidenceInstanceType          = "InstanceType"
+	EvidenceVolumeType            = "VolumeType"
+	EvidenceSize                  = "Size"
+	EvidenceVolumeID              = "VolumeId"
+	EvidenceVolumeSize            = "VolumeSize"
+	EvidenceStorageTier           = "StorageTier"
+	EvidenceVolumeIDs             = "VolumeIds"
+	EvidenceSnapshotIDs           = "SnapshotIds"
+	EvidenceAllocationIDs         = "AllocationIds"
+	EvidencePublicIP              = "PublicIp"
+	EvidenceDomain                = "Domain"
+	EvidenceVpcID                 = "VpcId"
+	EvidenceRouteTableCount       = "RouteTableCount"
+	EvidenceTargetCount           = "TargetCount"
+
+	// DynamoDB tables
+	EvidenceItemCount                 = "ItemCount"
+	EvidenceTableSizeBytes            = "TableSizeBytes"
+	EvidenceBillingMode               = "BillingMode"
+	EvidenceRecommendation            = "Recommendation"
+	EvidencePointInTimeRecoveryStatus = "PointInTimeRecoveryStatus"
+	EvidenceBackupSizeBytes           = "BackupSizeBytes"
+
+	// RDS instances, clusters and snapshots
+	EvidenceEngine               = "Engine"
+	EvidenceInstanceCreateTime   = "InstanceCreateTime"
+	EvidenceClusterCreateTime    = "ClusterCreateTime"
+	EvidenceDBInstanceStatus     = "DBInstanceStatus"
+	EvidenceDBInstanceIdentifier = "DBInstanceIdentifier"
+	EvidenceAllocatedStorage     = "AllocatedStorage"
+	EvidenceStorageType          = "StorageType"
+	EvidenceSnapshotCreateTime   = "SnapshotCreateTime"
+
+	// CloudWatch metrics, the start and end of the window usage was checked over
+	EvidenceMetricStartTime = "MetricStartTime"
+	EvidenceMetricEndTime   = "MetricEndTime"
+	EvidencePeriod          = "Period"
+)
+
+// EvidenceMetricMax is the evidence key of the largest data point of a metric and
+// statistic, e.g. CPUUtilization.Average.max
+func EvidenceMetricMax(name string, stat string) string {
+	return name + "." + stat + ".max"
+}
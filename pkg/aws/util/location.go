@@ -0,0 +1,145 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/pkg/errors"
+)
+
+var (
+	UnknownRegionError = errors.New("Unknown region")
+)
+
+// Location is where the AWS Price List prices the products of a region
+type Location struct {
+	Region string
+	// Name is the location attribute of the products of the region in the AWS Price List
+	Name      string
+	Partition string
+	// PricingRegion is the region of the Pricing API endpoint that serves the location
+	PricingRegion string
+	// Currency is the currency the AWS Price List prices the location in
+	Currency string
+}
+
+// Currencies of the AWS Price List
+const (
+	CurrencyUSD = "USD"
+	CurrencyCNY = "CNY"
+)
+
+// pricingRegions maps each partition to the region of its Pricing API endpoint. GovCloud
+// has no Pricing API of its own, its prices are served by the commercial endpoint.
+var pricingRegions = map[string]string{
+	endpoints.AwsPartitionID:      endpoints.UsEast1RegionID,
+	endpoints.AwsUsGovPartitionID: endpoints.UsEast1RegionID,
+	endpoints.AwsCnPartitionID:    endpoints.CnNorthwest1RegionID,
+}
+
+// pricingCurrencies maps each partition to the currency of its prices, the China regions
+// are priced in yuan
+var pricingCurrencies = map[string]string{
+	endpoints.AwsPartitionID:      CurrencyUSD,
+	endpoints.AwsUsGovPartitionID: CurrencyUSD,
+	endpoints.AwsCnPartitionID:    CurrencyCNY,
+}
+
+// locationNames maps each region to its location name in the AWS Price List,
+// which doesn't always match the region description of the SDK, e.g. "EU (Ireland)"
+var locationNames = map[string]map[string]string{
+	endpoints.AwsPartitionID: {
+		"af-south-1":     "Africa (Cape Town)",
+		"ap-east-1":      "Asia Pacific (Hong Kong)",
+		"ap-east-2":      "Asia Pacific (Taipei)",
+		"ap-northeast-1": "Asia Pacific (Tokyo)",
+		"ap-northeast-2": "Asia Pacific (Seoul)",
+		"ap-northeast-3": "Asia Pacific (Osaka)",
+		"ap-south-1":     "Asia Pacific (Mumbai)",
+		"ap-south-2":     "Asia Pacific (Hyderabad)",
+		"ap-southeast-1": "Asia Pacific (Singapore)",
+		"ap-southeast-2": "Asia Pacific (Sydney)",
+		"ap-southeast-3": "Asia Pacific (Jakarta)",
+		"ap-southeast-4": "Asia Pacific (Melbourne)",
+		"ap-southeast-5": "Asia Pacific (Malaysia)",
+		"ap-southeast-6": "Asia Pacific (New Zealand)",
+		"ap-southeast-7": "Asia Pacific (Thailand)",
+		"ca-central-1":   "Canada (Central)",
+		"ca-west-1":      "Canada West (Calgary)",
+		"eu-central-1":   "EU (Frankfurt)",
+		"eu-central-2":   "EU (Zurich)",
+		"eu-north-1":     "EU (Stockholm)",
+		"eu-south-1":     "EU (Milan)",
+		"eu-south-2":     "EU (Spain)",
+		"eu-west-1":      "EU (Ireland)",
+		"eu-west-2":      "EU (London)",
+		"eu-west-3":      "EU (Paris)",
+		"il-central-1":   "Israel (Tel Aviv)",
+		"me-central-1":   "Middle East (UAE)",
+		"me-south-1":     "Middle East (Bahrain)",
+		"mx-central-1":   "Mexico (Central)",
+		"sa-east-1":      "South America (Sao Paulo)",
+		"us-east-1":      "US East (N. Virginia)",
+		"us-east-2":      "US East (Ohio)",
+		"us-west-1":      "US West (N. California)",
+		"us-west-2":      "US West (Oregon)",
+	},
+	endpoints.AwsUsGovPartitionID: {
+		"us-gov-east-1": "AWS GovCloud (US-East)",
+		"us-gov-west-1": "AWS GovCloud (US-West)",
+	},
+	endpoints.AwsCnPartitionID: {
+		"cn-north-1":     "China (Beijing)",
+		"cn-northwest-1": "China (Ningxia)",
+	},
+}
+
+// ResolveLocation returns the AWS Price List location of a region
+func ResolveLocation(region string) (*Location, error) {
+	for partition, names := range locationNames {
+		if name, ok := names[region]; ok {
+			return &Location{
+				Region:        region,
+				Name:          name,
+				Partition:     partition,
+				PricingRegion: pricingRegions[partition],
+				Currency:      pricingCurrencies[partition],
+			}, nil
+		}
+	}
+
+	return nil, errors.Wrap(UnknownRegionError, fmt.Sprintf("%q has no known pricing location", region))
+}
+
+// RegionCurrency returns the currency the resources of a region are priced in, USD for
+// unknown regions
+func RegionCurrency(region string) string {
+	location, err := ResolveLocation(region)
+	if err != nil {
+		return CurrencyUSD
+	}
+	return location.Currency
+}
+
+// Locations returns the locations of every known region sorted by region
+func Locations() []Location {
+	var locations []Location
+	for partition, names := range locationNames {
+		for region, name := range names {
+			locations = append(locations, Location{
+				Region:        region,
+				Name:          name,
+				Partition:     partition,
+				PricingRegion: pricingRegions[partition],
+				Currency:      pricingCurrencies[partition],
+			})
+		}
+	}
+
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].Region < locations[j].Region
+	})
+
+	return locations
+}
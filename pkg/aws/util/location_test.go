@@ -0,0 +1,73 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveLocation(t *testing.T) {
+	assert := assert.New(t)
+
+	location, err := ResolveLocation("eu-west-1")
+	if assert.Nil(err) {
+		assert.Equal("EU (Ireland)", location.Name)
+		assert.Equal(endpoints.AwsPartitionID, location.Partition)
+		assert.Equal(endpoints.UsEast1RegionID, location.PricingRegion)
+		assert.Equal(CurrencyUSD, location.Currency)
+	}
+
+	location, err = ResolveLocation("us-gov-west-1")
+	if assert.Nil(err) {
+		assert.Equal("AWS GovCloud (US-West)", location.Name)
+		assert.Equal(endpoints.UsEast1RegionID, location.PricingRegion)
+	}
+
+	location, err = ResolveLocation("cn-north-1")
+	if assert.Nil(err) {
+		assert.Equal("China (Beijing)", location.Name)
+		assert.Equal(endpoints.CnNorthwest1RegionID, location.PricingRegion)
+		assert.Equal(CurrencyCNY, location.Currency)
+	}
+
+	assert.Equal(CurrencyCNY, RegionCurrency("cn-northwest-1"))
+	assert.Equal(CurrencyUSD, RegionCurrency("us-east-9"))
+
+	// Test error cases
+	location, err = ResolveLocation("us-east-9")
+	assert.Nil(location)
+	assert.True(errors.Is(err, UnknownRegionError))
+
+	location, err = ResolveLocation("")
+	assert.Nil(location)
+	assert.True(errors.Is(err, UnknownRegionError))
+}
+
+func TestResolveLocationCoversSDKRegions(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, partition := range endpoints.DefaultPartitions() {
+		if _, ok := pricingRegions[partition.ID()]; !ok {
+			continue
+		}
+
+		for region := range partition.Regions() {
+			location, err := ResolveLocation(region)
+			if assert.Nil(err, region) {
+				assert.Equal(partition.ID(), location.Partition, region)
+			}
+		}
+	}
+}
+
+func TestLocations(t *testing.T) {
+	assert := assert.New(t)
+
+	locations := Locations()
+	assert.Equal("af-south-1", locations[0].Region)
+	for i := 1; i < len(locations); i++ {
+		assert.True(locations[i-1].Region < locations[i].Region)
+	}
+}
@@ -0,0 +1,167 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/pkg/errors"
+)
+
+var (
+	InvalidStatisticError = errors.New("Invalid CloudWatch statistic")
+	InvalidPeriodError    = errors.New("Invalid CloudWatch period")
+)
+
+// percentileStatistic matches the percentile statistics of CloudWatch, e.g. p99 or p99.9
+var percentileStatistic = regexp.MustCompile(`^p\d{1,2}(\.\d+)?$`)
+
+// MetricQuery is a single statistic of a CloudWatch metric over a lookback window
+type MetricQuery struct {
+	// ID identifies the query in the GetMetricData request, e.g. "readcapacity"
+	ID         string
+	Namespace  string
+	MetricName string
+	Dimensions map[string]string
+	Stat       string
+	Period     time.Duration
+	Lookback   time.Duration
+}
+
+// MetricSettings tune how a metric-based analyzer queries the usage of a resource and
+// tells whether it's unused
+type MetricSettings struct {
+	// Lookback is how far back usage is checked
+	Lookback time.Duration
+	// Period is the length of each data point
+	Period time.Duration
+	// Stat is the statistic of each data point, e.g. Sum or Average
+	Stat string
+	// MinUsage is the data point a resource must exceed at least once to be used
+	MinUsage float64
+}
+
+// WithDefaults returns the settings with their zero lookback, period and statistic taken
+// from the defaults. A zero MinUsage is kept, nothing but no usage at all is unused then.
+func (s MetricSettings) WithDefaults(defaults MetricSettings) MetricSettings {
+	if s.Lookback <= 0 {
+		s.Lookback = defaults.Lookback
+	}
+	if s.Period <= 0 {
+		s.Period = defaults.Period
+	}
+	if s.Stat == "" {
+		s.Stat = defaults.Stat
+	}
+	return s
+}
+
+// ValidatePeriod checks that a period is a whole number of minutes
+func ValidatePeriod(period time.Duration) error {
+	if period <= 0 || period%time.Minute != 0 {
+		return errors.Wrap(InvalidPeriodError, fmt.Sprintf("%s isn't a whole number of minutes", period))
+	}
+	return nil
+}
+
+// ValidateStatistic checks that a statistic is one of the CloudWatch statistics or a percentile
+func ValidateStatistic(stat string) error {
+	for _, s := range cloudwatch.Statistic_Values() {
+		if stat == s {
+			return nil
+		}
+	}
+	if percentileStatistic.MatchString(stat) {
+		return nil
+	}
+	return errors.Wrap(InvalidStatisticError, fmt.Sprintf("%q isn't one of %v or a percentile like p99", stat, cloudwatch.Statistic_Values()))
+}
+
+// MetricResult summarizes the data points returned for a MetricQuery
+type MetricResult struct {
+	Start time.Time
+	End   time.Time
+	// Max is the largest data point, zero if there were none
+	Max float64
+	// Sum is the total of the data points
+	Sum        float64
+	DataPoints int
+}
+
+// AddEvidence records the window the result covers and its largest data point into the
+// evidence of a wasted resource, the latter keyed by name and statistic, e.g. CPUUtilization.Average.max
+func (r *MetricResult) AddEvidence(evidence map[string]string, name string, query MetricQuery) {
+	evidence[EvidenceMetricStartTime] = FormatTime(r.Start)
+	evidence[EvidenceMetricEndTime] = FormatTime(r.End)
+	evidence[EvidencePeriod] = FormatDuration(query.Period)
+	evidence[EvidenceMetricMax(name, query.Stat)] = strconv.FormatFloat(r.Max, 'f', -1, 64)
+}
+
+// GetMetric fetches every data point of the query, following NextToken
+func GetMetric(ctx context.Context, cw cloudwatchiface.CloudWatchAPI, query MetricQuery) (*MetricResult, error) {
+	end := time.Now()
+	start := end.Add(-query.Lookback)
+
+	names := make([]string, 0, len(query.Dimensions))
+	for name := range query.Dimensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var dimensions []*cloudwatch.Dimension
+	for _, name := range names {
+		dimensions = append(dimensions, &cloudwatch.Dimension{
+			Name:  aws.String(name),
+			Value: aws.String(query.Dimensions[name]),
+		})
+	}
+
+	input := &cloudwatch.GetMetricDataInput{
+		StartTime: &start,
+		EndTime:   &end,
+		MetricDataQueries: []*cloudwatch.MetricDataQuery{
+			{
+				Id: aws.String(query.ID),
+				MetricStat: &cloudwatch.MetricStat{
+					Period: aws.Int64(int64(query.Period.Seconds())),
+					Stat:   aws.String(query.Stat),
+					Metric: &cloudwatch.Metric{
+						MetricName: aws.String(query.MetricName),
+						Namespace:  aws.String(query.Namespace),
+						Dimensions: dimensions,
+					},
+				},
+			},
+		},
+	}
+
+	result := &MetricResult{Start: start, End: end}
+
+	for {
+		output, err := cw.GetMetricDataWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, metricResult := range output.MetricDataResults {
+			for _, value := range metricResult.Values {
+				if result.DataPoints == 0 || *value > result.Max {
+					result.Max = *value
+				}
+				result.Sum += *value
+				result.DataPoints++
+			}
+		}
+
+		if aws.StringValue(output.NextToken) == "" {
+			return result, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
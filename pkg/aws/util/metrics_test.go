@@ -0,0 +1,131 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockedCloudwatch struct {
+	mock.Mock
+	cloudwatchiface.CloudWatchAPI
+}
+
+func (m *mockedCloudwatch) GetMetricDataWithContext(ctx context.Context, input *cloudwatch.GetMetricDataInput, options ...request.Option) (*cloudwatch.GetMetricDataOutput, error) {
+	args := m.Called(ctx, aws.StringValue(input.NextToken))
+
+	if args.Error(1) == nil {
+		return args.Get(0).(*cloudwatch.GetMetricDataOutput), nil
+	}
+	return nil, args.Error(1)
+}
+
+func TestGetMetric(t *testing.T) {
+	assert := assert.New(t)
+
+	m := new(mockedCloudwatch)
+	m.On("GetMetricDataWithContext", mock.Anything, "").
+		Return(&cloudwatch.GetMetricDataOutput{
+			MetricDataResults: []*cloudwatch.MetricDataResult{{Values: aws.Float64Slice([]float64{1, 4})}},
+			NextToken:         aws.String("page2"),
+		}, nil)
+	m.On("GetMetricDataWithContext", mock.Anything, "page2").
+		Return(&cloudwatch.GetMetricDataOutput{
+			MetricDataResults: []*cloudwatch.MetricDataResult{{Values: aws.Float64Slice([]float64{2})}},
+		}, nil)
+
+	query := MetricQuery{
+		ID:         "connections",
+		Namespace:  "AWS/RDS",
+		MetricName: "DatabaseConnections",
+		Dimensions: map[string]string{"DBInstanceIdentifier": "db1"},
+		Stat:       "Maximum",
+		Period:     time.Hour,
+		Lookback:   24 * time.Hour,
+	}
+
+	result, err := GetMetric(context.Background(), m, query)
+	if assert.Nil(err) {
+		assert.Equal(4.0, result.Max)
+		assert.Equal(7.0, result.Sum)
+		assert.Equal(3, result.DataPoints)
+		assert.Equal(24*time.Hour, result.End.Sub(result.Start))
+	}
+
+	// No data points
+	m = new(mockedCloudwatch)
+	m.On("GetMetricDataWithContext", mock.Anything, "").
+		Return(&cloudwatch.GetMetricDataOutput{}, nil)
+
+	result, err = GetMetric(context.Background(), m, query)
+	if assert.Nil(err) {
+		assert.Equal(0.0, result.Max)
+		assert.Equal(0, result.DataPoints)
+	}
+
+	// Test error cases
+	m = new(mockedCloudwatch)
+	m.On("GetMetricDataWithContext", mock.Anything, "").
+		Return(nil, errors.New("failed"))
+
+	result, err = GetMetric(context.Background(), m, query)
+	assert.Nil(result)
+	assert.NotNil(err)
+}
+
+func TestMetricSettings(t *testing.T) {
+	assert := assert.New(t)
+
+	defaults := MetricSettings{Lookback: 14 * 24 * time.Hour, Period: time.Hour, Stat: "Sum"}
+	assert.Equal(defaults, MetricSettings{}.WithDefaults(defaults))
+	assert.Equal(
+		MetricSettings{Lookback: 14 * 24 * time.Hour, Period: 5 * time.Minute, Stat: "p99", MinUsage: 1},
+		MetricSettings{Period: 5 * time.Minute, Stat: "p99", MinUsage: 1}.WithDefaults(defaults),
+	)
+
+	assert.Nil(ValidatePeriod(5 * time.Minute))
+	assert.Nil(ValidateStatistic("Average"))
+	assert.Nil(ValidateStatistic("p99.9"))
+
+	// Test error cases
+	assert.True(errors.Is(ValidatePeriod(90*time.Second), InvalidPeriodError))
+	assert.True(errors.Is(ValidatePeriod(0), InvalidPeriodError))
+	assert.True(errors.Is(ValidateStatistic("average"), InvalidStatisticError))
+	assert.True(errors.Is(ValidateStatistic("p100.5"), InvalidStatisticError))
+}
+
+func TestAddEvidence(t *testing.T) {
+	assert := assert.New(t)
+
+	result := &MetricResult{
+		Start: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC),
+		End:   time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC),
+		Max:   0.5,
+	}
+
+	evidence := make(map[string]string)
+	result.AddEvidence(evidence, "CPUUtilization", MetricQuery{MetricName: "CPUUtilization", Stat: "Average", Period: time.Hour})
+	assert.Equal(map[string]string{
+		EvidenceMetricStartTime:      "2021-03-01T00:00:00Z",
+		EvidenceMetricEndTime:        "2021-03-15T00:00:00Z",
+		EvidencePeriod:               "1h",
+		"CPUUtilization.Average.max": "0.5",
+	}, evidence)
+}
+
+func TestFormatDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("14d", FormatDuration(14*24*time.Hour))
+	assert.Equal("36h", FormatDuration(36*time.Hour))
+	assert.Equal("5m", FormatDuration(5*time.Minute))
+	assert.Equal("1m30s", FormatDuration(90*time.Second))
+}
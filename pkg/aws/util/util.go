@@ -1,16 +1,115 @@
 package util
 
 import (
-	"strconv"
+	"fmt"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/pkg/errors"
 )
 
 const (
+	// FlagConfig is a viper flag for the config file of the other flags
+	FlagConfig = "config"
 	// FlagRegion is a viper flag for the region to run in
 	FlagRegion = "region"
+	// FlagRegions is a viper flag for a list of regions to run in
+	FlagRegions = "regions"
+	// FlagAllRegions is a viper flag to run in every region enabled for the account
+	FlagAllRegions = "all-regions"
+	// FlagProfile is a viper flag for the shared config profile to scan with
+	FlagProfile = "profile"
+	// FlagProfiles is a viper flag for a list of shared config profiles to scan with
+	FlagProfiles = "profiles"
+	// FlagRoleARN is a viper flag for a role to assume before scanning
+	FlagRoleARN = "role-arn"
+	// FlagRoleName is a viper flag for the name of the role to assume in every scanned account
+	FlagRoleName = "role-name"
+	// FlagAccounts is a viper flag for a list of account IDs to scan
+	FlagAccounts = "accounts"
+	// FlagOrg is a viper flag to scan every active account of the organization
+	FlagOrg = "org"
+	// FlagExternalID is a viper flag for the external ID passed when assuming roles
+	FlagExternalID = "external-id"
+	// FlagMFASerial is a viper flag for the MFA device authenticating the assumption of the role ARN,
+	// or without one the session assuming the role name in each account
+	FlagMFASerial = "mfa-serial"
+	// FlagMFAToken is a viper flag for the token code of the MFA device
+	FlagMFAToken = "mfa-token"
+	// FlagEndpointURL is a viper flag for custom endpoint URLs keyed by service
+	FlagEndpointURL = "endpoint-url"
+	// FlagConcurrency is a viper flag for the maximum number of analyzers run at once and
+	// of API calls in flight across all of them
+	FlagConcurrency = "concurrency"
+	// FlagTimeout is a viper flag for the maximum duration of a scan
+	FlagTimeout = "timeout"
+	// FlagPricingCacheTTL is a viper flag for how long price lists are cached on disk
+	FlagPricingCacheTTL = "pricing-cache-ttl"
+	// FlagRefreshPricing is a viper flag to ignore cached price lists
+	FlagRefreshPricing = "refresh-pricing"
+	// FlagPricingProfile is a viper flag for the shared config profile to call the Pricing API in us-east-1 with
+	FlagPricingProfile = "pricing-profile"
+	// FlagCNPricingProfile is a viper flag for the shared config profile to call the Pricing API of China with
+	FlagCNPricingProfile = "cn-pricing-profile"
+	// FlagPricingFile is a viper flag for AWS Price List offer files to use instead of the Pricing API
+	FlagPricingFile = "pricing-file"
+	// FlagAnalyzers is a viper flag for the only analyzers to run
+	FlagAnalyzers = "analyzers"
+	// FlagDisableAnalyzers is a viper flag for analyzers to skip
+	FlagDisableAnalyzers = "disable-analyzers"
+	// FlagListAnalyzers is a viper flag to list the analyzers instead of scanning
+	FlagListAnalyzers = "list-analyzers"
+	// FlagIncludeTag is a viper flag for tags a wasted resource must have one of to be reported
+	FlagIncludeTag = "include-tag"
+	// FlagExcludeTag is a viper flag for tags that keep a wasted resource out of the report
+	FlagExcludeTag = "exclude-tag"
+	// FlagIgnoreFile is a viper flag for the file of waivers of wasted resources kept on purpose
+	FlagIgnoreFile = "ignore-file"
+	// FlagOutput is a viper flag for the format of the scan report
+	FlagOutput = "output"
+	// FlagOutputFile is a viper flag for the file to write the scan report to
+	FlagOutputFile = "output-file"
+	// FlagSortBy is a viper flag for the order of the wasted resources in the scan report
+	FlagSortBy = "sort-by"
+	// FlagStrict is a viper flag to fail the scan if any resource couldn't be inspected
+	FlagStrict = "strict"
+	// FlagIdleCPUThreshold is a viper flag for the CPU utilization in percent per period below which an instance is idle
+	FlagIdleCPUThreshold = "idle-cpu-threshold"
+	// FlagIdleNetworkThreshold is a viper flag for the megabytes in or out per period below which an instance is idle
+	FlagIdleNetworkThreshold = "idle-network-threshold"
+	// FlagIdleInstanceLookback is a viper flag for how far back instance utilization is checked
+	FlagIdleInstanceLookback = "idle-instance-lookback"
+	// FlagIdleInstancePeriod is a viper flag for the period of the instance utilization data points
+	FlagIdleInstancePeriod = "idle-instance-period"
+	// FlagIdleInstanceStatistic is a viper flag for the statistic of the CPU utilization of instances
+	FlagIdleInstanceStatistic = "idle-instance-statistic"
+	// FlagDynamoDBLookback is a viper flag for how far back DynamoDB consumed capacity is checked
+	FlagDynamoDBLookback = "dynamodb-lookback"
+	// FlagDynamoDBPeriod is a viper flag for the period of the DynamoDB consumed capacity data points
+	FlagDynamoDBPeriod = "dynamodb-period"
+	// FlagDynamoDBStatistic is a viper flag for the statistic of DynamoDB consumed capacity compared to the min usage
+	FlagDynamoDBStatistic = "dynamodb-statistic"
+	// FlagDynamoDBMinUsage is a viper flag for the consumed capacity a DynamoDB table must exceed to be used
+	FlagDynamoDBMinUsage = "dynamodb-min-usage"
+	// FlagRDSIdleLookback is a viper flag for how far back database connections are checked
+	FlagRDSIdleLookback = "rds-idle-lookback"
+	// FlagRDSIdlePeriod is a viper flag for the period of the database connections data points
+	FlagRDSIdlePeriod = "rds-idle-period"
+	// FlagRDSIdleStatistic is a viper flag for the statistic of database connections
+	FlagRDSIdleStatistic = "rds-idle-statistic"
+	// FlagRDSIdleMinUsage is a viper flag for the connections a database must exceed to be used
+	FlagRDSIdleMinUsage = "rds-idle-min-usage"
+	// FlagELBIdleLookback is a viper flag for how far back load balancer traffic is checked
+	FlagELBIdleLookback = "elb-idle-lookback"
+	// FlagELBIdlePeriod is a viper flag for the period of the load balancer traffic data points
+	FlagELBIdlePeriod = "elb-idle-period"
+	// FlagELBIdleStatistic is a viper flag for the statistic of load balancer traffic
+	FlagELBIdleStatistic = "elb-idle-statistic"
+	// FlagELBIdleMinUsage is a viper flag for the requests or bytes a load balancer must exceed to be used
+	FlagELBIdleMinUsage = "elb-idle-min-usage"
+	// FlagStoppedInstanceAge is a viper flag for how long an instance must have been stopped to be waste
+	FlagStoppedInstanceAge = "stopped-instance-age"
+	// FlagUnusedImageAge is a viper flag for how old an unused AMI must be to be waste
+	FlagUnusedImageAge = "unused-image-age"
 )
 
 var (
@@ -21,6 +120,8 @@ var (
 type AWSResource interface {
 	Type() string
 	ID() string
+	// Tags returns the tags of the resource by key, empty if it has none or they couldn't be listed
+	Tags() map[string]string
 }
 
 type AWSResourceObject struct {
@@ -30,92 +131,55 @@ type AWSResourceObject struct {
 type Price struct {
 	Unit string
 	Rate float64
+	// Currency is the currency of the rate, USD if empty
+	Currency string
 }
 
-type AWSWastedResource struct {
-	Resource AWSResourceObject
-	Price    Price
+// CurrencyCode returns the currency of the price
+func (p Price) CurrencyCode() string {
+	if p.Currency == "" {
+		return CurrencyUSD
+	}
+	return p.Currency
 }
 
-type AWSPriceItemDimension struct {
-	BeginRange  string
-	EndRange    string
-	Unit        string
-	Rate        float64
-	Description string
+// Reason is why a resource is wasted
+type Reason struct {
+	// Rule identifies the check the resource failed, e.g. ebs-volume-unattached
+	Rule string
+	// Explanation describes the waste to a reviewer, e.g. "vol-123 is attached to no instance"
+	Explanation string
 }
 
-type AWSPriceItem struct {
-	Attributes map[string]interface{}
-	UsageType  string
-	OnDemand   struct {
-		Dimensions []AWSPriceItemDimension
-	}
+type AWSWastedResource struct {
+	Resource     AWSResourceObject
+	Price        Price
+	Region       string
+	AccountID    string
+	AccountAlias string
+	Reason       Reason
+	// Evidence holds what the resource was judged wasted on by one of the evidence keys,
+	// e.g. its State and CreateTime as the API describes them, or the window its usage was
+	// checked over and the largest data point observed
+	Evidence map[string]string
 }
 
-var RegionLongNames = map[string]string{
-	endpoints.UsEast1RegionID: "US East (N. Virginia)",
-	endpoints.UsEast2RegionID: "US East (Ohio)",
-	endpoints.UsWest2RegionID: "US West (Oregon)",
+// FormatTime formats a time for the evidence of a wasted resource
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
 }
 
-func ParsePriceItem(priceItem aws.JSONValue) (priceItemRet *AWSPriceItem, err error) {
-	priceItemRet = &AWSPriceItem{}
-
-	if product, ok := priceItem["product"].(map[string]interface{}); ok {
-		if attributes, ok := product["attributes"].(map[string]interface{}); ok {
-			usageType, ok := attributes["usagetype"].(string)
-			if !ok {
-				return nil, errors.New("Could not parse Attributes")
-			}
-			priceItemRet.Attributes = attributes
-			priceItemRet.UsageType = usageType
-		} else {
-			return nil, errors.New("Could not parse attributes")
-		}
-	}
-
-	if terms, ok := priceItem["terms"].(map[string]interface{}); ok {
-		if onDemand, ok := terms["OnDemand"].(map[string]interface{}); ok {
-			for _, v := range onDemand {
-				if onDemandValue, ok := v.(map[string]interface{}); ok {
-					if priceDimensions, ok := onDemandValue["priceDimensions"].(map[string]interface{}); ok {
-						for _, v2 := range priceDimensions {
-							if priceDimensionsValue, ok := v2.(map[string]interface{}); ok {
-								pricePerUnit, ok1 := priceDimensionsValue["pricePerUnit"].(map[string]interface{})
-								unit, ok2 := priceDimensionsValue["unit"].(string)
-								beginRange, ok3 := priceDimensionsValue["beginRange"].(string)
-								endRange, ok4 := priceDimensionsValue["endRange"].(string)
-								description, ok5 := priceDimensionsValue["description"].(string)
-
-								if ok1 && ok2 && ok3 && ok4 && ok5 {
-									if usd, ok := pricePerUnit["USD"].(string); ok {
-
-										rate, err := strconv.ParseFloat(usd, 64)
-										if err != nil {
-											return nil, err
-										}
-
-										priceItemRet.OnDemand.Dimensions = append(priceItemRet.OnDemand.Dimensions, AWSPriceItemDimension{
-											BeginRange:  beginRange,
-											EndRange:    endRange,
-											Unit:        unit,
-											Rate:        rate,
-											Description: description,
-										})
-									}
-								} else {
-									return nil, errors.New("Could not parse OnDemand")
-								}
-							}
-						}
-
-						return
-					}
-				}
-			}
-		}
+// FormatDuration formats a duration for the explanation of a wasted resource in the
+// largest whole unit of days, hours or minutes, e.g. 14d
+func FormatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+	switch {
+	case d >= day && d%day == 0:
+		return fmt.Sprintf("%dd", d/day)
+	case d >= time.Hour && d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d >= time.Minute && d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
 	}
-
-	return nil, errors.New("Could not parse OnDemand")
+	return d.String()
 }
@@ -0,0 +1,118 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+var InvalidTagFilterError = errors.New("Invalid tag filter")
+
+// TagFilter matches the resources with a tag, either of a given value or of any value
+type TagFilter struct {
+	Key   string
+	Value string
+	// AnyValue matches the key whatever its value, as opposed to an empty value
+	AnyValue bool
+}
+
+// ParseTagFilter parses a tag filter of the form key=value, or key for any value
+func ParseTagFilter(spec string) (TagFilter, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if parts[0] == "" {
+		return TagFilter{}, errors.Wrap(InvalidTagFilterError, fmt.Sprintf("%q", spec))
+	}
+
+	if len(parts) == 1 {
+		return TagFilter{Key: parts[0], AnyValue: true}, nil
+	}
+	return TagFilter{Key: parts[0], Value: parts[1]}, nil
+}
+
+// ParseTagFilters parses a list of tag filters
+func ParseTagFilters(specs []string) ([]TagFilter, error) {
+	var filters []TagFilter
+	for _, spec := range specs {
+		filter, err := ParseTagFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, filter)
+	}
+
+	return filters, nil
+}
+
+// Matches tells whether the tags of a resource match the filter
+func (f TagFilter) Matches(tags map[string]string) bool {
+	value, ok := tags[f.Key]
+	return ok && (f.AnyValue || value == f.Value)
+}
+
+func (f TagFilter) String() string {
+	if f.AnyValue {
+		return f.Key
+	}
+	return f.Key + "=" + f.Value
+}
+
+// Suppressed is a wasted resource left out of the report and the reason why
+type Suppressed struct {
+	Resource util.AWSWastedResource
+	Reason   string
+}
+
+// TagFilters keep the resources matching any include filter, all of them if there's
+// none, and then leave out the resources matching any exclude filter
+type TagFilters struct {
+	Include []TagFilter
+	Exclude []TagFilter
+}
+
+// Apply returns the resources kept by the filters, and the ones suppressed along with the
+// filter that suppressed them
+func (f TagFilters) Apply(resources []util.AWSWastedResource) ([]util.AWSWastedResource, []Suppressed) {
+	var kept []util.AWSWastedResource
+	var suppressed []Suppressed
+
+	for _, resource := range resources {
+		if reason, ok := f.suppress(resource.Resource.R.Tags()); ok {
+			suppressed = append(suppressed, Suppressed{Resource: resource, Reason: reason})
+			continue
+		}
+		kept = append(kept, resource)
+	}
+
+	return kept, suppressed
+}
+
+// suppress returns why a resource with the given tags is suppressed, if it is
+func (f TagFilters) suppress(tags map[string]string) (string, bool) {
+	if len(f.Include) > 0 && !matchesAny(f.Include, tags) {
+		var include []string
+		for _, filter := range f.Include {
+			include = append(include, filter.String())
+		}
+		return fmt.Sprintf("not tagged %s", strings.Join(include, " or ")), true
+	}
+
+	for _, filter := range f.Exclude {
+		if filter.Matches(tags) {
+			return fmt.Sprintf("tagged %s", filter), true
+		}
+	}
+
+	return "", false
+}
+
+func matchesAny(filters []TagFilter, tags map[string]string) bool {
+	for _, filter := range filters {
+		if filter.Matches(tags) {
+			return true
+		}
+	}
+	return false
+}
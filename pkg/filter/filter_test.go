@@ -0,0 +1,97 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+type testResource struct {
+	id   string
+	tags map[string]string
+}
+
+func (r testResource) Type() string {
+	return "Test Resource"
+}
+
+func (r testResource) ID() string {
+	return r.id
+}
+
+func (r testResource) Tags() map[string]string {
+	return r.tags
+}
+
+func newResource(id string, tags map[string]string) util.AWSWastedResource {
+	return util.AWSWastedResource{Resource: util.AWSResourceObject{R: testResource{id, tags}}}
+}
+
+func TestParseTagFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	filter, err := ParseTagFilter("env=dr")
+	assert.Nil(err)
+	assert.Equal(TagFilter{Key: "env", Value: "dr"}, filter)
+	assert.Equal("env=dr", filter.String())
+
+	filter, err = ParseTagFilter("keep")
+	assert.Nil(err)
+	assert.Equal(TagFilter{Key: "keep", AnyValue: true}, filter)
+	assert.Equal("keep", filter.String())
+
+	filter, err = ParseTagFilter("owner=")
+	assert.Nil(err)
+	assert.Equal(TagFilter{Key: "owner"}, filter)
+	assert.True(filter.Matches(map[string]string{"owner": ""}))
+	assert.False(filter.Matches(map[string]string{"owner": "me"}))
+
+	// Test error cases
+	_, err = ParseTagFilters([]string{"env=dr", "=dr"})
+	assert.True(errors.Is(err, InvalidTagFilterError))
+}
+
+func TestApply(t *testing.T) {
+	assert := assert.New(t)
+
+	resources := []util.AWSWastedResource{
+		newResource("prod-dr", map[string]string{"env": "prod", "purpose": "dr"}),
+		newResource("prod", map[string]string{"env": "prod"}),
+		newResource("staging", map[string]string{"env": "staging", "keep": "true"}),
+		newResource("untagged", nil),
+	}
+
+	kept, suppressed := TagFilters{}.Apply(resources)
+	assert.Equal(resources, kept)
+	assert.Nil(suppressed)
+
+	kept, suppressed = TagFilters{
+		Exclude: []TagFilter{{Key: "purpose", Value: "dr"}, {Key: "keep", AnyValue: true}},
+	}.Apply(resources)
+	if assert.Equal(2, len(kept)) {
+		assert.Equal("prod", kept[0].Resource.R.ID())
+		assert.Equal("untagged", kept[1].Resource.R.ID())
+	}
+	if assert.Equal(2, len(suppressed)) {
+		assert.Equal("prod-dr", suppressed[0].Resource.Resource.R.ID())
+		assert.Equal("tagged purpose=dr", suppressed[0].Reason)
+		assert.Equal("tagged keep", suppressed[1].Reason)
+	}
+
+	// Excluded resources are left out even if they're included
+	kept, suppressed = TagFilters{
+		Include: []TagFilter{{Key: "env", Value: "prod"}, {Key: "env", Value: "test"}},
+		Exclude: []TagFilter{{Key: "purpose", Value: "dr"}},
+	}.Apply(resources)
+	if assert.Equal(1, len(kept)) {
+		assert.Equal("prod", kept[0].Resource.R.ID())
+	}
+	if assert.Equal(3, len(suppressed)) {
+		assert.Equal("tagged purpose=dr", suppressed[0].Reason)
+		assert.Equal("not tagged env=prod or env=test", suppressed[1].Reason)
+		assert.Equal("untagged", suppressed[2].Resource.Resource.R.ID())
+	}
+}
@@ -0,0 +1,231 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+const (
+	// DefaultWaiverFile is the waiver file loaded from the working directory if it exists
+	DefaultWaiverFile = ".cloudwaste-ignore.yaml"
+
+	// WaiversKey is the config key of the list of waivers
+	WaiversKey = "waivers"
+
+	// waiverDateLayout is the layout of the expiry dates of waivers
+	waiverDateLayout = "2006-01-02"
+)
+
+var InvalidWaiverError = errors.New("Invalid waiver")
+
+// Waiver suppresses the waste of a resource kept on purpose until it expires, e.g.
+//
+//	waivers:
+//	  - id: vol-0123456789abcdef0
+//	    type: EBS Volume
+//	    account: "123456789012"
+//	    owner: finops@example.com
+//	    reason: Disaster recovery volume
+//	    expires: 2021-12-31
+type Waiver struct {
+	// ID is the ID or ARN of the resource, or a glob pattern of either. ARNs match
+	// the resources whose ID is the ID in the resource of the ARN, in its account and region.
+	ID string `mapstructure:"id"`
+	// Type and Account only match resources of that type or in that account if set
+	Type    string `mapstructure:"type"`
+	Account string `mapstructure:"account"`
+	Owner   string `mapstructure:"owner"`
+	Reason  string `mapstructure:"reason"`
+	// Expires is the last day the waiver applies
+	Expires time.Time `mapstructure:"expires"`
+}
+
+// String describes the waiver for the logs
+func (w Waiver) String() string {
+	return fmt.Sprintf("%s (owner %s, expires %s)", w.ID, w.Owner, w.Expires.Format(waiverDateLayout))
+}
+
+// Expired tells whether the waiver no longer applies at the given time
+func (w Waiver) Expired(now time.Time) bool {
+	return !now.Before(w.Expires.AddDate(0, 0, 1))
+}
+
+// Matches tells whether the waiver applies to a wasted resource, whether or not it expired
+func (w Waiver) Matches(resource util.AWSWastedResource) bool {
+	if w.Type != "" && !strings.EqualFold(w.Type, resource.Resource.R.Type()) {
+		return false
+	}
+	if w.Account != "" && w.Account != resource.AccountID {
+		return false
+	}
+
+	if !arn.IsARN(w.ID) {
+		return match(w.ID, resource.Resource.R.ID())
+	}
+
+	// The ARN was validated when the waiver was loaded, but waivers may be built in code too
+	parsed, err := arn.Parse(w.ID)
+	if err != nil {
+		return false
+	}
+	if !match(parsed.AccountID, resource.AccountID) || !match(parsed.Region, resource.Region) {
+		return false
+	}
+
+	id, ok := arnResourceID(parsed.Resource)
+	return ok && match(id, resource.Resource.R.ID())
+}
+
+// arnResourceID returns the ID in the resource of an ARN, the part after its type, e.g. vol-123
+// of volume/vol-123 or my-db of db:my-db. Load balancers of ELBv2 are known by their name,
+// e.g. my-alb of loadbalancer/app/my-alb/50dc6c495c0c9188. It's false without an ID.
+func arnResourceID(resource string) (string, bool) {
+	parts := strings.FieldsFunc(resource, func(r rune) bool {
+		return r == '/' || r == ':'
+	})
+	if len(parts) < 2 {
+		return "", false
+	}
+
+	if parts[0] == "loadbalancer" && len(parts) == 4 {
+		return parts[2], true
+	}
+	return parts[len(parts)-1], true
+}
+
+// match tells whether a value matches a glob pattern, any value matching an empty pattern
+func match(pattern string, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+// validate checks that a waiver has all its fields and that its patterns are valid,
+// returning the offending field along with the error
+func (w Waiver) validate() (string, error) {
+	for _, field := range []struct{ name, value string }{{"id", w.ID}, {"owner", w.Owner}, {"reason", w.Reason}} {
+		if field.value == "" {
+			return field.name, errors.New("missing")
+		}
+	}
+	if w.Expires.IsZero() {
+		return "expires", errors.New("missing")
+	}
+
+	pattern := w.ID
+	if arn.IsARN(w.ID) {
+		parsed, err := arn.Parse(w.ID)
+		if err != nil {
+			return "id", err
+		}
+		id, ok := arnResourceID(parsed.Resource)
+		if !ok {
+			return "id", errors.Errorf("no resource ID in ARN resource %q", parsed.Resource)
+		}
+		pattern = id
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "id", err
+	}
+
+	return "", nil
+}
+
+// LoadWaivers reads the waivers of a YAML waiver file
+func LoadWaivers(file string) ([]Waiver, error) {
+	v := viper.New()
+	v.SetConfigFile(file)
+	v.SetConfigType("yaml")
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+
+	return UnmarshalWaivers(v, file)
+}
+
+// UnmarshalWaivers decodes and validates the waivers under the waivers key of a config.
+// Errors name the source of the config and the offending key.
+func UnmarshalWaivers(v *viper.Viper, source string) ([]Waiver, error) {
+	var waivers []Waiver
+	err := v.UnmarshalKey(WaiversKey, &waivers, viper.DecodeHook(mapstructure.StringToTimeHookFunc(waiverDateLayout)))
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("%s: %s", source, WaiversKey))
+	}
+
+	for i, waiver := range waivers {
+		if field, err := waiver.validate(); err != nil {
+			return nil, errors.Wrap(InvalidWaiverError, fmt.Sprintf("%s: %s[%d].%s: %v", source, WaiversKey, i, field, err))
+		}
+	}
+
+	return waivers, nil
+}
+
+// WaiverResult is the outcome of applying waivers to wasted resources
+type WaiverResult struct {
+	// Kept are the resources no waiver applies to
+	Kept       []util.AWSWastedResource
+	Suppressed []Suppressed
+	// Expired are the waivers that no longer apply, whether they match a resource or not
+	Expired []Waiver
+	// Unused are the waivers that apply but match no wasted resource anymore
+	Unused []Waiver
+}
+
+// ApplyWaivers suppresses the wasted resources matched by a waiver that hasn't expired
+func ApplyWaivers(resources []util.AWSWastedResource, waivers []Waiver, now time.Time) WaiverResult {
+	var result WaiverResult
+
+	var active []Waiver
+	for _, waiver := range waivers {
+		if waiver.Expired(now) {
+			result.Expired = append(result.Expired, waiver)
+			continue
+		}
+		active = append(active, waiver)
+	}
+
+	used := make([]bool, len(active))
+	for _, resource := range resources {
+		waived := false
+		for i, waiver := range active {
+			if !waiver.Matches(resource) {
+				continue
+			}
+
+			// Every matching waiver is in use, the first one is the reason
+			if !waived {
+				result.Suppressed = append(result.Suppressed, Suppressed{
+					Resource: resource,
+					Reason:   fmt.Sprintf("waived by %s: %s", waiver.Owner, waiver.Reason),
+				})
+			}
+			used[i] = true
+			waived = true
+		}
+
+		if !waived {
+			result.Kept = append(result.Kept, resource)
+		}
+	}
+
+	for i, waiver := range active {
+		if !used[i] {
+			result.Unused = append(result.Unused, waiver)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,176 @@
+package filter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+)
+
+type volume struct {
+	testResource
+}
+
+func (r volume) Type() string {
+	return "EBS Volume"
+}
+
+func newWastedResource(r util.AWSResource, accountID string, region string) util.AWSWastedResource {
+	return util.AWSWastedResource{Resource: util.AWSResourceObject{R: r}, AccountID: accountID, Region: region}
+}
+
+func writeWaiverFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "cloudwaste")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	file := filepath.Join(dir, DefaultWaiverFile)
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadWaivers(t *testing.T) {
+	assert := assert.New(t)
+
+	file := writeWaiverFile(t, `
+waivers:
+  - id: vol-123
+    type: EBS Volume
+    account: "123456789012"
+    owner: finops@example.com
+    reason: Disaster recovery volume
+    expires: 2021-12-31
+  - id: arn:aws:ec2:*:123456789012:elastic-ip/eipalloc-*
+    owner: network
+    reason: Allowlisted by partners
+    expires: "2022-06-30"
+`)
+
+	waivers, err := LoadWaivers(file)
+	assert.Nil(err)
+	if assert.Equal(2, len(waivers)) {
+		assert.Equal(Waiver{
+			ID:      "vol-123",
+			Type:    "EBS Volume",
+			Account: "123456789012",
+			Owner:   "finops@example.com",
+			Reason:  "Disaster recovery volume",
+			Expires: time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC),
+		}, waivers[0])
+		assert.Equal(time.Date(2022, 6, 30, 0, 0, 0, 0, time.UTC), waivers[1].Expires)
+	}
+
+	// Test error cases
+	_, err = LoadWaivers(writeWaiverFile(t, `
+waivers:
+  - id: vol-123
+    reason: Disaster recovery volume
+    expires: 2021-12-31
+`))
+	assert.True(errors.Is(err, InvalidWaiverError))
+	assert.Contains(err.Error(), "waivers[0].owner: missing")
+
+	_, err = LoadWaivers(writeWaiverFile(t, `
+waivers:
+  - id: "vol-[123"
+    owner: finops@example.com
+    reason: Disaster recovery volume
+    expires: 2021-12-31
+`))
+	assert.True(errors.Is(err, InvalidWaiverError))
+
+	_, err = LoadWaivers(writeWaiverFile(t, `
+waivers:
+  - id: "arn:aws:ec2:us-east-1:123456789012:"
+    owner: finops@example.com
+    reason: Disaster recovery volume
+    expires: 2021-12-31
+`))
+	assert.True(errors.Is(err, InvalidWaiverError))
+	assert.Contains(err.Error(), "waivers[0].id: no resource ID")
+
+	_, err = LoadWaivers(writeWaiverFile(t, `
+waivers:
+  - id: vol-123
+    owner: finops@example.com
+    reason: Disaster recovery volume
+    expires: next year
+`))
+	assert.NotNil(err)
+
+	_, err = LoadWaivers(filepath.Join(os.TempDir(), "missing", DefaultWaiverFile))
+	assert.NotNil(err)
+}
+
+func TestWaiverMatches(t *testing.T) {
+	assert := assert.New(t)
+
+	vol := newWastedResource(volume{testResource{id: "vol-123"}}, "123456789012", "us-east-1")
+	alb := newWastedResource(testResource{id: "my-alb"}, "123456789012", "eu-west-1")
+
+	assert.True(Waiver{ID: "vol-123"}.Matches(vol))
+	assert.True(Waiver{ID: "vol-*", Type: "ebs volume", Account: "123456789012"}.Matches(vol))
+	assert.False(Waiver{ID: "vol-*", Type: "EBS Snapshot"}.Matches(vol))
+	assert.False(Waiver{ID: "vol-*", Account: "210987654321"}.Matches(vol))
+	assert.False(Waiver{ID: "vol-12"}.Matches(vol))
+
+	assert.True(Waiver{ID: "arn:aws:ec2:us-east-1:123456789012:volume/vol-123"}.Matches(vol))
+	assert.True(Waiver{ID: "arn:aws:ec2:*:123456789012:volume/vol-*"}.Matches(vol))
+	assert.False(Waiver{ID: "arn:aws:ec2:us-west-2:123456789012:volume/vol-123"}.Matches(vol))
+	assert.False(Waiver{ID: "arn:aws:ec2:us-east-1:210987654321:volume/vol-123"}.Matches(vol))
+	assert.True(Waiver{ID: "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/app/my-alb/50dc6c495c0c9188"}.Matches(alb))
+	assert.True(Waiver{ID: "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/my-alb"}.Matches(alb))
+
+	// Only the ID part of the resource matches, not its type or the rest of the path
+	app := newWastedResource(testResource{id: "app"}, "123456789012", "eu-west-1")
+	assert.False(Waiver{ID: "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/app/my-alb/50dc6c495c0c9188"}.Matches(app))
+	assert.False(Waiver{ID: "arn:aws:ec2:us-east-1:123456789012:volume"}.Matches(vol))
+	assert.False(Waiver{ID: "arn:aws:ec2:us-east-1:123456789012:"}.Matches(vol))
+}
+
+func TestApplyWaivers(t *testing.T) {
+	assert := assert.New(t)
+
+	resources := []util.AWSWastedResource{
+		newWastedResource(volume{testResource{id: "vol-123"}}, "123456789012", "us-east-1"),
+		newWastedResource(volume{testResource{id: "vol-456"}}, "123456789012", "us-east-1"),
+		newWastedResource(testResource{id: "eipalloc-1"}, "123456789012", "us-east-1"),
+	}
+	waivers := []Waiver{
+		{ID: "vol-123", Owner: "finops", Reason: "DR", Expires: time.Date(2021, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{ID: "vol-*", Owner: "storage", Reason: "migration", Expires: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{ID: "eipalloc-1", Owner: "network", Reason: "partners", Expires: time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{ID: "i-deleted", Owner: "compute", Reason: "batch", Expires: time.Date(2021, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+
+	// Waivers apply through the last day of their expiry date
+	result := ApplyWaivers(resources, waivers, time.Date(2021, 3, 31, 23, 59, 0, 0, time.UTC))
+	if assert.Equal(1, len(result.Kept)) {
+		assert.Equal("vol-456", result.Kept[0].Resource.R.ID())
+	}
+	if assert.Equal(2, len(result.Suppressed)) {
+		assert.Equal("vol-123", result.Suppressed[0].Resource.Resource.R.ID())
+		assert.Equal("waived by finops: DR", result.Suppressed[0].Reason)
+		assert.Equal("waived by network: partners", result.Suppressed[1].Reason)
+	}
+	if assert.Equal(1, len(result.Expired)) {
+		assert.Equal("vol-*", result.Expired[0].ID)
+	}
+	if assert.Equal(1, len(result.Unused)) {
+		assert.Equal("i-deleted", result.Unused[0].ID)
+	}
+
+	result = ApplyWaivers(resources, waivers, time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(2, len(result.Kept))
+	assert.Equal(2, len(result.Expired))
+}
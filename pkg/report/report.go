@@ -0,0 +1,539 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+	"github.com/cloudwaste/cloudwaste/pkg/filter"
+)
+
+type Format string
+
+const (
+	FormatText  Format = "text"
+	FormatJSON  Format = "json"
+	FormatCSV   Format = "csv"
+	FormatTable Format = "table"
+)
+
+type SortBy string
+
+const (
+	SortByNone   SortBy = "none"
+	SortByCost   SortBy = "cost"
+	SortByType   SortBy = "type"
+	SortByRegion SortBy = "region"
+)
+
+var (
+	Formats = []Format{FormatText, FormatJSON, FormatCSV, FormatTable}
+	SortBys = []SortBy{SortByNone, SortByCost, SortByType, SortByRegion}
+
+	UnknownFormatError = errors.New("Unknown output format")
+	UnknownSortByError = errors.New("Unknown sort order")
+)
+
+// Record is the flattened form of a wasted resource written by every format
+type Record struct {
+	Type         string  `json:"type"`
+	ID           string  `json:"id"`
+	AccountID    string  `json:"account_id"`
+	AccountAlias string  `json:"account_alias,omitempty"`
+	Region       string  `json:"region"`
+	Rate         float64 `json:"rate"`
+	Unit         string  `json:"unit"`
+	// Currency is the currency of the rate and of the costs, e.g. CNY in the China regions
+	Currency string  `json:"currency"`
+	Hourly   float64 `json:"hourly"`
+	Monthly  float64 `json:"monthly"`
+	Yearly   float64 `json:"yearly"`
+	// Rule and Reason are the rule that found the resource wasted and why, in words
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	// Evidence is what the resource was judged wasted on, e.g. the window its usage was checked over
+	Evidence map[string]string `json:"evidence,omitempty"`
+}
+
+// account returns the alias of the account of a record, or its ID if it has none
+func (r Record) account() string {
+	if r.AccountAlias != "" {
+		return r.AccountAlias
+	}
+	return r.AccountID
+}
+
+// ErrorRecord is a resource, or a whole analyzer, that couldn't be inspected
+type ErrorRecord struct {
+	Analyzer   string `json:"analyzer"`
+	AccountID  string `json:"account_id,omitempty"`
+	Region     string `json:"region"`
+	ResourceID string `json:"resource_id,omitempty"`
+	Operation  string `json:"operation,omitempty"`
+	Code       string `json:"code,omitempty"`
+	Message    string `json:"message"`
+}
+
+// Total is the number and cost of wasted resources priced in a single currency
+type Total struct {
+	Currency string    `json:"currency"`
+	Count    int       `json:"count"`
+	Cost     util.Cost `json:"cost"`
+}
+
+// Subtotal is the number and cost of wasted resources of a single type and currency
+type Subtotal struct {
+	Type     string    `json:"type"`
+	Currency string    `json:"currency"`
+	Count    int       `json:"count"`
+	Cost     util.Cost `json:"cost"`
+}
+
+// ReasonSubtotal is the number and cost of wasted resources of a single currency
+// suppressed for a single reason
+type ReasonSubtotal struct {
+	Reason   string    `json:"reason"`
+	Currency string    `json:"currency"`
+	Count    int       `json:"count"`
+	Cost     util.Cost `json:"cost"`
+}
+
+// SuppressedSummary totals the cost of the wasted resources left out of the report
+type SuppressedSummary struct {
+	Count    int              `json:"count"`
+	Totals   []Total          `json:"totals"`
+	ByReason []ReasonSubtotal `json:"by_reason"`
+}
+
+// Summary totals the cost of all wasted resources. Costs in different currencies are
+// never added up, there's a total per currency instead.
+type Summary struct {
+	Count      int               `json:"count"`
+	Totals     []Total           `json:"totals"`
+	ByType     []Subtotal        `json:"by_type"`
+	Suppressed SuppressedSummary `json:"suppressed"`
+}
+
+// Report is everything written by a renderer
+type Report struct {
+	Resources []Record      `json:"resources"`
+	Summary   Summary       `json:"summary"`
+	Errors    []ErrorRecord `json:"errors"`
+}
+
+// ParseFormat validates the name of an output format
+func ParseFormat(name string) (Format, error) {
+	for _, format := range Formats {
+		if string(format) == strings.ToLower(name) {
+			return format, nil
+		}
+	}
+
+	return "", errors.Wrap(UnknownFormatError, fmt.Sprintf("%q", name))
+}
+
+// ParseSortBy validates the name of a sort order
+func ParseSortBy(name string) (SortBy, error) {
+	if name == "" {
+		return SortByNone, nil
+	}
+
+	for _, sortBy := range SortBys {
+		if string(sortBy) == strings.ToLower(name) {
+			return sortBy, nil
+		}
+	}
+
+	return "", errors.Wrap(UnknownSortByError, fmt.Sprintf("%q", name))
+}
+
+// Sort orders wasted resources in place. Cost sorts the most expensive first, USD before
+// other currencies which can't be compared to it. Ties and the other orders keep the scan order.
+func Sort(resources []util.AWSWastedResource, sortBy SortBy) {
+	switch sortBy {
+	case SortByCost:
+		sort.SliceStable(resources, func(i, j int) bool {
+			ci, cj := resources[i].Price.CurrencyCode(), resources[j].Price.CurrencyCode()
+			if ci != cj {
+				return ci == util.CurrencyUSD || (cj != util.CurrencyUSD && ci < cj)
+			}
+			return resources[i].Cost().Hourly > resources[j].Cost().Hourly
+		})
+	case SortByType:
+		sort.SliceStable(resources, func(i, j int) bool {
+			return resources[i].Resource.R.Type() < resources[j].Resource.R.Type()
+		})
+	case SortByRegion:
+		sort.SliceStable(resources, func(i, j int) bool {
+			return resources[i].Region < resources[j].Region
+		})
+	}
+}
+
+// NewRecords flattens wasted resources into records
+func NewRecords(resources []util.AWSWastedResource) []Record {
+	records := make([]Record, 0, len(resources))
+	for _, r := range resources {
+		cost := r.Cost()
+
+		records = append(records, Record{
+			Type:         r.Resource.R.Type(),
+			ID:           r.Resource.R.ID(),
+			AccountID:    r.AccountID,
+			AccountAlias: r.AccountAlias,
+			Region:       r.Region,
+			Rate:         r.Price.Rate,
+			Unit:         r.Price.Unit,
+			Currency:     r.Price.CurrencyCode(),
+			Hourly:       cost.Hourly,
+			Monthly:      cost.Monthly,
+			Yearly:       cost.Yearly,
+			Rule:         r.Reason.Rule,
+			Reason:       r.Reason.Explanation,
+			Evidence:     r.Evidence,
+		})
+	}
+
+	return records
+}
+
+// NewErrorRecords flattens the failures of a scan into records
+func NewErrorRecords(resourceErrs util.ResourceErrors) []ErrorRecord {
+	records := make([]ErrorRecord, 0, len(resourceErrs))
+	for _, e := range resourceErrs {
+		records = append(records, ErrorRecord{
+			Analyzer:   e.Analyzer,
+			AccountID:  e.AccountID,
+			Region:     e.Region,
+			ResourceID: e.ResourceID,
+			Operation:  e.Operation,
+			Code:       e.Code,
+			Message:    e.Err.Error(),
+		})
+	}
+
+	return records
+}
+
+// addTotal adds the cost of a wasted resource to the total of its currency, totals are
+// in order of first appearance
+func addTotal(totals []Total, currency string, cost util.Cost) []Total {
+	for i := range totals {
+		if totals[i].Currency == currency {
+			totals[i].Count++
+			totals[i].Cost = totals[i].Cost.Add(cost)
+			return totals
+		}
+	}
+	return append(totals, Total{Currency: currency, Count: 1, Cost: cost})
+}
+
+// NewSummary totals the cost of wasted resources per currency and per type and currency,
+// with types in order of first appearance
+func NewSummary(resources []util.AWSWastedResource) Summary {
+	summary := Summary{Totals: []Total{}, ByType: []Subtotal{}}
+	index := make(map[[2]string]int)
+
+	for _, r := range resources {
+		cost := r.Cost()
+		currency := r.Price.CurrencyCode()
+		key := [2]string{r.Resource.R.Type(), currency}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(summary.ByType)
+			index[key] = i
+			summary.ByType = append(summary.ByType, Subtotal{Type: key[0], Currency: currency})
+		}
+
+		summary.ByType[i].Count++
+		summary.ByType[i].Cost = summary.ByType[i].Cost.Add(cost)
+		summary.Count++
+		summary.Totals = addTotal(summary.Totals, currency, cost)
+	}
+
+	return summary
+}
+
+// NewSuppressedSummary totals the cost of suppressed resources per currency and per
+// reason and currency, with reasons in order of first appearance
+func NewSuppressedSummary(suppressed []filter.Suppressed) SuppressedSummary {
+	summary := SuppressedSummary{Totals: []Total{}, ByReason: []ReasonSubtotal{}}
+	index := make(map[[2]string]int)
+
+	for _, s := range suppressed {
+		cost := s.Resource.Cost()
+		currency := s.Resource.Price.CurrencyCode()
+		key := [2]string{s.Reason, currency}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(summary.ByReason)
+			index[key] = i
+			summary.ByReason = append(summary.ByReason, ReasonSubtotal{Reason: s.Reason, Currency: currency})
+		}
+
+		summary.ByReason[i].Count++
+		summary.ByReason[i].Cost = summary.ByReason[i].Cost.Add(cost)
+		summary.Count++
+		summary.Totals = addTotal(summary.Totals, currency, cost)
+	}
+
+	return summary
+}
+
+// totalsOrZero returns the totals to render, a zero USD total if there are none
+func totalsOrZero(totals []Total) []Total {
+	if len(totals) == 0 {
+		return []Total{{Currency: util.CurrencyUSD}}
+	}
+	return totals
+}
+
+// formatMoney formats an amount of a currency with its symbol, e.g. $1.50 or ¥1.50
+func formatMoney(currency string, format string, amount float64) string {
+	switch currency {
+	case "", util.CurrencyUSD:
+		return "$" + fmt.Sprintf(format, amount)
+	case util.CurrencyCNY:
+		return "¥" + fmt.Sprintf(format, amount)
+	}
+	return fmt.Sprintf(format, amount) + " " + currency
+}
+
+// NewReport builds the report for wasted resources, the ones suppressed by filters and
+// the failures of the scan that found them
+func NewReport(resources []util.AWSWastedResource, suppressed []filter.Suppressed, resourceErrs util.ResourceErrors) Report {
+	summary := NewSummary(resources)
+	summary.Suppressed = NewSuppressedSummary(suppressed)
+
+	return Report{
+		Resources: NewRecords(resources),
+		Summary:   summary,
+		Errors:    NewErrorRecords(resourceErrs),
+	}
+}
+
+// Render writes the report to w in the given format
+func Render(w io.Writer, format Format, report Report) error {
+	switch format {
+	case FormatText:
+		return renderText(w, report)
+	case FormatJSON:
+		return renderJSON(w, report)
+	case FormatCSV:
+		return renderCSV(w, report)
+	case FormatTable:
+		return renderTable(w, report)
+	}
+
+	return errors.Wrap(UnknownFormatError, fmt.Sprintf("%q", format))
+}
+
+func renderText(w io.Writer, report Report) error {
+	for _, r := range report.Resources {
+		_, err := fmt.Fprintf(w, "%s - %s (%s): %s/%s (%s/month)\n", r.Type, r.ID, join("/", r.account(), r.Region),
+			formatMoney(r.Currency, "%f", r.Rate), r.Unit, formatMoney(r.Currency, "%.2f", r.Monthly))
+		if err != nil {
+			return err
+		}
+
+		if reason := join(": ", r.Rule, r.Reason); reason != "" {
+			if _, err := fmt.Fprintf(w, "  %s\n", reason); err != nil {
+				return err
+			}
+		}
+
+		if len(r.Evidence) > 0 {
+			if _, err := fmt.Fprintf(w, "  evidence: %s\n", formatEvidence(r.Evidence)); err != nil {
+				return err
+			}
+		}
+	}
+
+	summary := report.Summary
+	for _, subtotal := range summary.ByType {
+		_, err := fmt.Fprintf(w, "%s: %d wasted, %s/month, %s/year\n", subtotal.Type, subtotal.Count,
+			formatMoney(subtotal.Currency, "%.2f", subtotal.Cost.Monthly), formatMoney(subtotal.Currency, "%.2f", subtotal.Cost.Yearly))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, total := range totalsOrZero(summary.Totals) {
+		_, err := fmt.Fprintf(w, "Total: %d wasted, %s/month, %s/year\n", total.Count,
+			formatMoney(total.Currency, "%.2f", total.Cost.Monthly), formatMoney(total.Currency, "%.2f", total.Cost.Yearly))
+		if err != nil {
+			return err
+		}
+	}
+
+	if summary.Suppressed.Count > 0 {
+		for _, total := range summary.Suppressed.Totals {
+			_, err := fmt.Fprintf(w, "Suppressed: %d wasted, %s/month, %s/year\n", total.Count,
+				formatMoney(total.Currency, "%.2f", total.Cost.Monthly), formatMoney(total.Currency, "%.2f", total.Cost.Yearly))
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, subtotal := range summary.Suppressed.ByReason {
+			_, err := fmt.Fprintf(w, "Suppressed (%s): %d wasted, %s/month, %s/year\n", subtotal.Reason, subtotal.Count,
+				formatMoney(subtotal.Currency, "%.2f", subtotal.Cost.Monthly), formatMoney(subtotal.Currency, "%.2f", subtotal.Cost.Yearly))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(report.Errors) == 0 {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(w, "Errors: %d resources couldn't be inspected\n", len(report.Errors))
+	if err != nil {
+		return err
+	}
+
+	for _, e := range report.Errors {
+		location := join("/", e.AccountID, e.Region)
+		if location != "" {
+			location = "(" + location + ")"
+		}
+
+		_, err := fmt.Fprintf(w, "%s: %s\n", join(" ", e.Analyzer, location), join(": ", e.ResourceID, e.Operation, e.Message))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// join joins the parts that are set
+func join(sep string, parts ...string) string {
+	var set []string
+	for _, part := range parts {
+		if part != "" {
+			set = append(set, part)
+		}
+	}
+
+	return strings.Join(set, sep)
+}
+
+func renderJSON(w io.Writer, report Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(report)
+}
+
+// renderCSV writes only the resources, every row already carries its own cost. The
+// errors of the scan are logged instead, and suppressed resources are left out.
+func renderCSV(w io.Writer, report Report) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write([]string{"type", "id", "account_id", "account_alias", "region", "rate", "unit", "currency", "hourly", "monthly", "yearly", "rule", "reason", "evidence"})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range report.Resources {
+		err := writer.Write([]string{
+			r.Type,
+			r.ID,
+			r.AccountID,
+			r.AccountAlias,
+			r.Region,
+			formatFloat(r.Rate),
+			r.Unit,
+			r.Currency,
+			formatFloat(r.Hourly),
+			formatFloat(r.Monthly),
+			formatFloat(r.Yearly),
+			r.Rule,
+			r.Reason,
+			formatEvidence(r.Evidence),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func renderTable(w io.Writer, report Report) error {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(writer, "TYPE\tID\tACCOUNT\tREGION\tRATE\tUNIT\tMONTHLY\tYEARLY\tRULE\tREASON\tEVIDENCE")
+	for _, r := range report.Resources {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Type, r.ID, r.account(), r.Region,
+			formatMoney(r.Currency, "%f", r.Rate), r.Unit, formatMoney(r.Currency, "%.2f", r.Monthly), formatMoney(r.Currency, "%.2f", r.Yearly),
+			r.Rule, r.Reason, formatEvidence(r.Evidence))
+	}
+
+	fmt.Fprintln(writer)
+	fmt.Fprintln(writer, "TYPE\tCOUNT\tMONTHLY\tYEARLY")
+	for _, subtotal := range report.Summary.ByType {
+		fmt.Fprintf(writer, "%s\t%d\t%s\t%s\n", subtotal.Type, subtotal.Count,
+			formatMoney(subtotal.Currency, "%.2f", subtotal.Cost.Monthly), formatMoney(subtotal.Currency, "%.2f", subtotal.Cost.Yearly))
+	}
+	for _, total := range totalsOrZero(report.Summary.Totals) {
+		fmt.Fprintf(writer, "TOTAL\t%d\t%s\t%s\n", total.Count,
+			formatMoney(total.Currency, "%.2f", total.Cost.Monthly), formatMoney(total.Currency, "%.2f", total.Cost.Yearly))
+	}
+
+	if suppressed := report.Summary.Suppressed; suppressed.Count > 0 {
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "SUPPRESSED\tCOUNT\tMONTHLY\tYEARLY")
+		for _, subtotal := range suppressed.ByReason {
+			fmt.Fprintf(writer, "%s\t%d\t%s\t%s\n", subtotal.Reason, subtotal.Count,
+				formatMoney(subtotal.Currency, "%.2f", subtotal.Cost.Monthly), formatMoney(subtotal.Currency, "%.2f", subtotal.Cost.Yearly))
+		}
+		for _, total := range suppressed.Totals {
+			fmt.Fprintf(writer, "TOTAL\t%d\t%s\t%s\n", total.Count,
+				formatMoney(total.Currency, "%.2f", total.Cost.Monthly), formatMoney(total.Currency, "%.2f", total.Cost.Yearly))
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		fmt.Fprintln(writer)
+		fmt.Fprintln(writer, "ANALYZER\tACCOUNT\tREGION\tRESOURCE\tOPERATION\tCODE\tERROR")
+		for _, e := range report.Errors {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", e.Analyzer, e.AccountID, e.Region, e.ResourceID, e.Operation, e.Code, e.Message)
+		}
+	}
+
+	return writer.Flush()
+}
+
+// formatEvidence writes the evidence of a resource on a single line, sorted by key
+func formatEvidence(evidence map[string]string) string {
+	keys := make([]string, 0, len(evidence))
+	for key := range evidence {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+evidence[key])
+	}
+
+	return strings.Join(pairs, "; ")
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
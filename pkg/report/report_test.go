@@ -0,0 +1,335 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudwaste/cloudwaste/pkg/aws/util"
+	"github.com/cloudwaste/cloudwaste/pkg/filter"
+)
+
+type testResource struct {
+	id string
+}
+
+func (r testResource) Type() string {
+	return "Test Resource"
+}
+
+func (r testResource) ID() string {
+	return r.id
+}
+
+func (r testResource) Tags() map[string]string {
+	return nil
+}
+
+type otherResource struct {
+	id string
+}
+
+func (r otherResource) Type() string {
+	return "Other Resource"
+}
+
+func (r otherResource) ID() string {
+	return r.id
+}
+
+func (r otherResource) Tags() map[string]string {
+	return nil
+}
+
+var resources = []util.AWSWastedResource{
+	{
+		Resource:     util.AWSResourceObject{R: testResource{"res1"}},
+		Price:        util.Price{Unit: "Hr", Rate: 0.045},
+		Region:       "us-east-1",
+		AccountID:    "123456789012",
+		AccountAlias: "prod",
+		Reason:       util.Reason{Rule: "idle-instance-underutilized", Explanation: "CPU utilization stayed below 5% over 14d"},
+		Evidence:     map[string]string{"MetricStartTime": "2021-03-01T00:00:00Z", "CPUUtilization.Average.max": "1.2"},
+	},
+	{
+		Resource:  util.AWSResourceObject{R: testResource{"res,2"}},
+		Price:     util.Price{Unit: "Mo", Rate: 50},
+		Region:    "us-west-2",
+		AccountID: "210987654321",
+	},
+}
+
+var suppressed = []filter.Suppressed{
+	{Resource: resources[0], Reason: "tagged env=dr"},
+	{Resource: resources[1], Reason: "tagged env=dr"},
+	{
+		Resource: util.AWSWastedResource{
+			Resource: util.AWSResourceObject{R: otherResource{"other1"}},
+			Price:    util.Price{Unit: "Mo", Rate: 10},
+		},
+		Reason: "tagged keep",
+	},
+}
+
+var resourceErrs = util.ResourceErrors{
+	{
+		Analyzer:   "idle-instance",
+		AccountID:  "123456789012",
+		Region:     "us-east-1",
+		ResourceID: "i-123",
+		Operation:  "GetMetricData",
+		Code:       "Throttling",
+		Err:        errors.New("Throttling: Rate exceeded"),
+	},
+	{
+		Analyzer: "rds-idle-database",
+		Region:   "us-west-2",
+		Err:      errors.New("failed"),
+	},
+}
+
+func TestParseFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	format, err := ParseFormat("JSON")
+	assert.Nil(err)
+	assert.Equal(FormatJSON, format)
+
+	_, err = ParseFormat("xml")
+	assert.True(errors.Is(err, UnknownFormatError))
+}
+
+func TestParseSortBy(t *testing.T) {
+	assert := assert.New(t)
+
+	sortBy, err := ParseSortBy("")
+	assert.Nil(err)
+	assert.Equal(SortByNone, sortBy)
+
+	sortBy, err = ParseSortBy("cost")
+	assert.Nil(err)
+	assert.Equal(SortByCost, sortBy)
+
+	_, err = ParseSortBy("name")
+	assert.True(errors.Is(err, UnknownSortByError))
+}
+
+func TestSort(t *testing.T) {
+	assert := assert.New(t)
+
+	sorted := append([]util.AWSWastedResource{}, resources...)
+	Sort(sorted, SortByCost)
+	assert.Equal("res,2", sorted[0].Resource.R.ID())
+	assert.Equal("res1", sorted[1].Resource.R.ID())
+
+	Sort(sorted, SortByRegion)
+	assert.Equal("res1", sorted[0].Resource.R.ID())
+	assert.Equal("res,2", sorted[1].Resource.R.ID())
+}
+
+func TestNewSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	summary := NewSummary(append(resources, util.AWSWastedResource{
+		Resource: util.AWSResourceObject{R: otherResource{"other1"}},
+		Price:    util.Price{Unit: "Mo", Rate: 10},
+	}))
+
+	assert.Equal(3, summary.Count)
+	if assert.Equal(1, len(summary.Totals)) {
+		assert.Equal(util.CurrencyUSD, summary.Totals[0].Currency)
+		assert.InDelta(0.045*730+50+10, summary.Totals[0].Cost.Monthly, 1e-9)
+	}
+	if assert.Equal(2, len(summary.ByType)) {
+		assert.Equal("Test Resource", summary.ByType[0].Type)
+		assert.Equal(2, summary.ByType[0].Count)
+		assert.InDelta(0.045*730+50, summary.ByType[0].Cost.Monthly, 1e-9)
+		assert.Equal("Other Resource", summary.ByType[1].Type)
+		assert.Equal(1, summary.ByType[1].Count)
+		assert.InDelta(120, summary.ByType[1].Cost.Yearly, 1e-9)
+	}
+
+	// Costs in other currencies are totaled on their own
+	summary = NewSummary(append(resources, util.AWSWastedResource{
+		Resource: util.AWSResourceObject{R: testResource{"res3"}},
+		Price:    util.Price{Unit: "Mo", Rate: 70, Currency: util.CurrencyCNY},
+		Region:   "cn-north-1",
+	}))
+
+	assert.Equal(3, summary.Count)
+	if assert.Equal(2, len(summary.Totals)) {
+		assert.Equal(util.CurrencyUSD, summary.Totals[0].Currency)
+		assert.Equal(2, summary.Totals[0].Count)
+		assert.InDelta(0.045*730+50, summary.Totals[0].Cost.Monthly, 1e-9)
+		assert.Equal(util.CurrencyCNY, summary.Totals[1].Currency)
+		assert.Equal(1, summary.Totals[1].Count)
+		assert.InDelta(70, summary.Totals[1].Cost.Monthly, 1e-9)
+	}
+	if assert.Equal(2, len(summary.ByType)) {
+		assert.Equal(Subtotal{Type: "Test Resource", Currency: util.CurrencyCNY, Count: 1, Cost: summary.Totals[1].Cost}, summary.ByType[1])
+	}
+}
+
+func TestNewSuppressedSummary(t *testing.T) {
+	assert := assert.New(t)
+
+	summary := NewSuppressedSummary(suppressed)
+	assert.Equal(3, summary.Count)
+	if assert.Equal(1, len(summary.Totals)) {
+		assert.InDelta(0.045*730+50+10, summary.Totals[0].Cost.Monthly, 1e-9)
+	}
+	if assert.Equal(2, len(summary.ByReason)) {
+		assert.Equal("tagged env=dr", summary.ByReason[0].Reason)
+		assert.Equal(2, summary.ByReason[0].Count)
+		assert.Equal("tagged keep", summary.ByReason[1].Reason)
+		assert.InDelta(10, summary.ByReason[1].Cost.Monthly, 1e-9)
+	}
+
+	assert.Equal([]ReasonSubtotal{}, NewSuppressedSummary(nil).ByReason)
+}
+
+func TestRenderText(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatText, NewReport(resources, nil, nil)))
+	assert.Equal("Test Resource - res1 (prod/us-east-1): $0.045000/Hr ($32.85/month)\n"+
+		"  idle-instance-underutilized: CPU utilization stayed below 5% over 14d\n"+
+		"  evidence: CPUUtilization.Average.max=1.2; MetricStartTime=2021-03-01T00:00:00Z\n"+
+		"Test Resource - res,2 (210987654321/us-west-2): $50.000000/Mo ($50.00/month)\n"+
+		"Test Resource: 2 wasted, $82.85/month, $994.20/year\n"+
+		"Total: 2 wasted, $82.85/month, $994.20/year\n", buf.String())
+}
+
+func TestRenderTextSuppressed(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatText, NewReport(nil, suppressed, nil)))
+	assert.Equal("Total: 0 wasted, $0.00/month, $0.00/year\n"+
+		"Suppressed: 3 wasted, $92.85/month, $1114.20/year\n"+
+		"Suppressed (tagged env=dr): 2 wasted, $82.85/month, $994.20/year\n"+
+		"Suppressed (tagged keep): 1 wasted, $10.00/month, $120.00/year\n", buf.String())
+}
+
+func TestRenderTextErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatText, NewReport(nil, nil, resourceErrs)))
+	assert.Equal("Total: 0 wasted, $0.00/month, $0.00/year\n"+
+		"Errors: 2 resources couldn't be inspected\n"+
+		"idle-instance (123456789012/us-east-1): i-123: GetMetricData: Throttling: Rate exceeded\n"+
+		"rds-idle-database (us-west-2): failed\n", buf.String())
+}
+
+func TestRenderTextCurrencies(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatText, NewReport(append(resources[1:], util.AWSWastedResource{
+		Resource: util.AWSResourceObject{R: testResource{"res3"}},
+		Price:    util.Price{Unit: "Mo", Rate: 70, Currency: util.CurrencyCNY},
+		Region:   "cn-north-1",
+	}), nil, nil)))
+	assert.Equal("Test Resource - res,2 (210987654321/us-west-2): $50.000000/Mo ($50.00/month)\n"+
+		"Test Resource - res3 (cn-north-1): ¥70.000000/Mo (¥70.00/month)\n"+
+		"Test Resource: 1 wasted, $50.00/month, $600.00/year\n"+
+		"Test Resource: 1 wasted, ¥70.00/month, ¥840.00/year\n"+
+		"Total: 1 wasted, $50.00/month, $600.00/year\n"+
+		"Total: 1 wasted, ¥70.00/month, ¥840.00/year\n", buf.String())
+}
+
+func TestRenderJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatJSON, NewReport(resources, nil, nil)))
+
+	var report Report
+	assert.Nil(json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(NewRecords(resources), report.Resources)
+	assert.Equal(2, report.Summary.Count)
+	assert.Equal("1.2", report.Resources[0].Evidence["CPUUtilization.Average.max"])
+	assert.Equal("idle-instance-underutilized", report.Resources[0].Rule)
+	assert.Equal("CPU utilization stayed below 5% over 14d", report.Resources[0].Reason)
+	assert.NotContains(buf.String(), `"rule":""`)
+	assert.NotContains(buf.String(), `"evidence":null`)
+
+	buf.Reset()
+	assert.Nil(Render(&buf, FormatJSON, NewReport(nil, nil, nil)))
+	assert.Nil(json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal([]Record{}, report.Resources)
+	assert.Equal(0, report.Summary.Count)
+	assert.Equal([]ErrorRecord{}, report.Errors)
+	assert.Equal([]ReasonSubtotal{}, report.Summary.Suppressed.ByReason)
+
+	buf.Reset()
+	assert.Nil(Render(&buf, FormatJSON, NewReport(nil, nil, resourceErrs)))
+	assert.Nil(json.Unmarshal(buf.Bytes(), &report))
+	if assert.Equal(2, len(report.Errors)) {
+		assert.Equal(ErrorRecord{
+			Analyzer:   "idle-instance",
+			AccountID:  "123456789012",
+			Region:     "us-east-1",
+			ResourceID: "i-123",
+			Operation:  "GetMetricData",
+			Code:       "Throttling",
+			Message:    "Throttling: Rate exceeded",
+		}, report.Errors[0])
+	}
+}
+
+func TestRenderCSV(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatCSV, NewReport(resources, nil, nil)))
+	assert.Equal("type,id,account_id,account_alias,region,rate,unit,currency,hourly,monthly,yearly,rule,reason,evidence\n"+
+		"Test Resource,res1,123456789012,prod,us-east-1,0.045,Hr,USD,0.045,32.85,394.2,idle-instance-underutilized,CPU utilization stayed below 5% over 14d,"+
+		"CPUUtilization.Average.max=1.2; MetricStartTime=2021-03-01T00:00:00Z\n"+
+		"Test Resource,\"res,2\",210987654321,,us-west-2,50,Mo,USD,0.0684931506849315,50,600,,,\n", buf.String())
+}
+
+func TestRenderTable(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.Nil(Render(&buf, FormatTable, NewReport(resources, nil, nil)))
+	assert.Equal("TYPE           ID     ACCOUNT       REGION     RATE        UNIT  MONTHLY  YEARLY   RULE                         REASON                                    EVIDENCE\n"+
+		"Test Resource  res1   prod          us-east-1  $0.045000   Hr    $32.85   $394.20  idle-instance-underutilized  CPU utilization stayed below 5% over 14d  "+
+		"CPUUtilization.Average.max=1.2; MetricStartTime=2021-03-01T00:00:00Z\n"+
+		"Test Resource  res,2  210987654321  us-west-2  $50.000000  Mo    $50.00   $600.00  "+strings.Repeat(" ", 71)+"\n"+
+		"\n"+
+		"TYPE           COUNT  MONTHLY  YEARLY\n"+
+		"Test Resource  2      $82.85   $994.20\n"+
+		"TOTAL          2      $82.85   $994.20\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(Render(&buf, FormatTable, NewReport(nil, nil, resourceErrs[:1])))
+	assert.Equal("TYPE  ID  ACCOUNT  REGION  RATE  UNIT  MONTHLY  YEARLY  RULE  REASON  EVIDENCE\n"+
+		"\n"+
+		"TYPE   COUNT  MONTHLY  YEARLY\n"+
+		"TOTAL  0      $0.00    $0.00\n"+
+		"\n"+
+		"ANALYZER       ACCOUNT       REGION     RESOURCE  OPERATION      CODE        ERROR\n"+
+		"idle-instance  123456789012  us-east-1  i-123     GetMetricData  Throttling  Throttling: Rate exceeded\n", buf.String())
+
+	buf.Reset()
+	assert.Nil(Render(&buf, FormatTable, NewReport(nil, suppressed[2:], nil)))
+	assert.Equal("TYPE  ID  ACCOUNT  REGION  RATE  UNIT  MONTHLY  YEARLY  RULE  REASON  EVIDENCE\n"+
+		"\n"+
+		"TYPE   COUNT  MONTHLY  YEARLY\n"+
+		"TOTAL  0      $0.00    $0.00\n"+
+		"\n"+
+		"SUPPRESSED   COUNT  MONTHLY  YEARLY\n"+
+		"tagged keep  1      $10.00   $120.00\n"+
+		"TOTAL        1      $10.00   $120.00\n", buf.String())
+
+	// Test error cases
+	assert.NotNil(Render(&buf, Format("xml"), NewReport(resources, nil, nil)))
+}